@@ -0,0 +1,29 @@
+package benchmon
+
+import "github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+
+// influxPoints returns a single point, an unreachable list
+// has only the up field set.
+func influxPoints(result *listResult) []sink.Point {
+	fields := map[string]interface{}{
+		"up":    result.reachable(),
+		"valid": result.valid(),
+	}
+
+	if result.reachable() {
+		fields["fetch_ms"] = sink.Millis(result.latency)
+		fields["size"] = result.size
+		fields["benchmarks"] = result.benchmarks
+		fields["problems"] = len(result.problems)
+		if !result.lastModified.IsZero() {
+			fields["age_seconds"] = result.age().Seconds()
+		}
+	}
+
+	return []sink.Point{{
+		Measurement: influxMeasurementFlag,
+		Tags:        map[string]string{"url": result.url},
+		Fields:      fields,
+		Time:        result.time,
+	}}
+}
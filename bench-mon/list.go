@@ -0,0 +1,167 @@
+package benchmon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/sonm-io/core/proto"
+)
+
+// maxListSize limits the list read, the real one is a few kilobytes.
+const maxListSize = 4 << 20
+
+// benchmarkList is the schema workers expect, fields are pointers
+// where the zero value is valid, so missing ones are told apart.
+type benchmarkList struct {
+	Benchmarks map[string]*benchmark `json:"benchmarks"`
+}
+
+type benchmark struct {
+	Code        *uint64 `json:"code"`
+	Type        *int32  `json:"type"`
+	Image       string  `json:"image"`
+	Description string  `json:"description"`
+}
+
+// listResult is the state of the list found by a poll.
+type listResult struct {
+	time    time.Time
+	url     string
+	latency time.Duration
+	size    int
+	// lastModified is zero if the server sends no Last-Modified.
+	lastModified time.Time
+	benchmarks   int
+	// problems are schema violations, a list having
+	// any is rejected by workers.
+	problems []string
+	// err is set if the list could not be fetched,
+	// other fields but time and url are unset then.
+	err error
+}
+
+func (r *listResult) reachable() bool {
+	return r.err == nil
+}
+
+func (r *listResult) valid() bool {
+	return r.err == nil && len(r.problems) == 0
+}
+
+// age is time since the list was modified, zero if unknown.
+func (r *listResult) age() time.Duration {
+	if r.lastModified.IsZero() {
+		return 0
+	}
+
+	return r.time.Sub(r.lastModified)
+}
+
+func (r *listResult) stale() bool {
+	return maxAgeFlag > 0 && r.age() > maxAgeFlag
+}
+
+// checker fetches and validates the list.
+type checker struct {
+	url    string
+	client *http.Client
+}
+
+func (c *checker) check(ctx context.Context) *listResult {
+	result := &listResult{time: time.Now(), url: c.url}
+
+	req, err := http.NewRequest(http.MethodGet, c.url, nil)
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		result.err = err
+		return result
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		result.err = fmt.Errorf("unexpected status %s", resp.Status)
+		return result
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxListSize))
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	result.latency = time.Since(result.time)
+	result.size = len(data)
+	if lm, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		result.lastModified = lm
+	}
+
+	result.benchmarks, result.problems = validate(data)
+	return result
+}
+
+// validate parses the list and returns the number of benchmarks
+// with schema violations found. Codes index benchmark values of
+// orders and deals, so they must be unique and have no gaps.
+func validate(data []byte) (int, []string) {
+	var list benchmarkList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return 0, []string{fmt.Sprintf("invalid JSON: %v", err)}
+	}
+
+	if len(list.Benchmarks) < minBenchmarksFlag {
+		return len(list.Benchmarks), []string{fmt.Sprintf("%d benchmarks, expected at least %d", len(list.Benchmarks), minBenchmarksFlag)}
+	}
+
+	ids := make([]string, 0, len(list.Benchmarks))
+	for id := range list.Benchmarks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var problems []string
+	codes := map[uint64]string{}
+	for _, id := range ids {
+		b := list.Benchmarks[id]
+		if b == nil {
+			problems = append(problems, fmt.Sprintf("`%s` is null", id))
+			continue
+		}
+
+		if b.Type == nil {
+			problems = append(problems, fmt.Sprintf("`%s` has no type", id))
+		} else if _, ok := sonm.DeviceType_name[*b.Type]; !ok || *b.Type == int32(sonm.DeviceType_DEV_UNKNOWN) {
+			problems = append(problems, fmt.Sprintf("`%s` has unknown type %d", id, *b.Type))
+		}
+
+		if b.Code == nil {
+			problems = append(problems, fmt.Sprintf("`%s` has no code", id))
+			continue
+		}
+
+		if other, ok := codes[*b.Code]; ok {
+			problems = append(problems, fmt.Sprintf("`%s` and `%s` share code %d", other, id, *b.Code))
+			continue
+		}
+
+		codes[*b.Code] = id
+	}
+
+	for code := uint64(0); code < uint64(len(codes)); code++ {
+		if _, ok := codes[code]; !ok {
+			problems = append(problems, fmt.Sprintf("code %d is missing", code))
+		}
+	}
+
+	return len(list.Benchmarks), problems
+}
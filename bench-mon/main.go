@@ -0,0 +1,131 @@
+// Package benchmon checks the benchmark list workers download on
+// start, a list that is unreachable or malformed silently breaks
+// onboarding of new workers.
+package benchmon
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/daemon"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/health"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/logging"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+	"go.uber.org/zap"
+)
+
+// defaultListURL is the list workers use unless configured otherwise.
+const defaultListURL = "https://raw.githubusercontent.com/sonm-io/benchmarks-list/master/list.json"
+
+var (
+	urlFlag           string
+	maxAgeFlag        time.Duration
+	minBenchmarksFlag int
+	daemonFlag        bool
+	intervalFlag      time.Duration
+	timeoutFlag       time.Duration
+	listenFlag        string
+	alertRulesFlag    string
+
+	influxMeasurementFlag string
+)
+
+// Flags are command line flags of the tool, parsed by Run.
+var Flags = flag.NewFlagSet("sonm-mon bench", flag.ExitOnError)
+
+// logger is used for diagnostics, results printed by
+// console sinks do not go through it.
+var logger = zap.NewNop()
+
+// logOptions are set by the -log flags.
+var logOptions = logging.Options{Name: "bench-mon"}
+
+// sinkOptions configure metrics backends, see the sink package.
+var sinkOptions = sink.Options{InfluxDatabase: "sonm", StatsdPrefix: "sonm", PushJob: "bench_mon"}
+
+// selfHealth tracks polls of the daemon, so the monitor can be monitored.
+var selfHealth = health.New("bench-mon")
+
+func init() {
+	Flags.StringVar(&urlFlag, "url", defaultListURL, "benchmark list URL")
+	Flags.DurationVar(&maxAgeFlag, "max-age", 0, "fail when the list was last modified earlier, zero disables; needs the Last-Modified header")
+	Flags.IntVar(&minBenchmarksFlag, "min-benchmarks", 1, "fail when the list has fewer benchmarks")
+	Flags.BoolVar(&daemonFlag, "daemon", false, "keep running and check the list periodically")
+	Flags.DurationVar(&intervalFlag, "interval", 5*time.Minute, "polling interval for the daemon mode")
+	Flags.DurationVar(&timeoutFlag, "timeout", 30*time.Second, "timeout of fetching the list")
+	Flags.StringVar(&listenFlag, "listen", "", "serve prometheus metrics and health endpoints at the given address, implies -daemon")
+	Flags.StringVar(&alertRulesFlag, "alert-rules", "", "evaluate alert rules of the YAML file against exported metrics, implies -daemon")
+	logOptions.RegisterFlags(Flags)
+	Flags.StringVar(&influxMeasurementFlag, "influx-measurement", "benchmark_list", "measurement name of points")
+	sinkOptions.RegisterFlags(Flags)
+}
+
+// Run parses the arguments and runs the tool,
+// the process exits on failures.
+func Run(args []string) {
+	Flags.Parse(args)
+
+	var err error
+	logger, err = logOptions.New()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot create logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer logger.Sync()
+
+	if err := sinkOptions.Validate(); err != nil {
+		logger.Error("invalid output flags", zap.Error(err))
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := &checker{url: urlFlag, client: &http.Client{Timeout: timeoutFlag}}
+
+	tool := &daemon.Tool{
+		Name:       "bench-mon",
+		Logger:     logger,
+		Health:     selfHealth,
+		Daemon:     daemonFlag,
+		Interval:   intervalFlag,
+		Timeout:    timeoutFlag,
+		Listen:     listenFlag,
+		AlertRules: alertRulesFlag,
+	}
+
+	backends := sinkOptions.Outputs(tool.Exporter())
+	err = tool.Run(ctx, func(ctx context.Context) error {
+		return poll(ctx, c, backends)
+	})
+	if err != nil {
+		logger.Error("run failed", zap.Error(err))
+		logger.Sync()
+		os.Exit(1)
+	}
+}
+
+// poll checks the list once and writes results, the poll fails
+// if the list is unreachable, malformed or stale, so does the
+// one-shot run, or if outputs failed.
+func poll(ctx context.Context, c *checker, backends []sink.Sink) error {
+	result := c.check(ctx)
+
+	err := sink.WriteAll(backends, influxPoints(result))
+
+	switch {
+	case result.err != nil:
+		return fmt.Errorf("cannot fetch benchmark list: %v", result.err)
+	case len(result.problems) > 0:
+		return fmt.Errorf("benchmark list is malformed: %s", strings.Join(result.problems, "; "))
+	case result.stale():
+		return fmt.Errorf("benchmark list was last modified %s ago", result.age().Round(time.Second))
+	}
+
+	return err
+}
@@ -0,0 +1,85 @@
+package chainmon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const alertTimeout = 10 * time.Second
+
+// chainAlert is the webhook payload.
+type chainAlert struct {
+	Chain     string    `json:"chain"`
+	Time      time.Time `json:"time"`
+	Reasons   []string  `json:"reasons"`
+	Height    uint64    `json:"height"`
+	HeadTime  time.Time `json:"head_time"`
+	Recovered bool      `json:"recovered,omitempty"`
+}
+
+// alertReasons explains why the chain is considered stalled.
+func alertReasons(st *chainStats) []string {
+	var reasons []string
+	if age := st.headAge(); age > stallFlag {
+		reasons = append(reasons, fmt.Sprintf("no blocks for %s, the last one is #%d", age.Truncate(time.Second), st.height))
+	}
+
+	if maxLagFlag > 0 && st.masterHeight > 0 && st.lag > maxLagFlag {
+		reasons = append(reasons, fmt.Sprintf("head is %s behind the masterchain", st.lag.Truncate(time.Second)))
+	}
+
+	return reasons
+}
+
+// stallAlerter posts an alert when the chain stalls and
+// another one when it produces blocks again.
+type stallAlerter struct {
+	stalled bool
+}
+
+func (a *stallAlerter) notify(st *chainStats) error {
+	reasons := alertReasons(st)
+	for _, r := range reasons {
+		logger.Warn("chain is degraded", zap.String("chain", st.chain), zap.String("reason", r))
+	}
+
+	stalled := len(reasons) > 0
+	if stalled == a.stalled {
+		return nil
+	}
+
+	a.stalled = stalled
+	return postAlert(chainAlert{
+		Chain:     st.chain,
+		Time:      st.time,
+		Reasons:   reasons,
+		Height:    st.height,
+		HeadTime:  st.headTime,
+		Recovered: !stalled,
+	})
+}
+
+func postAlert(alert chainAlert) error {
+	b, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: alertTimeout}
+	resp, err := client.Post(alertURLFlag, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package chainmon
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// chain is a JSON-RPC connection to an ethereum-compatible chain.
+type chain struct {
+	name   string
+	client *ethclient.Client
+}
+
+func dialChain(name, url string) (*chain, error) {
+	client, err := ethclient.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chain{name: name, client: client}, nil
+}
+
+func (c *chain) Close() {
+	c.client.Close()
+}
+
+// chainStats is a single sample of the chain head.
+type chainStats struct {
+	chain    string
+	time     time.Time
+	height   uint64
+	headTime time.Time
+	// blockTime is averaged over -block-window blocks.
+	blockTime time.Duration
+	// lag is how far the head is behind the masterchain head,
+	// masterHeight is zero if the masterchain is not polled.
+	lag          time.Duration
+	masterHeight uint64
+}
+
+// headAge is how long ago the newest block has been produced.
+func (s *chainStats) headAge() time.Duration {
+	return s.time.Sub(s.headTime)
+}
+
+func (c *chain) sample(ctx context.Context, now time.Time) (*chainStats, error) {
+	head, err := c.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get %s head: %v", c.name, err)
+	}
+
+	st := &chainStats{
+		chain:    c.name,
+		time:     now,
+		height:   head.Number.Uint64(),
+		headTime: time.Unix(head.Time.Int64(), 0),
+	}
+
+	window := blockWindowFlag
+	if window > st.height {
+		window = st.height
+	}
+	if window == 0 {
+		return st, nil
+	}
+
+	past, err := c.client.HeaderByNumber(ctx, big.NewInt(0).Sub(head.Number, big.NewInt(int64(window))))
+	if err != nil {
+		return nil, fmt.Errorf("cannot get %s block: %v", c.name, err)
+	}
+
+	st.blockTime = st.headTime.Sub(time.Unix(past.Time.Int64(), 0)) / time.Duration(window)
+	return st, nil
+}
@@ -0,0 +1,31 @@
+package chainmon
+
+import "github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+
+// influxPoints returns the point of the polled chain.
+func influxPoints(st *chainStats) []sink.Point {
+	return []sink.Point{{
+		Measurement: influxMeasurementFlag,
+		Tags:        map[string]string{"chain": st.chain},
+		Fields:      chainFields(st),
+		Time:        st.time,
+	}}
+}
+
+// chainFields are fields of the line or point, the lag
+// is reported only if the masterchain is polled.
+func chainFields(st *chainStats) map[string]interface{} {
+	fields := map[string]interface{}{
+		"height":       st.height,
+		"head_age_s":   st.headAge().Seconds(),
+		"block_time_s": st.blockTime.Seconds(),
+		"stalled":      len(alertReasons(st)) > 0,
+	}
+
+	if st.masterHeight > 0 {
+		fields["master_height"] = st.masterHeight
+		fields["lag_s"] = st.lag.Seconds()
+	}
+
+	return fields
+}
@@ -0,0 +1,166 @@
+// Package chainmon tracks block production of the SONM sidechain.
+package chainmon
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/daemon"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/health"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/logging"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+	"go.uber.org/zap"
+)
+
+var (
+	sidechainFlag   string
+	masterchainFlag string
+	daemonFlag      bool
+	intervalFlag    time.Duration
+	timeoutFlag     time.Duration
+	blockWindowFlag uint64
+	stallFlag       time.Duration
+	maxLagFlag      time.Duration
+	alertURLFlag    string
+	listenFlag      string
+	alertRulesFlag  string
+
+	influxMeasurementFlag string
+)
+
+// Flags are command line flags of the tool, parsed by Run.
+var Flags = flag.NewFlagSet("sonm-mon chain", flag.ExitOnError)
+
+// logger is used for diagnostics, results printed by
+// console sinks do not go through it.
+var logger = zap.NewNop()
+
+// logOptions are set by the -log flags.
+var logOptions = logging.Options{Name: "chain-mon"}
+
+// sinkOptions configure metrics backends, see the sink package.
+var sinkOptions = sink.Options{InfluxDatabase: "sonm", StatsdPrefix: "sonm", PushJob: "chain_mon"}
+
+// selfHealth tracks polls of the daemon, so the monitor can be monitored.
+var selfHealth = health.New("chain-mon")
+
+func init() {
+	Flags.StringVar(&sidechainFlag, "sidechain", "https://sidechain.livenet.sonm.com", "sidechain JSON-RPC endpoint")
+	Flags.StringVar(&masterchainFlag, "masterchain", "https://mainnet.infura.io", "masterchain JSON-RPC endpoint to measure the lag against, empty to disable")
+	Flags.BoolVar(&daemonFlag, "daemon", false, "keep running and poll chains periodically")
+	Flags.DurationVar(&intervalFlag, "interval", 30*time.Second, "polling interval for the daemon mode")
+	Flags.DurationVar(&timeoutFlag, "timeout", 30*time.Second, "how long polling a chain may take")
+	Flags.Uint64Var(&blockWindowFlag, "block-window", 20, "number of latest blocks to average the block time over")
+	Flags.DurationVar(&stallFlag, "stall", 5*time.Minute, "alert when the newest sidechain block is older")
+	Flags.DurationVar(&maxLagFlag, "max-lag", 0, "alert when the sidechain head is behind the masterchain head by more, 0 to disable")
+	Flags.StringVar(&alertURLFlag, "alert-url", "", "URL to POST a JSON alert to when the sidechain stalls or recovers")
+	Flags.StringVar(&listenFlag, "listen", "", "serve prometheus metrics and health endpoints at the given address, implies -daemon")
+	Flags.StringVar(&alertRulesFlag, "alert-rules", "", "evaluate alert rules of the YAML file against exported metrics, implies -daemon")
+	logOptions.RegisterFlags(Flags)
+	Flags.StringVar(&influxMeasurementFlag, "influx-measurement", "chain", "measurement name of points")
+	sinkOptions.RegisterFlags(Flags)
+}
+
+// Run parses the arguments and runs the tool,
+// the process exits on failures.
+func Run(args []string) {
+	Flags.Parse(args)
+
+	if blockWindowFlag == 0 {
+		fmt.Fprintln(os.Stderr, "block window cannot be zero")
+		os.Exit(1)
+	}
+
+	var err error
+	logger, err = logOptions.New()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot create logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer logger.Sync()
+
+	if err := sinkOptions.Validate(); err != nil {
+		logger.Error("invalid output flags", zap.Error(err))
+		os.Exit(1)
+	}
+
+	side, err := dialChain("sidechain", sidechainFlag)
+	if err != nil {
+		logger.Error("cannot connect to sidechain", zap.Error(err))
+		os.Exit(1)
+	}
+	defer side.Close()
+
+	var master *chain
+	if len(masterchainFlag) > 0 {
+		if master, err = dialChain("masterchain", masterchainFlag); err != nil {
+			logger.Error("cannot connect to masterchain", zap.Error(err))
+			os.Exit(1)
+		}
+		defer master.Close()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tool := &daemon.Tool{
+		Name:       "chain-mon",
+		Logger:     logger,
+		Health:     selfHealth,
+		Daemon:     daemonFlag,
+		Interval:   intervalFlag,
+		Timeout:    timeoutFlag,
+		Listen:     listenFlag,
+		AlertRules: alertRulesFlag,
+	}
+
+	// alerts are posted besides writing to other outputs
+	var alerter *stallAlerter
+	if len(alertURLFlag) > 0 {
+		alerter = &stallAlerter{}
+	}
+
+	backends := sinkOptions.Outputs(tool.Exporter())
+	err = tool.Run(ctx, func(ctx context.Context) error {
+		return poll(ctx, side, master, alerter, backends)
+	})
+	if err != nil {
+		logger.Error("run failed", zap.Error(err))
+		logger.Sync()
+		os.Exit(1)
+	}
+}
+
+// poll samples heads of both chains, the sidechain result is written
+// even if the masterchain cannot be queried, only without the lag.
+func poll(ctx context.Context, side, master *chain, alerter *stallAlerter, backends []sink.Sink) error {
+	ctx, cancel := context.WithTimeout(ctx, timeoutFlag)
+	defer cancel()
+
+	now := time.Now()
+	st, err := side.sample(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	if master != nil {
+		mst, err := master.sample(ctx, now)
+		if err != nil {
+			logger.Warn("cannot query masterchain", zap.Error(err))
+		} else {
+			st.lag = mst.headTime.Sub(st.headTime)
+			st.masterHeight = mst.height
+		}
+	}
+
+	if alerter != nil {
+		if err := alerter.notify(st); err != nil {
+			logger.Warn("cannot post alert", zap.Error(err))
+		}
+	}
+
+	return sink.WriteAll(backends, influxPoints(st))
+}
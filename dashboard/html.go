@@ -0,0 +1,75 @@
+package dashboard
+
+// indexHTML is the whole UI, it polls the API and draws
+// a tile with a sparkline of the history per value.
+const indexHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>SONM network</title>
+<style>
+body { margin: 0; padding: 16px; background: #111; color: #ddd; font-family: sans-serif; }
+h1 { font-size: 20px; font-weight: normal; margin: 0 0 12px; }
+#updated, #sources { font-size: 12px; color: #888; margin-bottom: 12px; }
+.bad { color: #e55; }
+#tiles { display: grid; grid-template-columns: repeat(auto-fill, minmax(220px, 1fr)); grid-gap: 12px; }
+.tile { background: #222; border-left: 6px solid #4a4; padding: 12px; }
+.tile.alert { border-color: #e55; }
+.tile.nodata { border-color: #555; color: #777; }
+.name { font-size: 13px; color: #aaa; }
+.value { font-size: 32px; margin: 6px 0; }
+svg { width: 100%; height: 40px; }
+polyline { fill: none; stroke: #6af; stroke-width: 1.5; }
+</style>
+</head>
+<body>
+<h1>SONM network</h1>
+<div id="updated">loading...</div>
+<div id="sources"></div>
+<div id="tiles"></div>
+<script>
+function format(v) {
+  if (Math.abs(v) >= 1000) return Math.round(v).toLocaleString();
+  return (Math.round(v * 100) / 100).toString();
+}
+
+function sparkline(points) {
+  if (points.length < 2) return '';
+  var min = Math.min.apply(null, points), max = Math.max.apply(null, points);
+  var span = max - min || 1;
+  var coords = points.map(function(v, i) {
+    return (i * 100 / (points.length - 1)).toFixed(2) + ',' + (38 - (v - min) * 36 / span).toFixed(2);
+  });
+  return '<svg viewBox="0 0 100 40" preserveAspectRatio="none"><polyline points="' + coords.join(' ') + '"/></svg>';
+}
+
+function render(status, history) {
+  document.getElementById('updated').textContent = 'updated ' + new Date(status.time).toLocaleString();
+  document.getElementById('sources').innerHTML = status.sources.map(function(s) {
+    return s.ok ? s.url : '<span class="bad" title="' + s.error + '">' + s.url + '</span>';
+  }).join(' &middot; ');
+  document.getElementById('tiles').innerHTML = status.tiles.map(function(t) {
+    var points = history.filter(function(p) { return t.name in p.values; }).map(function(p) { return p.values[t.name]; });
+    var value = t.status === 'nodata' ? 'no data' : format(t.value) + (t.unit ? ' ' + t.unit : '');
+    return '<div class="tile ' + t.status + '"><div class="name">' + t.name + '</div>' +
+      '<div class="value">' + value + '</div>' + sparkline(points) + '</div>';
+  }).join('');
+}
+
+function refresh() {
+  Promise.all([fetch('api/status'), fetch('api/history')]).then(function(responses) {
+    if (!responses[0].ok) throw new Error('no data yet');
+    return Promise.all(responses.map(function(r) { return r.json(); }));
+  }).then(function(docs) {
+    render(docs[0], docs[1]);
+  }).catch(function(err) {
+    document.getElementById('updated').textContent = err.message;
+  });
+}
+
+refresh();
+setInterval(refresh, 15000);
+</script>
+</body>
+</html>
+`
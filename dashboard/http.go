@@ -0,0 +1,64 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+func serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(indexHTML))
+}
+
+func serveStatus(c *collector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snap := c.latest()
+		if snap == nil {
+			http.Error(w, "no data yet", http.StatusServiceUnavailable)
+			return
+		}
+
+		writeJSON(w, snap)
+	}
+}
+
+// historyPoint is a snapshot reduced to tile values for charts.
+type historyPoint struct {
+	Time   time.Time          `json:"time"`
+	Values map[string]float64 `json:"values"`
+}
+
+func serveHistory(c *collector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		points := []historyPoint{}
+		for _, snap := range c.all() {
+			p := historyPoint{Time: snap.Time, Values: map[string]float64{}}
+			for _, t := range snap.Tiles {
+				if t.Status != statusNoData {
+					p.Values[t.Name] = t.Value
+				}
+			}
+
+			points = append(points, p)
+		}
+
+		writeJSON(w, points)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,109 @@
+// Package dashboard aggregates metrics exported by the other
+// tools and serves them as status tiles with history charts.
+package dashboard
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/health"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/logging"
+	"go.uber.org/zap"
+)
+
+var (
+	listenFlag   string
+	metricsFlag  string
+	mapURLFlag   string
+	intervalFlag time.Duration
+	historyFlag  time.Duration
+	timeoutFlag  time.Duration
+)
+
+// Flags are command line flags of the tool, parsed by Run.
+var Flags = flag.NewFlagSet("sonm-mon dashboard", flag.ExitOnError)
+
+// logger is used for diagnostics, results printed by
+// console sinks do not go through it.
+var logger = zap.NewNop()
+
+// logOptions are set by the -log flags.
+var logOptions = logging.Options{Name: "dashboard"}
+
+// selfHealth is ready once sources are scraped.
+var selfHealth = health.New("dashboard")
+
+func init() {
+	Flags.StringVar(&listenFlag, "listen", ":8095", "address to serve the dashboard at")
+	Flags.StringVar(&metricsFlag, "metrics", "", "comma-separated /metrics URLs of the monitors started with -listen")
+	Flags.StringVar(&mapURLFlag, "map-url", "", "map-proxy URL serving supplier points, e.g. http://localhost:8090/")
+	Flags.DurationVar(&intervalFlag, "interval", 30*time.Second, "how often sources are scraped")
+	Flags.DurationVar(&historyFlag, "history", 24*time.Hour, "how long snapshots are kept for charts")
+	Flags.DurationVar(&timeoutFlag, "timeout", 10*time.Second, "timeout of a single scrape")
+	logOptions.RegisterFlags(Flags)
+}
+
+// Run parses the arguments and runs the tool,
+// the process exits on failures.
+func Run(args []string) {
+	Flags.Parse(args)
+
+	var err error
+	logger, err = logOptions.New()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot create logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer logger.Sync()
+
+	var sources []string
+	for _, u := range strings.Split(metricsFlag, ",") {
+		if u = strings.TrimSpace(u); len(u) > 0 {
+			sources = append(sources, u)
+		}
+	}
+
+	if len(sources) == 0 && len(mapURLFlag) == 0 {
+		logger.Error("nothing to show, set -metrics or -map-url")
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		logger.Info("shutting down", zap.Stringer("signal", <-sigs))
+		cancel()
+	}()
+
+	c := newCollector(sources, mapURLFlag, timeoutFlag, historyFlag)
+	go c.run(ctx, intervalFlag)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", serveIndex)
+	mux.HandleFunc("/api/status", serveStatus(c))
+	mux.HandleFunc("/api/history", serveHistory(c))
+	selfHealth.SetStaleAfter(3*intervalFlag + timeoutFlag)
+	selfHealth.Register(mux)
+
+	srv := &http.Server{Addr: listenFlag, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	logger.Info("serving dashboard", zap.String("addr", listenFlag))
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Error("cannot serve dashboard", zap.Error(err))
+		os.Exit(1)
+	}
+}
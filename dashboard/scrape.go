@@ -0,0 +1,234 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"go.uber.org/zap"
+)
+
+// sample is a single series of a metric.
+type sample struct {
+	labels map[string]string
+	value  float64
+}
+
+// metrics are samples of all sources keyed by the metric name.
+type metrics map[string][]sample
+
+// sourceStatus tells whether the last scrape of the source succeeded.
+type sourceStatus struct {
+	URL   string `json:"url"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// snapshot is the state of tiles at the time.
+type snapshot struct {
+	Time    time.Time      `json:"time"`
+	Sources []sourceStatus `json:"sources"`
+	Tiles   []tile         `json:"tiles"`
+}
+
+// collector scrapes sources periodically and keeps snapshots
+// for the history period.
+type collector struct {
+	sources []string
+	mapURL  string
+	client  *http.Client
+	history time.Duration
+
+	mu        sync.Mutex
+	snapshots []*snapshot
+}
+
+func newCollector(sources []string, mapURL string, timeout, history time.Duration) *collector {
+	return &collector{
+		sources: sources,
+		mapURL:  mapURL,
+		client:  &http.Client{Timeout: timeout},
+		history: history,
+	}
+}
+
+func (c *collector) run(ctx context.Context, interval time.Duration) {
+	c.collect(ctx)
+
+	tk := time.NewTicker(interval)
+	defer tk.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tk.C:
+			c.collect(ctx)
+		}
+	}
+}
+
+func (c *collector) collect(ctx context.Context) {
+	now := time.Now()
+	m := metrics{}
+	snap := &snapshot{Time: now}
+
+	for _, url := range c.sources {
+		err := c.scrape(ctx, url, m)
+		snap.Sources = append(snap.Sources, newSourceStatus(url, err))
+	}
+
+	if len(c.mapURL) > 0 {
+		err := c.scrapeMap(ctx, m)
+		snap.Sources = append(snap.Sources, newSourceStatus(c.mapURL, err))
+	}
+
+	snap.Tiles = evaluateTiles(m)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.snapshots = append(c.snapshots, snap)
+	for len(c.snapshots) > 0 && now.Sub(c.snapshots[0].Time) > c.history {
+		c.snapshots = c.snapshots[1:]
+	}
+
+	// failed sources are shown on tiles, the dashboard is
+	// unhealthy only if it stops collecting altogether
+	selfHealth.Record(nil)
+}
+
+func newSourceStatus(url string, err error) sourceStatus {
+	if err != nil {
+		logger.Warn("cannot scrape source", zap.String("url", url), zap.Error(err))
+		return sourceStatus{URL: url, Error: err.Error()}
+	}
+
+	return sourceStatus{URL: url, OK: true}
+}
+
+func (c *collector) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return resp, nil
+}
+
+// scrape adds samples of the prometheus text exposition to the metrics.
+func (c *collector) scrape(ctx context.Context, url string, m metrics) error {
+	resp, err := c.get(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	for name, f := range families {
+		for _, metric := range f.GetMetric() {
+			if v, ok := metricValue(metric); ok {
+				m[name] = append(m[name], sample{labels: labelMap(metric), value: v})
+			}
+		}
+	}
+
+	return nil
+}
+
+// mapPoint is the part of map-proxy points shown on the dashboard.
+type mapPoint struct {
+	Count    int    `json:"count"`
+	GPUCount uint64 `json:"gpu_count"`
+	CPUCount uint64 `json:"cpu_count"`
+}
+
+// scrapeMap adds map_* metrics summarizing the map snapshot.
+func (c *collector) scrapeMap(ctx context.Context, m metrics) error {
+	resp, err := c.get(ctx, c.mapURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var points []mapPoint
+	if err := json.NewDecoder(resp.Body).Decode(&points); err != nil {
+		return err
+	}
+
+	suppliers, gpus, cpus := 0.0, 0.0, 0.0
+	for _, p := range points {
+		suppliers += float64(p.Count)
+		gpus += float64(p.GPUCount)
+		cpus += float64(p.CPUCount)
+	}
+
+	m["map_locations"] = []sample{{value: float64(len(points))}}
+	m["map_suppliers"] = []sample{{value: suppliers}}
+	m["map_gpus"] = []sample{{value: gpus}}
+	m["map_cpus"] = []sample{{value: cpus}}
+
+	return nil
+}
+
+func labelMap(m *dto.Metric) map[string]string {
+	labels := map[string]string{}
+	for _, l := range m.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+
+	return labels
+}
+
+func metricValue(m *dto.Metric) (float64, bool) {
+	switch {
+	case m.Gauge != nil:
+		return m.GetGauge().GetValue(), true
+	case m.Counter != nil:
+		return m.GetCounter().GetValue(), true
+	case m.Untyped != nil:
+		return m.GetUntyped().GetValue(), true
+	}
+
+	return 0, false
+}
+
+// latest returns the newest snapshot, nil before the first scrape.
+func (c *collector) latest() *snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.snapshots) == 0 {
+		return nil
+	}
+
+	return c.snapshots[len(c.snapshots)-1]
+}
+
+// all returns snapshots of the history period, oldest first.
+func (c *collector) all() []*snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]*snapshot(nil), c.snapshots...)
+}
@@ -0,0 +1,141 @@
+package dashboard
+
+// Tile statuses, nodata means no source exports the metric.
+const (
+	statusOK     = "ok"
+	statusAlert  = "alert"
+	statusNoData = "nodata"
+)
+
+// tile is a single number shown on the dashboard.
+type tile struct {
+	Name   string  `json:"name"`
+	Value  float64 `json:"value"`
+	Unit   string  `json:"unit,omitempty"`
+	Status string  `json:"status"`
+}
+
+// tileDef computes a tile from scraped metrics.
+type tileDef struct {
+	name string
+	unit string
+	// value returns false if there is no data.
+	value func(m metrics) (float64, bool)
+	// ok tells whether the value is healthy, nil means always.
+	ok func(v float64) bool
+}
+
+// tileDefs use names of points exported with default measurements,
+// "<measurement>_<field>", see sink.Exporter.
+var tileDefs = []tileDef{
+	{name: "Map suppliers", value: sum("map_suppliers", nil), ok: above(0)},
+	{name: "Map GPUs", value: sum("map_gpus", nil)},
+	// every rendezvous server knows the whole network
+	{name: "RV peers", value: max("map_data_totals_endpoints", nil), ok: above(0)},
+	{name: "RV wallets", value: max("map_data_totals_wallets", nil)},
+	{name: "Relays", value: count("relay_members_conn_count", nil)},
+	{name: "Relays not serving", value: count("relay_health_serving", func(v float64) bool { return v == 0 }), ok: equal(0)},
+	{name: "Relay connections", value: sum("relay_members_conn_count", nil)},
+	{name: "DWH latency", unit: "ms", value: max("dwh_latency_ms", nil), ok: below(5000)},
+	{name: "DWH failing queries", value: count("dwh_ok", func(v float64) bool { return v == 0 }), ok: equal(0)},
+	{name: "Sidechain head age", unit: "s", value: max("chain_head_age_s", map[string]string{"chain": "sidechain"})},
+	{name: "Chains stalled", value: sum("chain_stalled", nil), ok: equal(0)},
+	{name: "Workers up", value: sum("worker_reachable", nil), ok: above(0)},
+	{name: "Active asks", value: sum("market_orders", map[string]string{"side": "ASK"})},
+	{name: "Active bids", value: sum("market_orders", map[string]string{"side": "BID"})},
+	{name: "SNM price", unit: "USD", value: max("snm_price_usd", map[string]string{"source": "median"})},
+}
+
+func evaluateTiles(m metrics) []tile {
+	var tiles []tile
+	for _, d := range tileDefs {
+		t := tile{Name: d.name, Unit: d.unit, Status: statusNoData}
+		if v, ok := d.value(m); ok {
+			t.Value, t.Status = v, statusOK
+			if d.ok != nil && !d.ok(v) {
+				t.Status = statusAlert
+			}
+		}
+
+		tiles = append(tiles, t)
+	}
+
+	return tiles
+}
+
+// series returns samples of the metric having the labels.
+func series(m metrics, name string, labels map[string]string) []sample {
+	var matched []sample
+	for _, s := range m[name] {
+		ok := true
+		for k, v := range labels {
+			if s.labels[k] != v {
+				ok = false
+				break
+			}
+		}
+
+		if ok {
+			matched = append(matched, s)
+		}
+	}
+
+	return matched
+}
+
+func sum(name string, labels map[string]string) func(m metrics) (float64, bool) {
+	return func(m metrics) (float64, bool) {
+		samples := series(m, name, labels)
+		total := 0.0
+		for _, s := range samples {
+			total += s.value
+		}
+
+		return total, len(samples) > 0
+	}
+}
+
+func max(name string, labels map[string]string) func(m metrics) (float64, bool) {
+	return func(m metrics) (float64, bool) {
+		samples := series(m, name, labels)
+		if len(samples) == 0 {
+			return 0, false
+		}
+
+		v := samples[0].value
+		for _, s := range samples[1:] {
+			if s.value > v {
+				v = s.value
+			}
+		}
+
+		return v, true
+	}
+}
+
+// count returns the number of series matching the filter, nil matches all.
+func count(name string, filter func(v float64) bool) func(m metrics) (float64, bool) {
+	return func(m metrics) (float64, bool) {
+		samples := m[name]
+		n := 0
+		for _, s := range samples {
+			if filter == nil || filter(s.value) {
+				n++
+			}
+		}
+
+		return float64(n), len(samples) > 0
+	}
+}
+
+func above(threshold float64) func(v float64) bool {
+	return func(v float64) bool { return v > threshold }
+}
+
+func below(threshold float64) func(v float64) bool {
+	return func(v float64) bool { return v < threshold }
+}
+
+func equal(want float64) func(v float64) bool {
+	return func(v float64) bool { return v == want }
+}
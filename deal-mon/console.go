@@ -0,0 +1,18 @@
+package dealmon
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// writeJSON prints an event per line to stdout.
+func writeJSON(events []dealEvent) error {
+	enc := json.NewEncoder(os.Stdout)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
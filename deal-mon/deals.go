@@ -0,0 +1,196 @@
+package dealmon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/sonm-io/core/proto"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sonmclient"
+	"go.uber.org/zap"
+)
+
+// Event names.
+const (
+	dealOpened = "deal_opened"
+	dealClosed = "deal_closed"
+)
+
+// dealInfo is the part of the deal reported in events.
+type dealInfo struct {
+	ID       string `json:"id"`
+	Supplier string `json:"supplier"`
+	Consumer string `json:"consumer"`
+	// Price is in SNM per hour.
+	Price     float64   `json:"price_per_hour"`
+	GPUs      uint64    `json:"gpus"`
+	CPUCores  uint64    `json:"cpu_cores"`
+	StartTime time.Time `json:"start_time"`
+}
+
+// dealEvent is a deal opened or closed since the previous poll.
+type dealEvent struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	dealInfo
+	// Duration is how long the closed deal has been open, in seconds.
+	Duration float64 `json:"duration_s,omitempty"`
+}
+
+func newDealInfo(d *sonm.Deal) dealInfo {
+	b := d.GetBenchmarks()
+	return dealInfo{
+		ID:        d.GetId().Unwrap().String(),
+		Supplier:  d.GetSupplierID().Unwrap().Hex(),
+		Consumer:  d.GetConsumerID().Unwrap().Hex(),
+		Price:     perHour(d.GetPrice().Unwrap()),
+		GPUs:      b.GPUCount(),
+		CPUCores:  b.CPUCores(),
+		StartTime: d.GetStartTime().Unix(),
+	}
+}
+
+// perHour converts the price in wei per second to SNM per hour.
+func perHour(price *big.Int) float64 {
+	if price == nil {
+		return 0
+	}
+
+	perHour := big.NewFloat(0).SetInt(big.NewInt(0).Mul(price, big.NewInt(3600)))
+	v, _ := big.NewFloat(0).Quo(perHour, big.NewFloat(params.Ether)).Float64()
+	return v
+}
+
+// tracker remembers open deals to tell which ones have been opened
+// and closed between polls. Nothing is reported until the first set
+// of deals is known, either loaded from the state file or polled.
+type tracker struct {
+	dwh  sonm.DWHClient
+	opts sonmclient.Options
+	path string
+
+	deals map[string]dealInfo
+	known bool
+}
+
+func newTracker(conn *sonmclient.Conn, opts sonmclient.Options, path string) (*tracker, error) {
+	t := &tracker{dwh: sonm.NewDWHClient(conn.ClientConn), opts: opts, path: path, deals: map[string]dealInfo{}}
+	if len(path) == 0 {
+		return t, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &t.deals); err != nil {
+		return nil, fmt.Errorf("cannot parse state file: %v", err)
+	}
+
+	t.known = true
+	return t, nil
+}
+
+// load returns open deals keyed by ID, they are complete
+// unless there are more than -max-deals of them.
+func (t *tracker) load(ctx context.Context) (map[string]dealInfo, bool, error) {
+	deals := map[string]dealInfo{}
+	for offset := uint64(0); offset < maxDealsFlag; offset += pageSizeFlag {
+		var reply *sonm.DWHDealsReply
+		err := t.opts.Retry(ctx, fmt.Sprintf("deals at %d", offset), func() error {
+			var err error
+			reply, err = t.dwh.GetDeals(ctx, &sonm.DealsRequest{
+				Status: sonm.DealStatus_DEAL_ACCEPTED,
+				Limit:  pageSizeFlag,
+				Offset: offset,
+			})
+			return err
+		})
+		if err != nil {
+			return nil, false, fmt.Errorf("cannot load deals: %v", err)
+		}
+
+		for _, d := range reply.GetDeals() {
+			info := newDealInfo(d.GetDeal())
+			deals[info.ID] = info
+		}
+
+		if uint64(len(reply.GetDeals())) < pageSizeFlag {
+			return deals, true, nil
+		}
+	}
+
+	logger.Warn("too many open deals, closed ones are not reported", zap.Uint64("max", maxDealsFlag))
+	return deals, false, nil
+}
+
+// diff returns events ordered by the deal ID, deals absent from
+// an incomplete set are not reported as closed.
+func (t *tracker) diff(deals map[string]dealInfo, complete bool, now time.Time) []dealEvent {
+	if !t.known {
+		logger.Info("remembering open deals", zap.Int("count", len(deals)))
+		return nil
+	}
+
+	var events []dealEvent
+	for id, d := range deals {
+		if _, ok := t.deals[id]; !ok {
+			events = append(events, dealEvent{Event: dealOpened, Time: now, dealInfo: d})
+		}
+	}
+
+	if complete {
+		for id, d := range t.deals {
+			if _, ok := deals[id]; !ok {
+				events = append(events, dealEvent{Event: dealClosed, Time: now, dealInfo: d, Duration: now.Sub(d.StartTime).Seconds()})
+			}
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ID < events[j].ID
+	})
+
+	return events
+}
+
+// commit remembers deals reported by the poll and saves them
+// to the state file if there is one.
+func (t *tracker) commit(deals map[string]dealInfo, complete bool) error {
+	if complete {
+		t.deals = deals
+	} else {
+		for id, d := range deals {
+			t.deals[id] = d
+		}
+	}
+	t.known = true
+
+	if len(t.path) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(t.deals)
+	if err != nil {
+		return err
+	}
+
+	// the state is replaced atomically, so a crash
+	// does not leave a truncated file behind
+	tmp := t.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("cannot save state: %v", err)
+	}
+
+	return os.Rename(tmp, t.path)
+}
@@ -0,0 +1,27 @@
+package dealmon
+
+import "github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+
+// influxPoints returns a point per event tagged by the event name,
+// counterparties are fields, so they do not blow up series cardinality.
+func influxPoints(events []dealEvent) []sink.Point {
+	var points []sink.Point
+	for _, e := range events {
+		points = append(points, sink.Point{
+			Measurement: influxMeasurementFlag,
+			Tags:        map[string]string{"event": e.Event},
+			Fields: map[string]interface{}{
+				"id":             e.ID,
+				"supplier":       e.Supplier,
+				"consumer":       e.Consumer,
+				"price_per_hour": e.Price,
+				"gpus":           e.GPUs,
+				"cpu_cores":      e.CPUCores,
+				"duration_s":     e.Duration,
+			},
+			Time: e.Time,
+		})
+	}
+
+	return points
+}
@@ -0,0 +1,62 @@
+package dealmon
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Shopify/sarama"
+)
+
+// kafkaSink publishes every event as JSON, events are keyed by
+// the deal ID, so opening and closing of a deal stay ordered.
+type kafkaSink struct {
+	producer sarama.SyncProducer
+	topic    string
+}
+
+func newKafkaSink(brokers, topic string) (*kafkaSink, error) {
+	cfg := sarama.NewConfig()
+	cfg.ClientID = "deal-mon"
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.Retry.Max = int(retriesFlag)
+
+	producer, err := sarama.NewSyncProducer(strings.Split(brokers, ","), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create kafka producer: %v", err)
+	}
+
+	return &kafkaSink{producer: producer, topic: topic}, nil
+}
+
+func (k *kafkaSink) Write(events []dealEvent) error {
+	var messages []*sarama.ProducerMessage
+	for _, e := range events {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		messages = append(messages, &sarama.ProducerMessage{
+			Topic:     k.topic,
+			Key:       sarama.StringEncoder(e.ID),
+			Value:     sarama.ByteEncoder(b),
+			Timestamp: e.Time,
+		})
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	if err := k.producer.SendMessages(messages); err != nil {
+		return fmt.Errorf("cannot publish to kafka: %v", err)
+	}
+
+	return nil
+}
+
+func (k *kafkaSink) Close() error {
+	return k.producer.Close()
+}
@@ -0,0 +1,178 @@
+// Package dealmon reports deals opened and closed on the SONM marketplace.
+package dealmon
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/daemon"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/logging"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sonmclient"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/tracing"
+	"go.uber.org/zap"
+)
+
+const defaultDWH = "0xadffcac607a0a1b583c489977eae413a62d4bc73@dwh.livenet.sonm.com:15021"
+
+var (
+	dwhAddrFlag      string
+	keyFileFlag      string
+	keyPasswordFlag  string
+	intervalFlag     time.Duration
+	timeoutFlag      time.Duration
+	retriesFlag      uint
+	retryBackoffFlag time.Duration
+	maxDealsFlag     uint64
+	pageSizeFlag     uint64
+	stateFlag        string
+
+	influxMeasurementFlag string
+
+	kafkaBrokersFlag string
+	kafkaTopicFlag   string
+)
+
+// Flags are command line flags of the tool, parsed by Run.
+var Flags = flag.NewFlagSet("sonm-mon deal", flag.ExitOnError)
+
+// logger is used for diagnostics, results printed by
+// console sinks do not go through it.
+var logger = zap.NewNop()
+
+// logOptions are set by the -log flags.
+var logOptions = logging.Options{Name: "deal-mon"}
+
+// sinkOptions configure metrics backends, see the sink package.
+var sinkOptions = sink.Options{InfluxDatabase: "sonm", StatsdPrefix: "sonm", PushJob: "deal_mon"}
+
+// traceOptions configure exporting spans, see the tracing package.
+var traceOptions tracing.Options
+
+func init() {
+	Flags.StringVar(&dwhAddrFlag, "dwh", defaultDWH, "DWH address: 0xEth@ip:port")
+	Flags.StringVar(&keyFileFlag, "key-file", "", "keystore or hex private key file, a new key is generated every run if empty")
+	Flags.StringVar(&keyPasswordFlag, "key-password", "", "keystore password (KEY_PASSWORD)")
+	Flags.DurationVar(&intervalFlag, "interval", 60*time.Second, "how often to compare deals")
+	Flags.DurationVar(&timeoutFlag, "timeout", 120*time.Second, "how long loading all deals may take")
+	Flags.UintVar(&retriesFlag, "retries", 3, "how many times to retry failed DWH requests")
+	Flags.DurationVar(&retryBackoffFlag, "retry-backoff", time.Second, "delay before the first retry, doubled on every next one")
+	Flags.Uint64Var(&maxDealsFlag, "max-deals", 50000, "load at most this many deals, closed deals are not reported when reached")
+	Flags.Uint64Var(&pageSizeFlag, "page-size", 500, "how many deals to request at once")
+	Flags.StringVar(&stateFlag, "state", "", "file to keep open deals between runs in, so deals changed while stopped are reported")
+	logOptions.RegisterFlags(Flags)
+	traceOptions.RegisterFlags(Flags)
+	Flags.StringVar(&influxMeasurementFlag, "influx-measurement", "deal_events", "measurement name of points")
+	sinkOptions.RegisterFlags(Flags)
+	Flags.StringVar(&kafkaBrokersFlag, "kafka-brokers", "", "comma-separated kafka brokers to publish events to")
+	Flags.StringVar(&kafkaTopicFlag, "kafka-topic", "sonm-deals", "kafka topic for events")
+}
+
+// Run parses the arguments and runs the daemon,
+// the process exits on failures.
+func Run(args []string) {
+	Flags.Parse(args)
+
+	if pageSizeFlag == 0 {
+		fmt.Fprintln(os.Stderr, "page size cannot be zero")
+		os.Exit(1)
+	}
+
+	var err error
+	logger, err = logOptions.New()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot create logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer logger.Sync()
+
+	stopTracing, err := traceOptions.Setup("deal-mon")
+	if err != nil {
+		logger.Error("cannot set up tracing", zap.Error(err))
+		os.Exit(1)
+	}
+	defer stopTracing()
+
+	if err := sinkOptions.Validate(); err != nil {
+		logger.Error("invalid output flags", zap.Error(err))
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	id, err := sonmclient.NewIdentity(keyFileFlag, keyPasswordFlag)
+	if err != nil {
+		logger.Error("cannot set up identity", zap.Error(err))
+		os.Exit(1)
+	}
+
+	logger.Info("using identity", zap.String("eth", id.Eth().Hex()))
+
+	opts := sonmclient.Options{DialTimeout: timeoutFlag, Retries: retriesFlag, Backoff: retryBackoffFlag, Logger: logger}
+	conn, err := id.Dial(ctx, dwhAddrFlag, opts)
+	if err != nil {
+		logger.Error("cannot connect to DWH", zap.Error(err))
+		os.Exit(1)
+	}
+	defer conn.ClientConn.Close()
+
+	t, err := newTracker(conn, opts, stateFlag)
+	if err != nil {
+		logger.Error("cannot load state", zap.Error(err))
+		os.Exit(1)
+	}
+
+	sinks, err := outputSinks()
+	if err != nil {
+		logger.Error("cannot create outputs", zap.Error(err))
+		os.Exit(1)
+	}
+	defer closeSinks(sinks)
+
+	tool := &daemon.Tool{Name: "deal-mon", Logger: logger, Daemon: true, Interval: intervalFlag, Timeout: timeoutFlag}
+	err = tool.Run(ctx, func(ctx context.Context) error {
+		return poll(ctx, t, sinks)
+	})
+	if err != nil {
+		logger.Error("run failed", zap.Error(err))
+		logger.Sync()
+		os.Exit(1)
+	}
+}
+
+// poll loads deals and writes events of deals changed since the
+// previous poll, the state is updated only if all sinks succeed,
+// so failed events are emitted again during the next poll.
+func poll(ctx context.Context, t *tracker, sinks []Sink) error {
+	ctx, span := tracing.StartSpan(ctx, "poll")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, timeoutFlag)
+	defer cancel()
+
+	deals, complete, err := t.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	events := t.diff(deals, complete, time.Now())
+	logger.Debug("deals compared", zap.Int("open", len(deals)), zap.Int("events", len(events)))
+
+	failed := 0
+	for _, s := range sinks {
+		if err := s.Write(events); err != nil {
+			logger.Warn("cannot write events", zap.Error(err))
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d outputs failed", failed, len(sinks))
+	}
+
+	return t.commit(deals, complete)
+}
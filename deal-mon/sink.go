@@ -0,0 +1,60 @@
+package dealmon
+
+import (
+	"io"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+)
+
+// Sink outputs events of a single poll, it is
+// called even if there are no events.
+type Sink interface {
+	Write(events []dealEvent) error
+}
+
+// SinkFunc allows using ordinary functions as sinks.
+type SinkFunc func(events []dealEvent) error
+
+func (f SinkFunc) Write(events []dealEvent) error {
+	return f(events)
+}
+
+// outputSinks returns sinks enabled by flags, events
+// are printed to the console if there are no others.
+func outputSinks() ([]Sink, error) {
+	var sinks []Sink
+	for _, b := range sinkOptions.Sinks() {
+		sinks = append(sinks, pointSink(b))
+	}
+
+	if len(kafkaBrokersFlag) > 0 {
+		k, err := newKafkaSink(kafkaBrokersFlag, kafkaTopicFlag)
+		if err != nil {
+			return nil, err
+		}
+
+		sinks = append(sinks, k)
+	}
+
+	if len(sinks) == 0 {
+		sinks = append(sinks, SinkFunc(writeJSON))
+	}
+
+	return sinks, nil
+}
+
+// closeSinks releases connections held by sinks.
+func closeSinks(sinks []Sink) {
+	for _, s := range sinks {
+		if c, ok := s.(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
+
+// pointSink writes events converted to points to the backend.
+func pointSink(backend sink.Sink) Sink {
+	return SinkFunc(func(events []dealEvent) error {
+		return backend.Write(influxPoints(events))
+	})
+}
@@ -0,0 +1,27 @@
+package dwhmon
+
+import "github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+
+// influxPoints returns a point per query, the age is reported
+// only for queries telling about freshness.
+func influxPoints(result *pollResult) []sink.Point {
+	var points []sink.Point
+	for _, q := range result.queries {
+		fields := map[string]interface{}{
+			"ok":         q.err == nil,
+			"latency_ms": sink.Millis(q.latency),
+		}
+		if age := q.age(result.time); age > 0 {
+			fields["age_s"] = age.Seconds()
+		}
+
+		points = append(points, sink.Point{
+			Measurement: influxMeasurementFlag,
+			Tags:        map[string]string{"query": q.name},
+			Fields:      fields,
+			Time:        result.time,
+		})
+	}
+
+	return points
+}
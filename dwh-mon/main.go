@@ -0,0 +1,173 @@
+// Package dwhmon checks availability and data freshness of the SONM DWH.
+package dwhmon
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/daemon"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/health"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/logging"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sonmclient"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/tracing"
+	"go.uber.org/zap"
+)
+
+const defaultDWH = "0xadffcac607a0a1b583c489977eae413a62d4bc73@dwh.livenet.sonm.com:15021"
+
+var (
+	dwhAddrFlag      string
+	keyFileFlag      string
+	keyPasswordFlag  string
+	daemonFlag       bool
+	intervalFlag     time.Duration
+	timeoutFlag      time.Duration
+	retriesFlag      uint
+	retryBackoffFlag time.Duration
+	staleFlag        time.Duration
+	listenFlag       string
+	alertRulesFlag   string
+
+	influxMeasurementFlag string
+)
+
+// Flags are command line flags of the tool, parsed by Run.
+var Flags = flag.NewFlagSet("sonm-mon dwh", flag.ExitOnError)
+
+// logger is used for diagnostics, results printed by
+// console sinks do not go through it.
+var logger = zap.NewNop()
+
+// logOptions are set by the -log flags.
+var logOptions = logging.Options{Name: "dwh-mon"}
+
+// sinkOptions configure metrics backends, see the sink package.
+var sinkOptions = sink.Options{InfluxDatabase: "sonm", StatsdPrefix: "sonm", PushJob: "dwh_mon"}
+
+// selfHealth tracks polls of the daemon, so the monitor can be monitored.
+var selfHealth = health.New("dwh-mon")
+
+// traceOptions configure exporting spans, see the tracing package.
+var traceOptions tracing.Options
+
+func init() {
+	Flags.StringVar(&dwhAddrFlag, "dwh", defaultDWH, "DWH address: 0xEth@ip:port")
+	Flags.StringVar(&keyFileFlag, "key-file", "", "keystore or hex private key file, a new key is generated every run if empty")
+	Flags.StringVar(&keyPasswordFlag, "key-password", "", "keystore password (KEY_PASSWORD)")
+	Flags.BoolVar(&daemonFlag, "daemon", false, "keep running and query the DWH periodically")
+	Flags.DurationVar(&intervalFlag, "interval", 60*time.Second, "polling interval for the daemon mode")
+	Flags.DurationVar(&timeoutFlag, "timeout", 30*time.Second, "how long a single query may take, retries included")
+	Flags.UintVar(&retriesFlag, "retries", 0, "how many times to retry failed queries, errors are counted once per poll anyway")
+	Flags.DurationVar(&retryBackoffFlag, "retry-backoff", time.Second, "delay before the first retry, doubled on every next one")
+	Flags.DurationVar(&staleFlag, "stale", time.Hour, "warn when the newest deal or order is older, 0 to disable")
+	Flags.StringVar(&listenFlag, "listen", "", "serve prometheus metrics and health endpoints at the given address, implies -daemon")
+	Flags.StringVar(&alertRulesFlag, "alert-rules", "", "evaluate alert rules of the YAML file against exported metrics, implies -daemon")
+	logOptions.RegisterFlags(Flags)
+	traceOptions.RegisterFlags(Flags)
+	Flags.StringVar(&influxMeasurementFlag, "influx-measurement", "dwh", "measurement name of points")
+	sinkOptions.RegisterFlags(Flags)
+}
+
+// Run parses the arguments and runs the tool,
+// the process exits on failures.
+func Run(args []string) {
+	Flags.Parse(args)
+
+	var err error
+	logger, err = logOptions.New()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot create logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer logger.Sync()
+
+	stopTracing, err := traceOptions.Setup("dwh-mon")
+	if err != nil {
+		logger.Error("cannot set up tracing", zap.Error(err))
+		os.Exit(1)
+	}
+	defer stopTracing()
+
+	if err := sinkOptions.Validate(); err != nil {
+		logger.Error("invalid output flags", zap.Error(err))
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	id, err := sonmclient.NewIdentity(keyFileFlag, keyPasswordFlag)
+	if err != nil {
+		logger.Error("cannot set up identity", zap.Error(err))
+		os.Exit(1)
+	}
+
+	logger.Info("using identity", zap.String("eth", id.Eth().Hex()))
+
+	opts := sonmclient.Options{DialTimeout: timeoutFlag, Retries: retriesFlag, Backoff: retryBackoffFlag, Logger: logger}
+	conn, err := id.Dial(ctx, dwhAddrFlag, opts)
+	if err != nil {
+		logger.Error("cannot connect to DWH", zap.Error(err))
+		os.Exit(1)
+	}
+	defer conn.ClientConn.Close()
+
+	checker := newChecker(conn, opts)
+
+	tool := &daemon.Tool{
+		Name:       "dwh-mon",
+		Logger:     logger,
+		Health:     selfHealth,
+		Daemon:     daemonFlag,
+		Interval:   intervalFlag,
+		Timeout:    timeoutFlag,
+		Listen:     listenFlag,
+		AlertRules: alertRulesFlag,
+	}
+
+	backends := sinkOptions.Outputs(tool.Exporter())
+	err = tool.Run(ctx, func(ctx context.Context) error {
+		return poll(ctx, checker, backends)
+	})
+	if err != nil {
+		logger.Error("run failed", zap.Error(err))
+		logger.Sync()
+		os.Exit(1)
+	}
+}
+
+// poll runs the queries once and writes results, failed queries
+// are written too, but fail the poll along with stale data, so
+// the health of the daemon follows the DWH.
+func poll(ctx context.Context, c *checker, backends []sink.Sink) error {
+	ctx, span := tracing.StartSpan(ctx, "poll")
+	defer span.End()
+
+	result := c.check(ctx)
+
+	var problems []string
+	for _, q := range result.queries {
+		if q.err != nil {
+			logger.Warn("DWH query failed", zap.String("query", q.name), zap.Error(q.err))
+			problems = append(problems, fmt.Sprintf("%s failed: %v", q.name, q.err))
+			continue
+		}
+
+		if age := q.age(result.time); staleFlag > 0 && !q.newest.IsZero() && age > staleFlag {
+			logger.Warn("DWH data is stale", zap.String("query", q.name), zap.Duration("age", age))
+			problems = append(problems, fmt.Sprintf("%s is %s old", q.name, age.Round(time.Second)))
+		}
+	}
+
+	err := sink.WriteAll(backends, influxPoints(result))
+	if len(problems) > 0 {
+		return fmt.Errorf("DWH is degraded: %s", strings.Join(problems, "; "))
+	}
+
+	return err
+}
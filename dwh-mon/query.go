@@ -0,0 +1,120 @@
+package dwhmon
+
+import (
+	"context"
+	"time"
+
+	"github.com/sonm-io/core/proto"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sonmclient"
+)
+
+// dwhQuery is a representative request made by DWH users, it returns
+// creation time of the newest entity, zero if the DWH has none or
+// the query tells nothing about freshness.
+type dwhQuery struct {
+	name string
+	run  func(ctx context.Context, dwh sonm.DWHClient) (time.Time, error)
+}
+
+var queries = []dwhQuery{
+	{name: "deals", run: newestDeal},
+	{name: "orders", run: newestOrder},
+	{name: "profiles", run: anyProfile},
+}
+
+func newestDeal(ctx context.Context, dwh sonm.DWHClient) (time.Time, error) {
+	reply, err := dwh.GetDeals(ctx, &sonm.DealsRequest{
+		Limit:    1,
+		Sortings: []*sonm.SortingOption{{Field: "StartTime", Order: sonm.SortingOrder_Desc}},
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, d := range reply.GetDeals() {
+		return time.Unix(d.GetDeal().GetStartTime().GetSeconds(), 0), nil
+	}
+
+	return time.Time{}, nil
+}
+
+func newestOrder(ctx context.Context, dwh sonm.DWHClient) (time.Time, error) {
+	reply, err := dwh.GetOrders(ctx, &sonm.OrdersRequest{
+		Status:   sonm.OrderStatus_ORDER_ACTIVE,
+		Limit:    1,
+		Sortings: []*sonm.SortingOption{{Field: "CreatedTS", Order: sonm.SortingOrder_Desc}},
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, o := range reply.GetOrders() {
+		return time.Unix(o.GetCreatedTS().GetSeconds(), 0), nil
+	}
+
+	return time.Time{}, nil
+}
+
+func anyProfile(ctx context.Context, dwh sonm.DWHClient) (time.Time, error) {
+	_, err := dwh.GetProfiles(ctx, &sonm.ProfilesRequest{Limit: 1})
+	return time.Time{}, err
+}
+
+// queryResult is an outcome of a single query.
+type queryResult struct {
+	name    string
+	latency time.Duration
+	newest  time.Time
+	err     error
+}
+
+// age is how old the newest entity is, zero if unknown.
+func (q queryResult) age(now time.Time) time.Duration {
+	if q.newest.IsZero() {
+		return 0
+	}
+
+	return now.Sub(q.newest)
+}
+
+// pollResult has results of all queries made during the poll.
+type pollResult struct {
+	time    time.Time
+	queries []queryResult
+}
+
+type checker struct {
+	dwh  sonm.DWHClient
+	opts sonmclient.Options
+}
+
+func newChecker(conn *sonmclient.Conn, opts sonmclient.Options) *checker {
+	return &checker{dwh: sonm.NewDWHClient(conn.ClientConn), opts: opts}
+}
+
+// check runs queries one by one, so they do not
+// affect latencies of each other.
+func (c *checker) check(ctx context.Context) *pollResult {
+	result := &pollResult{time: time.Now()}
+	for _, q := range queries {
+		result.queries = append(result.queries, c.run(ctx, q))
+	}
+
+	return result
+}
+
+func (c *checker) run(ctx context.Context, q dwhQuery) queryResult {
+	ctx, cancel := context.WithTimeout(ctx, timeoutFlag)
+	defer cancel()
+
+	r := queryResult{name: q.name}
+	started := time.Now()
+	r.err = c.opts.Retry(ctx, q.name, func() error {
+		var err error
+		r.newest, err = q.run(ctx, c.dwh)
+		return err
+	})
+	r.latency = time.Since(started)
+
+	return r
+}
@@ -0,0 +1,117 @@
+// Package geoipupdate downloads MaxMind databases used by the
+// other tools, which reload the file once it is replaced.
+package geoipupdate
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/daemon"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/geoip"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/logging"
+	"go.uber.org/zap"
+)
+
+var (
+	accountIDFlag  string
+	licenseKeyFlag string
+	editionFlag    string
+	dbFlag         string
+	daemonFlag     bool
+	intervalFlag   time.Duration
+	timeoutFlag    time.Duration
+)
+
+// Flags are command line flags of the tool, parsed by Run.
+var Flags = flag.NewFlagSet("sonm-mon geoip", flag.ExitOnError)
+
+// logger is used for diagnostics, results printed by
+// console sinks do not go through it.
+var logger = zap.NewNop()
+
+// logOptions are set by the -log flags.
+var logOptions = logging.Options{Name: "geoip-update"}
+
+func init() {
+	Flags.StringVar(&accountIDFlag, "account-id", "", "MaxMind account ID, the legacy download URL is used if empty (MAXMIND_ACCOUNT_ID)")
+	Flags.StringVar(&licenseKeyFlag, "license-key", "", "MaxMind license key (MAXMIND_LICENSE_KEY)")
+	Flags.StringVar(&editionFlag, "edition", "GeoLite2-City", "database edition, e.g. GeoLite2-City or GeoLite2-ASN")
+	Flags.StringVar(&dbFlag, "db", "geo.mmdb", "path to install the database to")
+	Flags.BoolVar(&daemonFlag, "daemon", false, "keep running and check for updates periodically")
+	Flags.DurationVar(&intervalFlag, "interval", 24*time.Hour, "how often to check for updates in the daemon mode")
+	Flags.DurationVar(&timeoutFlag, "timeout", 5*time.Minute, "how long a single download may take")
+	logOptions.RegisterFlags(Flags)
+}
+
+// Run parses the arguments and runs the tool,
+// the process exits on failures.
+func Run(args []string) {
+	Flags.Parse(args)
+
+	var err error
+	logger, err = logOptions.New()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot create logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer logger.Sync()
+
+	if len(licenseKeyFlag) == 0 {
+		logger.Error("license key is required")
+		os.Exit(1)
+	}
+
+	u := &geoip.Updater{
+		AccountID:  accountIDFlag,
+		LicenseKey: licenseKeyFlag,
+		Edition:    editionFlag,
+		Path:       dbFlag,
+		Client:     &http.Client{Timeout: timeoutFlag},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if !daemonFlag {
+		if err := update(ctx, u); err != nil {
+			logger.Error("cannot update database", zap.Error(err))
+			logger.Sync()
+			os.Exit(1)
+		}
+		return
+	}
+
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		logger.Info("shutting down", zap.Stringer("signal", <-sigs))
+		cancel()
+	}()
+
+	daemon.Loop(ctx, intervalFlag, func() {
+		if err := update(ctx, u); err != nil {
+			logger.Warn("cannot update database", zap.Error(err))
+		}
+	})
+}
+
+func update(ctx context.Context, u *geoip.Updater) error {
+	updated, err := u.Update(ctx)
+	if err != nil {
+		return err
+	}
+
+	if updated {
+		logger.Info("database updated", zap.String("edition", u.Edition), zap.String("path", u.Path))
+	} else {
+		logger.Debug("database is up to date", zap.String("edition", u.Edition))
+	}
+
+	return nil
+}
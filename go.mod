@@ -7,7 +7,27 @@ replace github.com/libp2p/go-reuseport v0.1.10 => github.com/libp2p/go-reuseport
 go 1.12
 
 require (
+	github.com/Shopify/sarama v1.27.2
+	github.com/coreos/go-systemd v0.0.0-20170609144627-24036eb3df68
 	github.com/ethereum/go-ethereum v0.0.0-20180929205331-b69942befeb9
-	github.com/oschwald/geoip2-golang v1.3.0 // indirect
-	github.com/sonm-io/core v0.4.27 // indirect
+	github.com/graph-gophers/graphql-go v1.3.0
+	github.com/influxdata/influxdb v0.0.0-20180412224233-7ebfc9c544e0
+	github.com/influxdata/influxdb-client-go/v2 v2.4.0
+	github.com/mattn/go-sqlite3 v1.14.6
+	github.com/mmcloughlin/geohash v0.0.0-20180909114810-59020f29e94a
+	github.com/oschwald/geoip2-golang v1.3.0
+	github.com/pborman/uuid v0.0.0-20160216163710-c55201b03606
+	github.com/prometheus/client_golang v0.0.0-20180120141031-06bc6e01f4ba
+	github.com/prometheus/client_model v0.0.0-20190812154241-14fe0d1b01d4
+	github.com/prometheus/common v0.0.0-20180110214958-89604d197083
+	github.com/sonm-io/core v0.4.27
+	github.com/xitongsys/parquet-go v1.6.2
+	github.com/xitongsys/parquet-go-source v0.0.0-20200817004010-026bad9b25d0
+	go.opentelemetry.io/otel v1.10.0
+	go.opentelemetry.io/otel/sdk v1.10.0
+	go.opentelemetry.io/otel/trace v1.10.0
+	go.uber.org/zap v1.9.1
+	golang.org/x/sync v0.0.0-20190911185100-cd5d95a43a6e
+	google.golang.org/grpc v1.27.1
+	gopkg.in/yaml.v2 v2.4.0
 )
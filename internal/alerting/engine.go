@@ -0,0 +1,181 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap"
+)
+
+// Engine evaluates rules against metrics of the gatherer,
+// the default prometheus registry the tools export to.
+type Engine struct {
+	cfg       *Config
+	notifiers []Notifier
+	gatherer  prometheus.Gatherer
+	logger    *zap.Logger
+	// active are series the rules hold for, keyed
+	// by the rule name followed by the series.
+	active map[string]*Alert
+}
+
+// New loads rules from the file, notifications are sent
+// to the notifiers configured there and the extra ones.
+func New(path string, logger *zap.Logger, extra ...Notifier) (*Engine, error) {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	notifiers := append(cfg.notifiers(), extra...)
+	if len(notifiers) == 0 {
+		return nil, fmt.Errorf("no notifiers configured")
+	}
+
+	return &Engine{
+		cfg:       cfg,
+		notifiers: notifiers,
+		gatherer:  prometheus.DefaultGatherer,
+		logger:    logger,
+		active:    map[string]*Alert{},
+	}, nil
+}
+
+// Run evaluates rules periodically until the context is done.
+func (e *Engine) Run(ctx context.Context) {
+	e.logger.Info("evaluating alert rules", zap.Int("rules", len(e.cfg.Rules)), zap.Duration("interval", e.cfg.Interval))
+
+	tk := time.NewTicker(e.cfg.Interval)
+	defer tk.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tk.C:
+			if err := e.Evaluate(time.Now()); err != nil {
+				e.logger.Warn("cannot evaluate alert rules", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Evaluate checks rules once. A series missing from the metrics
+// is resolved, tools drop series of things gone away.
+func (e *Engine) Evaluate(now time.Time) error {
+	families, err := e.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+
+	byName := map[string]*dto.MetricFamily{}
+	for _, f := range families {
+		byName[f.GetName()] = f
+	}
+
+	seen := map[string]bool{}
+	for _, r := range e.cfg.Rules {
+		f, ok := byName[r.Metric]
+		if !ok {
+			continue
+		}
+
+		for _, m := range f.GetMetric() {
+			labels := labelMap(m)
+			value, ok := metricValue(m)
+			if !ok || !matches(labels, r.Labels) || !comparisons[r.Op](value, r.Threshold) {
+				continue
+			}
+
+			key := r.Name + "/" + seriesKey(labels)
+			seen[key] = true
+
+			a, ok := e.active[key]
+			if !ok {
+				a = &Alert{
+					Rule: r.Name, Metric: r.Metric, Labels: labels, Op: r.Op,
+					Threshold: r.Threshold, Message: r.Message, Since: now,
+				}
+				e.active[key] = a
+			}
+
+			firing := now.Sub(a.Since) >= r.For
+			wasFiring := !a.Time.IsZero()
+			a.Value = value
+			if firing && !wasFiring {
+				a.Time = now
+				e.notify(*a)
+			}
+		}
+	}
+
+	for key, a := range e.active {
+		if seen[key] {
+			continue
+		}
+
+		delete(e.active, key)
+		if !a.Time.IsZero() {
+			a.Time, a.Resolved = now, true
+			e.notify(*a)
+		}
+	}
+
+	return nil
+}
+
+func (e *Engine) notify(a Alert) {
+	for _, n := range e.notifiers {
+		if err := n.Notify(a); err != nil {
+			e.logger.Warn("cannot send alert", zap.String("rule", a.Rule), zap.Error(err))
+		}
+	}
+}
+
+func labelMap(m *dto.Metric) map[string]string {
+	labels := map[string]string{}
+	for _, l := range m.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+
+	return labels
+}
+
+// metricValue returns values of gauges, counters and untyped metrics.
+func metricValue(m *dto.Metric) (float64, bool) {
+	switch {
+	case m.Gauge != nil:
+		return m.GetGauge().GetValue(), true
+	case m.Counter != nil:
+		return m.GetCounter().GetValue(), true
+	case m.Untyped != nil:
+		return m.GetUntyped().GetValue(), true
+	}
+
+	return 0, false
+}
+
+func matches(labels, want map[string]string) bool {
+	for k, v := range want {
+		if labels[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+func seriesKey(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,127 @@
+package alerting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// grafanaNotifier writes alerts as Grafana annotations, so dashboards
+// show markers aligned with the metric graphs. A firing alert starts
+// an annotation, resolving turns it into a region ending at the time.
+// Annotations are not bound to a dashboard, add an annotation query
+// filtering by tags to show them, e.g. rules like
+//
+//	rules:
+//	- name: peer count drop
+//	  metric: map_data_totals_endpoints
+//	  op: "<"
+//	  threshold: 1000
+//	- name: relay member loss
+//	  metric: relay_members_changes_left
+//	  op: ">"
+//	  threshold: 0
+//	- name: DWH outage
+//	  metric: dwh_ok
+//	  op: "=="
+//	  threshold: 0
+//	  for: 2m
+type grafanaNotifier struct {
+	url   string
+	token string
+	tags  []string
+
+	mu sync.Mutex
+	// ids are annotations of firing alerts by the rule and series.
+	ids map[string]int64
+}
+
+func newGrafanaNotifier(url, token string, tags []string) *grafanaNotifier {
+	return &grafanaNotifier{
+		url:   strings.TrimSuffix(url, "/"),
+		token: token,
+		tags:  tags,
+		ids:   map[string]int64{},
+	}
+}
+
+func (n *grafanaNotifier) Notify(a Alert) error {
+	key := a.Rule + "/" + seriesKey(a.Labels)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if a.Resolved {
+		id, ok := n.ids[key]
+		if !ok {
+			// the alert has fired before the restart
+			return nil
+		}
+
+		delete(n.ids, key)
+		return n.do(http.MethodPatch, fmt.Sprintf("/api/annotations/%d", id), map[string]interface{}{
+			"timeEnd": toMillis(a.Time),
+		}, nil)
+	}
+
+	tags := append([]string{a.Rule, a.Metric}, n.tags...)
+	for k, v := range a.Labels {
+		tags = append(tags, k+":"+v)
+	}
+
+	created := struct {
+		ID int64 `json:"id"`
+	}{}
+	err := n.do(http.MethodPost, "/api/annotations", map[string]interface{}{
+		"time": toMillis(a.Since),
+		"tags": tags,
+		"text": a.String(),
+	}, &created)
+	if err != nil {
+		return err
+	}
+
+	n.ids[key] = created.ID
+	return nil
+}
+
+func (n *grafanaNotifier) do(method, path string, body, result interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(method, n.url+path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if len(n.token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+
+	resp, err := (&http.Client{Timeout: notifyTimeout}).Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot write grafana annotation: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("grafana responded with %s", resp.Status)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,151 @@
+package alerting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const notifyTimeout = 10 * time.Second
+
+// Alert is a rule holding for a single series of the metric.
+type Alert struct {
+	Rule      string            `json:"rule"`
+	Metric    string            `json:"metric"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Value     float64           `json:"value"`
+	Op        string            `json:"op"`
+	Threshold float64           `json:"threshold"`
+	Message   string            `json:"message,omitempty"`
+	// Since is when the rule has started to hold.
+	Since time.Time `json:"since"`
+	Time  time.Time `json:"time"`
+	// Resolved is set when the rule does not hold anymore.
+	Resolved bool `json:"resolved,omitempty"`
+}
+
+// String is the human readable form used by chat notifiers.
+func (a Alert) String() string {
+	keys := make([]string, 0, len(a.Labels))
+	for k := range a.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var labels []string
+	for _, k := range keys {
+		labels = append(labels, k+"="+a.Labels[k])
+	}
+
+	series := a.Metric
+	if len(labels) > 0 {
+		series += "{" + strings.Join(labels, ",") + "}"
+	}
+
+	if a.Resolved {
+		return fmt.Sprintf("resolved: %s, %s = %g", a.Rule, series, a.Value)
+	}
+
+	text := fmt.Sprintf("firing: %s, %s = %g %s %g since %s", a.Rule, series, a.Value, a.Op, a.Threshold, a.Since.Format(time.RFC3339))
+	if len(a.Message) > 0 {
+		text += "\n" + a.Message
+	}
+
+	return text
+}
+
+// Notifier delivers alerts.
+type Notifier interface {
+	Notify(a Alert) error
+}
+
+// notifiers returns notifiers configured in the file.
+func (cfg *Config) notifiers() []Notifier {
+	var notifiers []Notifier
+	if len(cfg.Webhook) > 0 {
+		notifiers = append(notifiers, &webhookNotifier{url: cfg.Webhook})
+	}
+
+	if len(cfg.Telegram.Token) > 0 && len(cfg.Telegram.Chat) > 0 {
+		notifiers = append(notifiers, &telegramNotifier{token: cfg.Telegram.Token, chatID: cfg.Telegram.Chat})
+	}
+
+	if len(cfg.SlackWebhook) > 0 {
+		notifiers = append(notifiers, &slackNotifier{webhook: cfg.SlackWebhook})
+	}
+
+	if len(cfg.Grafana.URL) > 0 {
+		notifiers = append(notifiers, newGrafanaNotifier(cfg.Grafana.URL, cfg.Grafana.Token, cfg.Grafana.Tags))
+	}
+
+	return notifiers
+}
+
+// webhookNotifier posts alerts as JSON.
+type webhookNotifier struct {
+	url string
+}
+
+func (n *webhookNotifier) Notify(a Alert) error {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	return post(n.url, "application/json", b)
+}
+
+type telegramNotifier struct {
+	token  string
+	chatID string
+}
+
+func (n *telegramNotifier) Notify(a Alert) error {
+	resp, err := (&http.Client{Timeout: notifyTimeout}).PostForm("https://api.telegram.org/bot"+n.token+"/sendMessage", url.Values{
+		"chat_id": {n.chatID},
+		"text":    {a.String()},
+	})
+	if err != nil {
+		// the error contains the URL, which contains the token
+		return fmt.Errorf("cannot send telegram message: %v", strings.Replace(err.Error(), n.token, "<token>", -1))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("telegram responded with %s", resp.Status)
+	}
+
+	return nil
+}
+
+type slackNotifier struct {
+	webhook string
+}
+
+func (n *slackNotifier) Notify(a Alert) error {
+	b, err := json.Marshal(map[string]string{"text": a.String()})
+	if err != nil {
+		return err
+	}
+
+	return post(n.webhook, "application/json", b)
+}
+
+func post(url, contentType string, body []byte) error {
+	resp, err := (&http.Client{Timeout: notifyTimeout}).Post(url, contentType, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+// Package alerting evaluates threshold rules against metrics exported
+// by the tool and notifies when a rule holds for long enough.
+package alerting
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the YAML file with rules and notifiers, e.g.
+//
+//	interval: 30s
+//	webhook: https://example.com/alerts
+//	rules:
+//	  - name: rendezvous is empty
+//	    metric: map_data_totals_endpoints
+//	    labels: {source: "1.2.3.4:14099"}
+//	    op: "<"
+//	    threshold: 100
+//	    for: 5m
+type Config struct {
+	// Interval is how often rules are evaluated, 30s if not set.
+	Interval time.Duration `yaml:"interval"`
+	Rules    []Rule        `yaml:"rules"`
+
+	Webhook  string `yaml:"webhook"`
+	Telegram struct {
+		Token string `yaml:"token"`
+		Chat  string `yaml:"chat"`
+	} `yaml:"telegram"`
+	SlackWebhook string `yaml:"slack_webhook"`
+	// Grafana writes alerts as annotations, see grafanaNotifier.
+	Grafana struct {
+		URL   string   `yaml:"url"`
+		Token string   `yaml:"token"`
+		Tags  []string `yaml:"tags"`
+	} `yaml:"grafana"`
+}
+
+// Rule compares every series of the metric matching labels with the
+// threshold, the alert fires when the comparison holds for the duration.
+type Rule struct {
+	Name      string            `yaml:"name"`
+	Metric    string            `yaml:"metric"`
+	Labels    map[string]string `yaml:"labels"`
+	Op        string            `yaml:"op"`
+	Threshold float64           `yaml:"threshold"`
+	For       time.Duration     `yaml:"for"`
+	// Message is added to notifications, optional.
+	Message string `yaml:"message"`
+}
+
+var comparisons = map[string]func(v, threshold float64) bool{
+	">":  func(v, t float64) bool { return v > t },
+	">=": func(v, t float64) bool { return v >= t },
+	"<":  func(v, t float64) bool { return v < t },
+	"<=": func(v, t float64) bool { return v <= t },
+	"==": func(v, t float64) bool { return v == t },
+	"!=": func(v, t float64) bool { return v != t },
+}
+
+// LoadConfig reads and validates the rules file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+
+	for i, r := range cfg.Rules {
+		if len(r.Name) == 0 || len(r.Metric) == 0 {
+			return nil, fmt.Errorf("rule #%d must have both name and metric", i+1)
+		}
+
+		if _, ok := comparisons[r.Op]; !ok {
+			return nil, fmt.Errorf("rule `%s` has unknown comparison `%s`", r.Name, r.Op)
+		}
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,124 @@
+// Package daemon runs polls of the tools periodically and serves
+// their HTTP endpoints. A standalone tool owns its ticker and its
+// listener, while the agent hosting several tools in one process
+// runs their polls on a shared scheduler and mounts their endpoints
+// on a single server, see StartAgent.
+package daemon
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// agent is set once StartAgent is called.
+var (
+	mu    sync.Mutex
+	agent *Agent
+)
+
+// Agent is the shared scheduler and server of tools hosted
+// in one process.
+type Agent struct {
+	slots chan struct{}
+	mux   *http.ServeMux
+}
+
+// StartAgent switches the process to the agent mode, at most parallel
+// polls of all tools run at once. The returned agent serves /metrics
+// of the process and endpoints of tools under /<name>/.
+func StartAgent(parallel int) *Agent {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	a := &Agent{slots: make(chan struct{}, parallel), mux: http.NewServeMux()}
+	a.mux.Handle("/metrics", promhttp.Handler())
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	agent = a
+	return a
+}
+
+func current() *Agent {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return agent
+}
+
+// Enabled reports whether the process is the agent.
+func Enabled() bool {
+	return current() != nil
+}
+
+// ServeHTTP serves the metrics and endpoints mounted by tools.
+func (a *Agent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mux.ServeHTTP(w, r)
+}
+
+// Handle mounts a handler of the agent itself, e.g. its health.
+func (a *Agent) Handle(pattern string, h http.Handler) {
+	a.mux.Handle(pattern, h)
+}
+
+// acquire waits for a free slot, false is returned
+// if the context is done first.
+func (a *Agent) acquire(ctx context.Context) bool {
+	select {
+	case a.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (a *Agent) release() {
+	<-a.slots
+}
+
+// Loop calls poll right away and then every interval until the
+// context is done. Within the agent a poll waits for a free slot
+// of the shared scheduler, so tools do not poll all at once.
+func Loop(ctx context.Context, interval time.Duration, poll func()) {
+	tk := time.NewTicker(interval)
+	defer tk.Stop()
+
+	for {
+		if a := current(); a != nil {
+			if !a.acquire(ctx) {
+				return
+			}
+
+			poll()
+			a.release()
+		} else {
+			poll()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tk.C:
+		}
+	}
+}
+
+// Serve serves the handler at the address until the server fails.
+// Within the agent the handler is mounted on the agent server under
+// /<name>/ instead and nil is returned at once.
+func Serve(name, addr string, h http.Handler) error {
+	if a := current(); a != nil {
+		prefix := "/" + strings.Trim(name, "/")
+		a.mux.Handle(prefix+"/", http.StripPrefix(prefix, h))
+		return nil
+	}
+
+	return http.ListenAndServe(addr, h)
+}
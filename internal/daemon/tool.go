@@ -0,0 +1,143 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/alerting"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/health"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+	"go.uber.org/zap"
+)
+
+// Tool runs polls of a tool either once or as a daemon, serving
+// its metrics and evaluating alert rules against them.
+type Tool struct {
+	Name   string
+	Logger *zap.Logger
+	// Health records polls of the daemon and is served along
+	// with metrics, nil if the tool does not track them.
+	Health *health.Checker
+
+	// Daemon keeps polling every Interval until the process is
+	// stopped, Listen and AlertRules imply it. Timeout is how long
+	// a poll may take, the daemon is stale after a few missed polls.
+	Daemon   bool
+	Interval time.Duration
+	Timeout  time.Duration
+
+	// Listen is the address to serve /metrics, health endpoints
+	// and Handlers at, nothing is served if empty.
+	Listen   string
+	Handlers map[string]http.Handler
+	// AlertRules is the path of the YAML rules file, see the
+	// alerting package.
+	AlertRules string
+
+	exporter *sink.Exporter
+}
+
+// Exporting reports whether polls are exported as prometheus
+// metrics, alert rules are evaluated against them too.
+func (t *Tool) Exporting() bool {
+	return len(t.Listen) > 0 || len(t.AlertRules) > 0
+}
+
+// Exporter returns the exporter of points served on /metrics, it is
+// registered once on the first call. It is nil unless the tool is
+// exporting, so it is not a backend of one-shot runs.
+func (t *Tool) Exporter() *sink.Exporter {
+	if !t.Exporting() {
+		return nil
+	}
+
+	if t.exporter == nil {
+		t.exporter = sink.NewExporter(t.Name)
+		prometheus.MustRegister(t.exporter)
+	}
+
+	return t.exporter
+}
+
+// Polling reports whether the tool keeps polling once Run is called,
+// either as asked or to serve metrics and evaluate alert rules.
+func (t *Tool) Polling() bool {
+	return t.Daemon || t.Exporting()
+}
+
+// Run calls poll once and returns its error, while the daemon polls
+// until SIGINT or SIGTERM and returns nil. Failed polls of the daemon
+// are logged and recorded by the health checker.
+func (t *Tool) Run(ctx context.Context, poll func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if len(t.Listen) > 0 {
+		t.Daemon = true
+		if t.Health != nil {
+			t.Health.SetStaleAfter(3*t.Interval + t.Timeout)
+		}
+
+		go t.serve()
+	}
+
+	if len(t.AlertRules) > 0 {
+		engine, err := alerting.New(t.AlertRules, t.Logger)
+		if err != nil {
+			return fmt.Errorf("cannot load alert rules: %v", err)
+		}
+
+		t.Daemon = true
+		go engine.Run(ctx)
+	}
+
+	if !t.Daemon {
+		return poll(ctx)
+	}
+
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		t.Logger.Info("shutting down", zap.Stringer("signal", <-sigs))
+		cancel()
+	}()
+
+	Loop(ctx, t.Interval, func() {
+		err := poll(ctx)
+		if err != nil {
+			t.Logger.Warn("poll failed", zap.Error(err))
+		}
+
+		if t.Health != nil {
+			t.Health.Record(err)
+		}
+	})
+
+	return nil
+}
+
+// serve exposes metrics, health and handlers of the tool,
+// the process exits if the address cannot be served.
+func (t *Tool) serve() {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	if t.Health != nil {
+		t.Health.Register(mux)
+	}
+
+	for pattern, h := range t.Handlers {
+		mux.Handle(pattern, h)
+	}
+
+	t.Logger.Info("serving metrics", zap.String("addr", t.Listen))
+	if err := Serve(t.Name, t.Listen, mux); err != nil {
+		t.Logger.Fatal("cannot serve metrics", zap.Error(err))
+	}
+}
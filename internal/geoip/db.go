@@ -0,0 +1,116 @@
+// Package geoip keeps MaxMind databases up to date: the updater
+// downloads and swaps the file, readers reload once it is swapped.
+package geoip
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// DB is a geoip database reopened when the file is replaced.
+type DB struct {
+	path string
+
+	mu     sync.RWMutex
+	reader *geoip2.Reader
+	// modTime and size tell whether the file has changed.
+	modTime time.Time
+	size    int64
+}
+
+func Open(path string) (*DB, error) {
+	db := &DB{path: path}
+	if err := db.Reload(); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (db *DB) City(ip net.IP) (*geoip2.City, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.reader.City(ip)
+}
+
+func (db *DB) ASN(ip net.IP) (*geoip2.ASN, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.reader.ASN(ip)
+}
+
+// Reload reopens the file, the previous database
+// is kept if the new one cannot be opened.
+func (db *DB) Reload() error {
+	st, err := os.Stat(db.path)
+	if err != nil {
+		return err
+	}
+
+	reader, err := geoip2.Open(db.path)
+	if err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	old := db.reader
+	db.reader, db.modTime, db.size = reader, st.ModTime(), st.Size()
+	db.mu.Unlock()
+
+	// lookups hold the read lock, so none uses the old one
+	if old != nil {
+		old.Close()
+	}
+
+	return nil
+}
+
+// changed reports whether the file differs from the loaded one.
+func (db *DB) changed() bool {
+	st, err := os.Stat(db.path)
+	if err != nil {
+		return false
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return !st.ModTime().Equal(db.modTime) || st.Size() != db.size
+}
+
+// Watch reloads the database when the file is replaced, e.g. by the
+// updater running in another process, until the context is done.
+// The error is passed to onError if the reload fails.
+func (db *DB) Watch(ctx context.Context, interval time.Duration, onError func(err error)) {
+	tk := time.NewTicker(interval)
+	defer tk.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tk.C:
+			if !db.changed() {
+				continue
+			}
+
+			if err := db.Reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+func (db *DB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.reader.Close()
+}
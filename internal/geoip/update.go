@@ -0,0 +1,193 @@
+package geoip
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+const (
+	downloadURL       = "https://download.maxmind.com/geoip/databases/%s/download?suffix=%s"
+	legacyDownloadURL = "https://download.maxmind.com/app/geoip_download?edition_id=%s&license_key=%s&suffix=%s"
+)
+
+// Updater downloads a MaxMind database edition to the path, the
+// checksum of the installed archive is kept next to it in a
+// ".sha256" file, so unchanged databases are not downloaded.
+type Updater struct {
+	// AccountID is optional, the license key alone
+	// uses the legacy download endpoint.
+	AccountID  string
+	LicenseKey string
+	// Edition is e.g. GeoLite2-City or GeoLite2-ASN.
+	Edition string
+	Path    string
+	Client  *http.Client
+}
+
+// Update installs the latest database if it differs from the installed
+// one and reports whether it did. The archive is checked against its
+// checksum and the database is opened before the file is swapped.
+func (u *Updater) Update(ctx context.Context) (bool, error) {
+	b, err := u.get(ctx, "tar.gz.sha256")
+	if err != nil {
+		return false, fmt.Errorf("cannot get checksum: %v", err)
+	}
+
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return false, fmt.Errorf("empty checksum")
+	}
+	checksum := strings.ToLower(fields[0])
+
+	installed, _ := ioutil.ReadFile(u.Path + ".sha256")
+	if strings.TrimSpace(string(installed)) == checksum {
+		if _, err := os.Stat(u.Path); err == nil {
+			return false, nil
+		}
+	}
+
+	archive, err := u.get(ctx, "tar.gz")
+	if err != nil {
+		return false, fmt.Errorf("cannot download database: %v", err)
+	}
+
+	sum := sha256.Sum256(archive)
+	if hex.EncodeToString(sum[:]) != checksum {
+		return false, fmt.Errorf("checksum mismatch, expected %s", checksum)
+	}
+
+	tmp, err := u.extract(archive)
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp)
+
+	if err := u.verify(tmp); err != nil {
+		return false, err
+	}
+
+	if err := os.Rename(tmp, u.Path); err != nil {
+		return false, err
+	}
+
+	if err := ioutil.WriteFile(u.Path+".sha256", []byte(checksum+"\n"), 0644); err != nil {
+		return true, fmt.Errorf("cannot save checksum: %v", err)
+	}
+
+	return true, nil
+}
+
+func (u *Updater) get(ctx context.Context, suffix string) ([]byte, error) {
+	var target string
+	if len(u.AccountID) > 0 {
+		target = fmt.Sprintf(downloadURL, url.PathEscape(u.Edition), url.QueryEscape(suffix))
+	} else {
+		target = fmt.Sprintf(legacyDownloadURL, url.QueryEscape(u.Edition), url.QueryEscape(u.LicenseKey), url.QueryEscape(suffix))
+	}
+
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(u.AccountID) > 0 {
+		req.SetBasicAuth(u.AccountID, u.LicenseKey)
+	}
+
+	client := u.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		// the legacy URL contains the license key
+		return nil, fmt.Errorf("%v", strings.Replace(err.Error(), u.LicenseKey, "<license-key>", -1))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// extract writes the .mmdb file of the archive to a temporary
+// file next to the path, so it can be renamed atomically.
+func (u *Updater) extract(archive []byte) (string, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return "", fmt.Errorf("cannot read archive: %v", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return "", fmt.Errorf("no database in the archive")
+		}
+		if err != nil {
+			return "", fmt.Errorf("cannot read archive: %v", err)
+		}
+
+		if hdr.Typeflag != tar.TypeReg || !strings.HasSuffix(hdr.Name, ".mmdb") {
+			continue
+		}
+
+		f, err := ioutil.TempFile(filepath.Dir(u.Path), filepath.Base(u.Path)+".tmp")
+		if err != nil {
+			return "", err
+		}
+
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return "", err
+		}
+
+		if err := f.Sync(); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return "", err
+		}
+
+		if err := f.Chmod(0644); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return "", err
+		}
+
+		return f.Name(), f.Close()
+	}
+}
+
+// verify opens the database and checks it is of the edition.
+func (u *Updater) verify(path string) error {
+	db, err := geoip2.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot open downloaded database: %v", err)
+	}
+	defer db.Close()
+
+	if t := db.Metadata().DatabaseType; t != u.Edition {
+		return fmt.Errorf("downloaded database is %s, expected %s", t, u.Edition)
+	}
+
+	return nil
+}
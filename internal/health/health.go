@@ -0,0 +1,183 @@
+// Package health serves the state of a daemon, so every tool is
+// probed the same way: /healthz fails once polls stop succeeding,
+// /readyz fails until the daemon has something to report, and
+// /info describes the running process.
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Checker tracks polls of a daemon, the zero staleness
+// keeps it healthy as long as the process serves.
+type Checker struct {
+	name    string
+	started time.Time
+
+	lastSuccessGauge prometheus.Gauge
+	failuresGauge    prometheus.Gauge
+
+	mu          sync.Mutex
+	staleAfter  time.Duration
+	ready       bool
+	lastSuccess time.Time
+	failures    int
+	lastError   string
+}
+
+// New returns a checker of the named tool and registers its gauges,
+// e.g. rv_mon_last_success_timestamp_seconds for "rv-mon".
+func New(name string) *Checker {
+	prefix := strings.Replace(name, "-", "_", -1)
+	c := &Checker{
+		name:    name,
+		started: time.Now(),
+		lastSuccessGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: prefix + "_last_success_timestamp_seconds",
+			Help: "Unix time of the last poll which has succeeded completely.",
+		}),
+		failuresGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: prefix + "_consecutive_failures",
+			Help: "Number of failed polls since the last successful one.",
+		}),
+	}
+
+	prometheus.MustRegister(c.lastSuccessGauge, c.failuresGauge)
+	return c
+}
+
+// SetStaleAfter sets how long the daemon may go without a successful
+// poll before it is unhealthy, usually a few polling intervals.
+func (c *Checker) SetStaleAfter(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.staleAfter = d
+}
+
+// SetReady marks the daemon ready or not, the first
+// successful poll makes it ready too.
+func (c *Checker) SetReady(ready bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ready = ready
+}
+
+// Record updates the state with the result of a poll.
+func (c *Checker) Record(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		c.failures++
+		c.lastError = err.Error()
+	} else {
+		c.lastSuccess = time.Now()
+		c.failures = 0
+		c.lastError = ""
+		c.ready = true
+		c.lastSuccessGauge.Set(float64(c.lastSuccess.Unix()))
+	}
+
+	c.failuresGauge.Set(float64(c.failures))
+}
+
+// Register mounts /healthz, /readyz and /info on the mux.
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", c.serveHealth)
+	mux.HandleFunc("/readyz", c.serveReady)
+	mux.HandleFunc("/info", c.serveInfo)
+}
+
+type report struct {
+	Healthy             bool      `json:"healthy"`
+	Ready               bool      `json:"ready"`
+	LastSuccess         time.Time `json:"last_success"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+}
+
+func (c *Checker) report() report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	since := c.lastSuccess
+	if since.IsZero() {
+		since = c.started
+	}
+
+	return report{
+		Healthy:             c.staleAfter == 0 || time.Since(since) < c.staleAfter,
+		Ready:               c.ready,
+		LastSuccess:         c.lastSuccess,
+		ConsecutiveFailures: c.failures,
+		LastError:           c.lastError,
+	}
+}
+
+// serveHealth responds with 503 when there was no successful poll
+// for the staleness period, a single failure is not reported.
+func (c *Checker) serveHealth(w http.ResponseWriter, r *http.Request) {
+	rep := c.report()
+	writeJSON(w, rep.Healthy, rep)
+}
+
+// serveReady responds with 503 until the daemon is ready.
+func (c *Checker) serveReady(w http.ResponseWriter, r *http.Request) {
+	rep := c.report()
+	writeJSON(w, rep.Ready, rep)
+}
+
+type info struct {
+	Name       string    `json:"name"`
+	Version    string    `json:"version"`
+	GoVersion  string    `json:"go_version"`
+	Hostname   string    `json:"hostname"`
+	PID        int       `json:"pid"`
+	Started    time.Time `json:"started"`
+	Uptime     string    `json:"uptime"`
+	Goroutines int       `json:"goroutines"`
+}
+
+func (c *Checker) serveInfo(w http.ResponseWriter, r *http.Request) {
+	hostname, _ := os.Hostname()
+	writeJSON(w, true, info{
+		Name:       c.name,
+		Version:    version(),
+		GoVersion:  runtime.Version(),
+		Hostname:   hostname,
+		PID:        os.Getpid(),
+		Started:    c.started,
+		Uptime:     time.Since(c.started).Round(time.Second).String(),
+		Goroutines: runtime.NumGoroutine(),
+	})
+}
+
+// version returns the module version the binary is built from,
+// "(devel)" for builds out of a working copy.
+func version() string {
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		return bi.Main.Version
+	}
+
+	return "unknown"
+}
+
+func writeJSON(w http.ResponseWriter, ok bool, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.NewEncoder(w).Encode(v)
+}
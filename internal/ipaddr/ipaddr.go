@@ -0,0 +1,41 @@
+// Package ipaddr classifies addresses of peers and relays.
+package ipaddr
+
+import "net"
+
+// privateNets are IPv4 and IPv6 ranges not routed on the internet.
+var privateNets = mustParseCIDRs(
+	"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10",
+	"fc00::/7",
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+
+		nets = append(nets, n)
+	}
+
+	return nets
+}
+
+// IsPrivate reports whether the address cannot be reached from the
+// internet, being loopback, link-local or belonging to a private network.
+// Such addresses cannot be located with geoip either.
+func IsPrivate(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return true
+	}
+
+	for _, n := range privateNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
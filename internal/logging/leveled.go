@@ -0,0 +1,154 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log/syslog"
+	"net"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// journalSocket is where journald accepts native protocol datagrams.
+const journalSocket = "/run/systemd/journal/socket"
+
+// leveledWriter writes a single entry keeping its severity,
+// which syslog and the journal store apart from the message.
+type leveledWriter interface {
+	writeLevel(lvl zapcore.Level, msg []byte) error
+}
+
+// leveledCore is zapcore.ioCore writing entries one by one
+// along with their level.
+type leveledCore struct {
+	enab zapcore.LevelEnabler
+	enc  zapcore.Encoder
+	out  leveledWriter
+}
+
+func newLeveledCore(enc zapcore.Encoder, out leveledWriter, enab zapcore.LevelEnabler) zapcore.Core {
+	return &leveledCore{enab: enab, enc: enc, out: out}
+}
+
+func (c *leveledCore) Enabled(lvl zapcore.Level) bool {
+	return c.enab.Enabled(lvl)
+}
+
+func (c *leveledCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	return &leveledCore{enab: c.enab, enc: enc, out: c.out}
+}
+
+func (c *leveledCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+func (c *leveledCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	return c.out.writeLevel(ent.Level, bytes.TrimRight(buf.Bytes(), "\n"))
+}
+
+func (c *leveledCore) Sync() error {
+	return nil
+}
+
+type syslogWriter struct {
+	w *syslog.Writer
+}
+
+func newSyslogWriter(tag string) (*syslogWriter, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return &syslogWriter{w: w}, nil
+}
+
+func (s *syslogWriter) writeLevel(lvl zapcore.Level, msg []byte) error {
+	switch lvl {
+	case zapcore.DebugLevel:
+		return s.w.Debug(string(msg))
+	case zapcore.InfoLevel:
+		return s.w.Info(string(msg))
+	case zapcore.WarnLevel:
+		return s.w.Warning(string(msg))
+	case zapcore.ErrorLevel:
+		return s.w.Err(string(msg))
+	default:
+		return s.w.Crit(string(msg))
+	}
+}
+
+// journalWriter sends entries using the native journal protocol,
+// see systemd.journal-fields(7) for the fields.
+type journalWriter struct {
+	identifier string
+	conn       *net.UnixConn
+	addr       *net.UnixAddr
+}
+
+func newJournalWriter(identifier string) (*journalWriter, error) {
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+
+	addr := &net.UnixAddr{Name: journalSocket, Net: "unixgram"}
+	w := &journalWriter{identifier: identifier, conn: conn, addr: addr}
+	// fail early if the journal is not there, e.g. out of systemd
+	if err := w.writeLevel(zapcore.DebugLevel, []byte("logging to journal")); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// journalPriority maps levels to syslog priorities.
+var journalPriority = map[zapcore.Level]int{
+	zapcore.DebugLevel:  7,
+	zapcore.InfoLevel:   6,
+	zapcore.WarnLevel:   4,
+	zapcore.ErrorLevel:  3,
+	zapcore.DPanicLevel: 2,
+	zapcore.PanicLevel:  2,
+	zapcore.FatalLevel:  2,
+}
+
+func (j *journalWriter) writeLevel(lvl zapcore.Level, msg []byte) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "PRIORITY=%d\n", journalPriority[lvl])
+	if len(j.identifier) > 0 {
+		fmt.Fprintf(&b, "SYSLOG_IDENTIFIER=%s\n", j.identifier)
+	}
+
+	// values with newlines are length-prefixed instead
+	if bytes.IndexByte(msg, '\n') >= 0 {
+		b.WriteString("MESSAGE\n")
+		binary.Write(&b, binary.LittleEndian, uint64(len(msg)))
+		b.Write(msg)
+		b.WriteByte('\n')
+	} else {
+		b.WriteString("MESSAGE=")
+		b.Write(msg)
+		b.WriteByte('\n')
+	}
+
+	_, err := j.conn.WriteToUnix(b.Bytes(), j.addr)
+	return err
+}
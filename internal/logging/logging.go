@@ -0,0 +1,113 @@
+// Package logging builds loggers of the tools, so they share the
+// level, format and destination flags: stderr, a rotating file,
+// syslog or the systemd journal.
+package logging
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Options configure the logger, see RegisterFlags for defaults.
+type Options struct {
+	// Name is the syslog tag and the journal identifier.
+	Name string
+	// Output is either "stderr", "syslog", "journald" or "file:/path".
+	Output string
+	// Level is a zap level name, e.g. "info".
+	Level string
+	// Format is either "console" or "json".
+	Format string
+	// MaxSize is the size in bytes a log file is rotated after,
+	// zero disables rotation. Keep is how many rotated files stay.
+	MaxSize int64
+	Keep    uint
+	// Verbose overrides the level with debug, set by -v.
+	Verbose bool
+}
+
+// RegisterFlags registers the logging flags, current values
+// of the options are defaults.
+func (o *Options) RegisterFlags(fs *flag.FlagSet) {
+	if len(o.Output) == 0 {
+		o.Output = "stderr"
+	}
+	if len(o.Level) == 0 {
+		o.Level = "info"
+	}
+	if len(o.Format) == 0 {
+		o.Format = "console"
+	}
+	if o.MaxSize == 0 {
+		o.MaxSize = 10 << 20
+	}
+	if o.Keep == 0 {
+		o.Keep = 3
+	}
+
+	fs.StringVar(&o.Output, "log", o.Output, "where to write logs: stderr, syslog, journald or file:/path")
+	fs.StringVar(&o.Level, "log-level", o.Level, "log level: debug, info, warn or error")
+	fs.BoolVar(&o.Verbose, "v", o.Verbose, "log debug messages, same as -log-level debug")
+	fs.StringVar(&o.Format, "log-format", o.Format, "log format: console or json")
+	fs.Int64Var(&o.MaxSize, "log-max-size", o.MaxSize, "rotate the log file when it grows over this many bytes, 0 to disable")
+	fs.UintVar(&o.Keep, "log-keep", o.Keep, "how many rotated log files to keep")
+}
+
+// New returns the logger configured by options.
+func (o *Options) New() (*zap.Logger, error) {
+	level := o.Level
+	if o.Verbose {
+		level = "debug"
+	}
+
+	var lvl zapcore.Level
+	if err := lvl.Set(level); err != nil {
+		return nil, err
+	}
+
+	encoderCfg := zap.NewProductionEncoderConfig()
+	encoderCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	var enc zapcore.Encoder
+	switch o.Format {
+	case "console":
+		enc = zapcore.NewConsoleEncoder(encoderCfg)
+	case "json":
+		enc = zapcore.NewJSONEncoder(encoderCfg)
+	default:
+		return nil, fmt.Errorf("unsupported log format `%s`, expected console or json", o.Format)
+	}
+
+	var core zapcore.Core
+	switch {
+	case o.Output == "stderr":
+		core = zapcore.NewCore(enc, zapcore.Lock(os.Stderr), lvl)
+	case o.Output == "syslog":
+		w, err := newSyslogWriter(o.Name)
+		if err != nil {
+			return nil, fmt.Errorf("cannot connect to syslog: %v", err)
+		}
+		core = newLeveledCore(enc, w, lvl)
+	case o.Output == "journald":
+		w, err := newJournalWriter(o.Name)
+		if err != nil {
+			return nil, fmt.Errorf("cannot connect to journald: %v", err)
+		}
+		core = newLeveledCore(enc, w, lvl)
+	case strings.HasPrefix(o.Output, "file:") && len(o.Output) > len("file:"):
+		f, err := openRotatingFile(strings.TrimPrefix(o.Output, "file:"), o.MaxSize, o.Keep)
+		if err != nil {
+			return nil, err
+		}
+		core = zapcore.NewCore(enc, f, lvl)
+	default:
+		return nil, fmt.Errorf("unsupported log destination `%s`, expected stderr, syslog, journald or file:/path", o.Output)
+	}
+
+	return zap.New(core, zap.AddCaller(), zap.ErrorOutput(zapcore.Lock(os.Stderr))), nil
+}
@@ -0,0 +1,112 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	influx "github.com/influxdata/influxdb/client"
+)
+
+const influxTimeout = 30 * time.Second
+
+// influxSink writes points using InfluxDB 1.x API.
+type influxSink struct {
+	url       string
+	database  string
+	retention string
+	username  string
+	password  string
+}
+
+func (s *influxSink) Write(points []Point) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	u, err := url.Parse(s.url)
+	if err != nil {
+		return fmt.Errorf("cannot parse influx url: %v", err)
+	}
+
+	client, err := influx.NewClient(influx.Config{
+		URL:      *u,
+		Username: s.username,
+		Password: s.password,
+		Timeout:  influxTimeout,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot create influx client: %v", err)
+	}
+
+	var infPoints []influx.Point
+	for _, p := range points {
+		infPoints = append(infPoints, influx.Point{
+			Measurement: p.Measurement,
+			Tags:        nonEmptyTags(p.Tags),
+			Fields:      p.Fields,
+			Time:        p.Time,
+			Precision:   "s",
+		})
+	}
+
+	_, err = client.Write(influx.BatchPoints{
+		Database:        s.database,
+		RetentionPolicy: s.retention,
+		Precision:       "s",
+		Points:          infPoints,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot write influx point: %v", err)
+	}
+
+	return nil
+}
+
+// influx2Sink writes points using InfluxDB 2.x API,
+// authenticating with the token.
+type influx2Sink struct {
+	url    string
+	org    string
+	bucket string
+	token  string
+}
+
+func (s *influx2Sink) Write(points []Point) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	client := influxdb2.NewClient(s.url, s.token)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), influxTimeout)
+	defer cancel()
+
+	var infPoints []*write.Point
+	for _, p := range points {
+		infPoints = append(infPoints, influxdb2.NewPoint(p.Measurement, nonEmptyTags(p.Tags), p.Fields, p.Time))
+	}
+
+	api := client.WriteAPIBlocking(s.org, s.bucket)
+	if err := api.WritePoint(ctx, infPoints...); err != nil {
+		return fmt.Errorf("cannot write influx point: %v", err)
+	}
+
+	return nil
+}
+
+// nonEmptyTags drops tags influx would refuse.
+func nonEmptyTags(tags map[string]string) map[string]string {
+	out := make(map[string]string, len(tags))
+	for k, v := range tags {
+		if len(v) > 0 {
+			out[k] = v
+		}
+	}
+
+	return out
+}
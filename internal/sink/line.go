@@ -0,0 +1,98 @@
+package sink
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+	stringEscaper      = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// LineProtocol formats the point with second precision,
+// tags and fields are sorted to keep the output stable.
+func LineProtocol(p Point) string {
+	b := strings.Builder{}
+	b.WriteString(measurementEscaper.Replace(p.Measurement))
+
+	for _, k := range sortedKeys(p.Tags) {
+		// influx refuses empty tag values
+		if len(p.Tags[k]) == 0 {
+			continue
+		}
+
+		b.WriteString("," + tagEscaper.Replace(k) + "=" + tagEscaper.Replace(p.Tags[k]))
+	}
+
+	fields := make([]string, 0, len(p.Fields))
+	for k := range p.Fields {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	for i, k := range fields {
+		if i == 0 {
+			b.WriteString(" ")
+		} else {
+			b.WriteString(",")
+		}
+
+		b.WriteString(tagEscaper.Replace(k) + "=" + fieldValue(p.Fields[k]))
+	}
+
+	if !p.Time.IsZero() {
+		b.WriteString(" " + strconv.FormatInt(p.Time.Unix(), 10))
+	}
+
+	return b.String()
+}
+
+func fieldValue(v interface{}) string {
+	switch v := v.(type) {
+	case int:
+		return strconv.Itoa(v) + "i"
+	case int64:
+		return strconv.FormatInt(v, 10) + "i"
+	case uint:
+		return strconv.FormatUint(uint64(v), 10) + "i"
+	case uint64:
+		return strconv.FormatUint(v, 10) + "i"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return `"` + stringEscaper.Replace(v) + `"`
+	default:
+		return `"` + stringEscaper.Replace(fmt.Sprint(v)) + `"`
+	}
+}
+
+// NewLineWriter returns a sink printing points in line protocol,
+// e.g. to stdout for the telegraf exec input.
+func NewLineWriter(w io.Writer) Sink {
+	return SinkFunc(func(points []Point) error {
+		bw := bufio.NewWriter(w)
+		for _, p := range points {
+			bw.WriteString(LineProtocol(p))
+			bw.WriteByte('\n')
+		}
+
+		return bw.Flush()
+	})
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
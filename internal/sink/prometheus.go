@@ -0,0 +1,148 @@
+package sink
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+var invalidMetricChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// Exporter is a prometheus collector exposing numeric fields of the
+// latest points as "<measurement>_<field>" gauges labeled by tags,
+// the last point wins if several ones have the same tags.
+type Exporter struct {
+	// pointsDesc describes the number of exported points, the only
+	// metric known before points are written. Collectors having no
+	// descriptors cannot be registered.
+	pointsDesc *prometheus.Desc
+
+	mu      sync.Mutex
+	points  int
+	metrics []prometheus.Metric
+}
+
+// NewExporter returns an exporter of points of the tool, the tool
+// label tells exporters of tools sharing a registry apart.
+func NewExporter(tool string) *Exporter {
+	return &Exporter{
+		pointsDesc: prometheus.NewDesc("sonm_exported_points", "Number of points written by the latest poll.",
+			nil, prometheus.Labels{"tool": tool}),
+	}
+}
+
+func (e *Exporter) Write(points []Point) error {
+	// series of a metric must have the same label names,
+	// tags missing from a point are exported as empty
+	labels := map[string]map[string]bool{}
+	for _, p := range points {
+		for field := range p.Fields {
+			name := exportedName(p.Measurement, field)
+			if labels[name] == nil {
+				labels[name] = map[string]bool{}
+			}
+			for k := range p.Tags {
+				labels[name][invalidMetricChars.ReplaceAllString(k, "_")] = true
+			}
+		}
+	}
+
+	var metrics []prometheus.Metric
+	series := map[string]int{}
+	for _, p := range points {
+		tags := map[string]string{}
+		for k, v := range p.Tags {
+			tags[invalidMetricChars.ReplaceAllString(k, "_")] = v
+		}
+
+		for field, v := range p.Fields {
+			value, ok := numericValue(v)
+			if !ok {
+				continue
+			}
+
+			name := exportedName(p.Measurement, field)
+			var names, values []string
+			for k := range labels[name] {
+				names = append(names, k)
+			}
+			sort.Strings(names)
+			for _, k := range names {
+				values = append(values, tags[k])
+			}
+
+			desc := prometheus.NewDesc(name, fmt.Sprintf("Field %s of %s points.", field, p.Measurement), names, nil)
+			m, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, value, values...)
+			if err != nil {
+				return err
+			}
+
+			key := name + "\xff" + strings.Join(values, "\xff")
+			if i, ok := series[key]; ok {
+				metrics[i] = m
+				continue
+			}
+
+			series[key] = len(metrics)
+			metrics = append(metrics, m)
+		}
+	}
+
+	e.mu.Lock()
+	e.points = len(points)
+	e.metrics = metrics
+	e.mu.Unlock()
+
+	return nil
+}
+
+// Describe sends the descriptor of the points number only,
+// other metrics depend on points.
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- e.pointsDesc
+}
+
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(e.pointsDesc, prometheus.GaugeValue, float64(e.points))
+	for _, m := range e.metrics {
+		ch <- m
+	}
+}
+
+func exportedName(measurement, field string) string {
+	return invalidMetricChars.ReplaceAllString(measurement+"_"+field, "_")
+}
+
+// pushgatewaySink replaces the group of the job and instance on
+// the pushgateway with the latest points, for one-shot cron runs.
+type pushgatewaySink struct {
+	url      string
+	job      string
+	instance string
+}
+
+func (s *pushgatewaySink) Write(points []Point) error {
+	e := NewExporter(s.job)
+	if err := e.Write(points); err != nil {
+		return err
+	}
+
+	pusher := push.New(s.url, s.job).Collector(e)
+	if len(s.instance) > 0 {
+		pusher = pusher.Grouping("instance", s.instance)
+	}
+
+	if err := pusher.Push(); err != nil {
+		return fmt.Errorf("cannot push metrics to pushgateway: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+// Package sink writes points of the tools to metrics backends, a tool
+// converts its results to points once and gains every backend.
+package sink
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Point is a single measurement in the influx data model,
+// fields are numbers, bools or strings.
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]interface{}
+	Time        time.Time
+}
+
+// Sink writes points of a single poll.
+type Sink interface {
+	Write(points []Point) error
+}
+
+// SinkFunc allows using ordinary functions as sinks.
+type SinkFunc func(points []Point) error
+
+func (f SinkFunc) Write(points []Point) error {
+	return f(points)
+}
+
+// Options enable and configure backends, zero values disable them.
+type Options struct {
+	Influx          bool
+	InfluxURL       string
+	InfluxDatabase  string
+	InfluxRetention string
+	InfluxUsername  string
+	InfluxPassword  string
+	// InfluxVersion is the API version, 2.x uses the org,
+	// bucket and token instead of the database and credentials.
+	InfluxVersion uint
+	InfluxOrg     string
+	InfluxBucket  string
+	InfluxToken   string
+
+	Statsd       string
+	StatsdPrefix string
+	// StatsdName overrides metric names, see statsdSink.
+	StatsdName func(p Point, field string) string
+
+	Pushgateway  string
+	PushJob      string
+	PushInstance string
+}
+
+// RegisterFlags registers flags of all backends, current values
+// of the options are defaults, sonm-mon reads environment overrides.
+func (o *Options) RegisterFlags(fs *flag.FlagSet) {
+	if len(o.InfluxURL) == 0 {
+		o.InfluxURL = "http://127.0.0.1:8086"
+	}
+	if o.InfluxVersion == 0 {
+		o.InfluxVersion = 1
+	}
+
+	fs.BoolVar(&o.Influx, "influx", o.Influx, "write points to influx")
+	fs.StringVar(&o.InfluxURL, "influx-url", o.InfluxURL, "influx url (INFLUX_URL)")
+	fs.StringVar(&o.InfluxDatabase, "influx-db", o.InfluxDatabase, "influx database (INFLUX_DB)")
+	fs.StringVar(&o.InfluxRetention, "influx-rp", o.InfluxRetention, "influx retention policy, default if empty (INFLUX_RP)")
+	fs.StringVar(&o.InfluxUsername, "influx-user", o.InfluxUsername, "influx username (INFLUX_USER)")
+	fs.StringVar(&o.InfluxPassword, "influx-password", o.InfluxPassword, "influx password (INFLUX_PASSWORD)")
+	fs.UintVar(&o.InfluxVersion, "influx-version", o.InfluxVersion, "influx API version, 1 or 2")
+	fs.StringVar(&o.InfluxOrg, "influx-org", o.InfluxOrg, "influx 2.x organization (INFLUX_ORG)")
+	fs.StringVar(&o.InfluxBucket, "influx-bucket", o.InfluxBucket, "influx 2.x bucket (INFLUX_BUCKET)")
+	fs.StringVar(&o.InfluxToken, "influx-token", o.InfluxToken, "influx 2.x auth token (INFLUX_TOKEN)")
+
+	fs.StringVar(&o.Statsd, "statsd", o.Statsd, "statsd address to send gauges to, host:port")
+	fs.StringVar(&o.StatsdPrefix, "statsd-prefix", o.StatsdPrefix, "prefix of statsd metric names")
+
+	fs.StringVar(&o.Pushgateway, "pushgateway", o.Pushgateway, "prometheus pushgateway URL to push gauges to after every poll")
+	fs.StringVar(&o.PushJob, "push-job", o.PushJob, "pushgateway job label")
+	fs.StringVar(&o.PushInstance, "push-instance", o.PushInstance, "pushgateway instance label, not set if empty")
+}
+
+// Validate checks options after flags are parsed.
+func (o *Options) Validate() error {
+	if o.Influx && o.InfluxVersion != 1 && o.InfluxVersion != 2 {
+		return fmt.Errorf("influx version must be either 1 or 2")
+	}
+
+	if len(o.Pushgateway) > 0 && len(o.PushJob) == 0 {
+		return fmt.Errorf("pushgateway job must not be empty")
+	}
+
+	return nil
+}
+
+// Sinks returns enabled backends, connections are made on every write.
+func (o *Options) Sinks() []Sink {
+	var sinks []Sink
+	if o.Influx && o.InfluxVersion == 2 {
+		sinks = append(sinks, &influx2Sink{url: o.InfluxURL, org: o.InfluxOrg, bucket: o.InfluxBucket, token: o.InfluxToken})
+	} else if o.Influx {
+		sinks = append(sinks, &influxSink{
+			url:       o.InfluxURL,
+			database:  o.InfluxDatabase,
+			retention: o.InfluxRetention,
+			username:  o.InfluxUsername,
+			password:  o.InfluxPassword,
+		})
+	}
+
+	if len(o.Statsd) > 0 {
+		sinks = append(sinks, &statsdSink{addr: o.Statsd, prefix: o.StatsdPrefix, name: o.StatsdName})
+	}
+
+	if len(o.Pushgateway) > 0 {
+		sinks = append(sinks, &pushgatewaySink{url: o.Pushgateway, job: o.PushJob, instance: o.PushInstance})
+	}
+
+	return sinks
+}
+
+// Enabled reports whether any backend is enabled.
+func (o *Options) Enabled() bool {
+	return o.Influx || len(o.Statsd) > 0 || len(o.Pushgateway) > 0
+}
+
+// Outputs returns enabled backends and the exporter of the tool
+// unless it is nil, points are printed to stdout if there are none.
+func (o *Options) Outputs(exporter *Exporter) []Sink {
+	sinks := o.Sinks()
+	if exporter != nil {
+		sinks = append(sinks, exporter)
+	}
+
+	if len(sinks) == 0 {
+		sinks = append(sinks, NewLineWriter(os.Stdout))
+	}
+
+	return sinks
+}
+
+// WriteAll writes points of a poll to every sink, a failed
+// sink does not stop others.
+func WriteAll(sinks []Sink, points []Point) error {
+	var errs []string
+	for _, s := range sinks {
+		if err := s.Write(points); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d outputs failed: %s", len(errs), len(sinks), strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// Millis converts durations for fields and gauges
+// reported in milliseconds.
+func Millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,122 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// statsdPacketSize keeps datagrams below the common MTU.
+const statsdPacketSize = 1400
+
+// statsdSink sends a gauge per numeric field, named by the prefix,
+// the measurement, tag values sorted by the key and the field, e.g.
+// "dwh.dwh.deals.latency_ms:42|g". Bools are sent as 0 and 1, strings
+// are skipped. The name function replaces this naming, an empty name
+// skips the field.
+type statsdSink struct {
+	addr   string
+	prefix string
+	name   func(p Point, field string) string
+}
+
+func (s *statsdSink) Write(points []Point) error {
+	var lines []string
+	for _, p := range points {
+		for field, v := range p.Fields {
+			value, ok := numericValue(v)
+			if !ok {
+				continue
+			}
+
+			name := s.metricName(p, field)
+			if len(name) == 0 {
+				continue
+			}
+
+			lines = append(lines, fmt.Sprintf("%s:%g|g", name, value))
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	conn, err := net.Dial("udp", s.addr)
+	if err != nil {
+		return fmt.Errorf("cannot connect to statsd: %v", err)
+	}
+	defer conn.Close()
+
+	buf := bytes.Buffer{}
+	for _, line := range lines {
+		if buf.Len() > 0 && buf.Len()+len(line)+1 > statsdPacketSize {
+			if _, err := conn.Write(buf.Bytes()); err != nil {
+				return fmt.Errorf("cannot write to statsd: %v", err)
+			}
+			buf.Reset()
+		}
+
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(line)
+	}
+
+	if buf.Len() > 0 {
+		if _, err := conn.Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("cannot write to statsd: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *statsdSink) metricName(p Point, field string) string {
+	if s.name != nil {
+		return s.name(p, field)
+	}
+
+	nodes := []string{MetricNode(p.Measurement)}
+	if len(s.prefix) > 0 {
+		nodes = append([]string{s.prefix}, nodes...)
+	}
+
+	for _, k := range sortedKeys(p.Tags) {
+		if len(p.Tags[k]) > 0 {
+			nodes = append(nodes, MetricNode(p.Tags[k]))
+		}
+	}
+
+	return strings.Join(append(nodes, MetricNode(field)), ".")
+}
+
+// MetricNode replaces characters that have a special meaning
+// in statsd and graphite metric paths.
+func MetricNode(s string) string {
+	return strings.NewReplacer(".", "_", ":", "_", "|", "_", "@", "_", " ", "_").Replace(s)
+}
+
+// numericValue converts numeric and bool fields to a float.
+func numericValue(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,144 @@
+package sonmclient
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sonm-io/core/insonmnia/auth"
+	"github.com/sonm-io/core/util"
+	"github.com/sonm-io/core/util/xgrpc"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// Options tune connecting to services and retrying requests.
+type Options struct {
+	// DialTimeout limits every dial attempt, unlimited if zero.
+	DialTimeout time.Duration
+	// Retries is how many times a failed request is retried.
+	Retries uint
+	// Backoff is the delay before the first retry,
+	// doubled on every next one.
+	Backoff time.Duration
+	// Logger reports retries, nothing is logged if nil.
+	Logger *zap.Logger
+}
+
+// Conn is a connection to the service authenticated by its wallet.
+type Conn struct {
+	*grpc.ClientConn
+	// Addr is the host:port part of the target.
+	Addr string
+	// Eth is the wallet the service must authenticate with.
+	Eth common.Address
+	// Creds are the credentials used by the connection,
+	// they allow to make separate handshakes.
+	Creds credentials.TransportCredentials
+}
+
+// ParseTarget splits the "0xEth@host:port" target into parts.
+func ParseTarget(target string) (string, common.Address, error) {
+	parsed, err := auth.ParseAddr(target)
+	if err != nil {
+		return "", common.Address{}, fmt.Errorf("cannot parse string `%s` into endpoint: %v", target, err)
+	}
+
+	eth, err := parsed.ETH()
+	if err != nil {
+		return "", common.Address{}, fmt.Errorf("cannot extract eth part from addr `%s`: %v", target, err)
+	}
+
+	addr, err := parsed.Addr()
+	if err != nil {
+		return "", common.Address{}, fmt.Errorf("cannot extract IP part from addr `%s`: %v", target, err)
+	}
+
+	return addr, eth, nil
+}
+
+// traceOptions make a span of every call, see tracing.ClientHandler.
+var traceOptions = []grpc.DialOption{grpc.WithStatsHandler(tracing.ClientHandler())}
+
+// Dial connects to the service at "0xEth@host:port",
+// failed attempts are retried according to options.
+func (id *Identity) Dial(ctx context.Context, target string, opts Options) (*Conn, error) {
+	addr, eth, err := ParseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, span := tracing.StartSpan(ctx, "dial", attribute.String("addr", addr))
+	defer span.End()
+
+	creds := auth.NewWalletAuthenticator(util.NewTLS(id.TLS), eth)
+	var client *grpc.ClientConn
+	err = opts.Retry(ctx, "dial "+addr, func() error {
+		dialCtx, cancel := ctx, func() {}
+		if opts.DialTimeout > 0 {
+			dialCtx, cancel = context.WithTimeout(ctx, opts.DialTimeout)
+		}
+		defer cancel()
+
+		var err error
+		client, err = xgrpc.NewClient(dialCtx, addr, creds, traceOptions...)
+		return err
+	})
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("cannot create client connection to `%s`: %v", addr, err)
+	}
+
+	return &Conn{ClientConn: client, Addr: addr, Eth: eth, Creds: creds}, nil
+}
+
+// IsAuthError reports whether the error is caused by authentication,
+// e.g. the service presents a wallet other than expected. Such
+// errors will not disappear on retry.
+func IsAuthError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return true
+	}
+
+	return strings.Contains(err.Error(), "authentication handshake failed")
+}
+
+// Retry calls fn until it succeeds, fails with an authentication
+// error or runs out of attempts, the delay between attempts is
+// doubled every time starting from the backoff.
+func (o Options) Retry(ctx context.Context, what string, fn func() error) error {
+	backoff := o.Backoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || IsAuthError(err) || attempt >= int(o.Retries) {
+			return err
+		}
+
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+			attribute.String("request", what),
+			attribute.Int("attempt", attempt+1),
+			attribute.String("error", err.Error()),
+		))
+
+		if o.Logger != nil {
+			o.Logger.Debug("request failed, retrying", zap.String("request", what), zap.Duration("backoff", backoff), zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
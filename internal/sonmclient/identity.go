@@ -0,0 +1,94 @@
+// Package sonmclient sets up the wallet the tools authenticate with
+// and connects to SONM services authenticated by their wallets.
+package sonmclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/tls"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/sonm-io/core/util"
+)
+
+// Identity is the key of the monitor with the TLS
+// config having certificates issued for its wallet.
+type Identity struct {
+	Key *ecdsa.PrivateKey
+	TLS *tls.Config
+}
+
+// LoadKey reads monitor's identity either from an encrypted keystore
+// file or from a file with hex-encoded private key. A new key is
+// generated every run if the path is empty.
+func LoadKey(path, password string) (*ecdsa.PrivateKey, error) {
+	if len(path) == 0 {
+		return crypto.GenerateKey()
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("{")) {
+		key, err := keystore.DecryptKey(data, password)
+		if err != nil {
+			return nil, err
+		}
+
+		return key.PrivateKey, nil
+	}
+
+	return crypto.HexToECDSA(strings.TrimPrefix(string(data), "0x"))
+}
+
+// identities are created once per key file, see NewIdentity. The
+// cache owns rotation of their certificates, it lasts as long as the
+// process does, so a tool of the agent stopping does not stop it for
+// others sharing the identity.
+var (
+	identitiesMu sync.Mutex
+	identities   = map[string]*Identity{}
+	rotationCtx  = context.Background()
+)
+
+// NewIdentity loads the key, see LoadKey, and creates the TLS config,
+// certificates are rotated until the process exits. The identity
+// is created once per key file and password, so tools hosted by the
+// agent share the wallet and the certificates, a generated key too.
+func NewIdentity(path, password string) (*Identity, error) {
+	identitiesMu.Lock()
+	defer identitiesMu.Unlock()
+
+	cacheKey := path + "\x00" + password
+	if id, ok := identities[cacheKey]; ok {
+		return id, nil
+	}
+
+	key, err := LoadKey(path, password)
+	if err != nil {
+		return nil, err
+	}
+
+	_, TLSConfig, err := util.NewHitlessCertRotator(rotationCtx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	id := &Identity{Key: key, TLS: TLSConfig}
+	identities[cacheKey] = id
+	return id, nil
+}
+
+// Eth is the wallet address of the identity.
+func (id *Identity) Eth() common.Address {
+	return crypto.PubkeyToAddress(id.Key.PublicKey)
+}
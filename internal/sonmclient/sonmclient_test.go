@@ -0,0 +1,294 @@
+package sonmclient
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const testEth = "0x8125721C2413d99a33E351e1F6Bb4e56b6b633FD"
+
+// keyFiles writes the same key as a hex file and as a keystore
+// encrypted with the password, the key address is returned too.
+func keyFiles(t *testing.T, dir, password string) (hexPath, keystorePath string, eth common.Address) {
+	ks := keystore.NewKeyStore(filepath.Join(dir, "keystore"), keystore.LightScryptN, keystore.LightScryptP)
+	account, err := ks.NewAccount(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(account.URL.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := keystore.DecryptKey(data, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hexPath = filepath.Join(dir, "key.hex")
+	hexKey := "0x" + hex.EncodeToString(crypto.FromECDSA(key.PrivateKey)) + "\n"
+	if err := ioutil.WriteFile(hexPath, []byte(hexKey), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return hexPath, account.URL.Path, account.Address
+}
+
+func TestLoadKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sonmclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	hexPath, keystorePath, eth := keyFiles(t, dir, "secret")
+
+	tests := []struct {
+		name     string
+		path     string
+		password string
+		err      bool
+	}{
+		{name: "hex", path: hexPath},
+		{name: "hex ignores the password", path: hexPath, password: "whatever"},
+		{name: "keystore", path: keystorePath, password: "secret"},
+		{name: "keystore with a wrong password", path: keystorePath, password: "wrong", err: true},
+		{name: "missing file", path: filepath.Join(dir, "missing"), err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := LoadKey(tt.path, tt.password)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("got key of %s, want an error", crypto.PubkeyToAddress(key.PublicKey).Hex())
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := crypto.PubkeyToAddress(key.PublicKey); got != eth {
+				t.Errorf("got key of %s, want %s", got.Hex(), eth.Hex())
+			}
+		})
+	}
+}
+
+func TestLoadKeyGenerated(t *testing.T) {
+	a, err := LoadKey("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := LoadKey("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if crypto.PubkeyToAddress(a.PublicKey) == crypto.PubkeyToAddress(b.PublicKey) {
+		t.Errorf("the same key is generated twice")
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		addr   string
+		eth    common.Address
+		err    bool
+	}{
+		{
+			name:   "wallet and address",
+			target: testEth + "@rendezvous.livenet.sonm.com:14099",
+			addr:   "rendezvous.livenet.sonm.com:14099",
+			eth:    common.HexToAddress(testEth),
+		},
+		{
+			name:   "wallet and IP address",
+			target: testEth + "@127.0.0.1:15021",
+			addr:   "127.0.0.1:15021",
+			eth:    common.HexToAddress(testEth),
+		},
+		{
+			name:   "bare address",
+			target: "127.0.0.1:15021",
+			err:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, eth, err := ParseTarget(tt.target)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("got %s@%s, want an error", eth.Hex(), addr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if addr != tt.addr || eth != tt.eth {
+				t.Errorf("got %s@%s, want %s@%s", eth.Hex(), addr, tt.eth.Hex(), tt.addr)
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		calls int
+	}{
+		{name: "success", calls: 1},
+		{name: "unavailable is retried", err: status.Error(codes.Unavailable, "connection refused"), calls: 4},
+		{name: "unauthenticated fails fast", err: status.Error(codes.Unauthenticated, "no peer"), calls: 1},
+		{name: "permission denied fails fast", err: status.Error(codes.PermissionDenied, "wrong wallet"), calls: 1},
+		{name: "failed handshake fails fast", err: errors.New("transport: authentication handshake failed: unexpected wallet"), calls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{Retries: 3, Backoff: time.Millisecond}
+
+			calls := 0
+			err := opts.Retry(context.Background(), "test", func() error {
+				calls++
+				return tt.err
+			})
+
+			if err != tt.err {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+
+			if calls != tt.calls {
+				t.Errorf("got %d calls, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	opts := Options{Retries: 3, Backoff: 20 * time.Millisecond}
+
+	var calls []time.Time
+	opts.Retry(context.Background(), "test", func() error {
+		calls = append(calls, time.Now())
+		return errors.New("unavailable")
+	})
+
+	if len(calls) != 4 {
+		t.Fatalf("got %d calls, want 4", len(calls))
+	}
+
+	// delays may only be longer than the backoff, e.g. on a busy machine
+	want := opts.Backoff
+	for i := 1; i < len(calls); i++ {
+		if d := calls[i].Sub(calls[i-1]); d < want {
+			t.Errorf("retry %d came after %v, want at least %v", i, d, want)
+		}
+		want *= 2
+	}
+}
+
+func TestRetryCancelled(t *testing.T) {
+	opts := Options{Retries: 3, Backoff: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := opts.Retry(ctx, "test", func() error {
+		calls++
+		cancel()
+		return errors.New("unavailable")
+	})
+
+	if err == nil || calls != 1 {
+		t.Errorf("got %d calls and error %v, want a single failed call", calls, err)
+	}
+}
+
+func TestNewIdentityCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sonmclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	hexPath, keystorePath, eth := keyFiles(t, dir, "secret")
+
+	id, err := NewIdentity(keystorePath, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id.Eth() != eth {
+		t.Errorf("got identity of %s, want %s", id.Eth().Hex(), eth.Hex())
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		password string
+		// same is whether the cached identity is expected.
+		same bool
+		err  bool
+	}{
+		{name: "same key and password", path: keystorePath, password: "secret", same: true},
+		{name: "same key with a wrong password", path: keystorePath, password: "wrong", err: true},
+		{name: "same wallet from another file", path: hexPath},
+		{name: "same hex key with a different password", path: hexPath, password: "other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewIdentity(tt.path, tt.password)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("got identity of %s, want an error", got.Eth().Hex())
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if (got == id) != tt.same {
+				t.Errorf("cached identity returned: %v, want %v", got == id, tt.same)
+			}
+
+			if got.Eth() != eth {
+				t.Errorf("got identity of %s, want %s", got.Eth().Hex(), eth.Hex())
+			}
+		})
+	}
+
+	again, err := NewIdentity(hexPath, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first, _ := NewIdentity(hexPath, ""); again == first {
+		t.Errorf("identities of the key file with different passwords are the same")
+	}
+}
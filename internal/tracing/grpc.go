@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+// ClientHandler makes a client span of every gRPC call, e.g.
+// sonm.DWH/GetDeals, a child of the span in the context of the call
+// if any. The trace context is sent in the call metadata.
+//
+// Calls are traced by a stats handler rather than interceptors, so
+// the ones set by xgrpc are kept without relying on chaining, which
+// older gRPC versions sonm-io/core is built with do not have.
+func ClientHandler() stats.Handler {
+	return clientHandler{}
+}
+
+type clientHandler struct{}
+
+func (clientHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	method := strings.TrimPrefix(info.FullMethodName, "/")
+	ctx, _ = otel.Tracer(instrumentation).Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("rpc.system", "grpc"), attribute.String("rpc.method", method)),
+	)
+
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// HandleRPC ends the span of the call once it is finished,
+// both unary calls and streams end with the End stats.
+func (clientHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	end, ok := s.(*stats.End)
+	if !ok {
+		return
+	}
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int("rpc.grpc.status_code", int(status.Code(end.Error))))
+	if end.Error != nil {
+		span.RecordError(end.Error)
+		span.SetStatus(codes.Error, end.Error.Error())
+	}
+
+	span.End(trace.WithTimestamp(end.EndTime))
+}
+
+func (clientHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (clientHandler) HandleConn(ctx context.Context, s stats.ConnStats) {}
+
+// metadataCarrier allows the propagator to write gRPC metadata,
+// keys are lowercase there.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c)[strings.ToLower(key)]
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c)[strings.ToLower(key)] = []string{value}
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
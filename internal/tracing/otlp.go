@@ -0,0 +1,238 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// exportTimeout limits a single request to the collector.
+const exportTimeout = 10 * time.Second
+
+// otlpExporter posts spans to the collector over OTLP/HTTP in the
+// JSON encoding, so exporting needs neither gRPC nor protobuf
+// newer than the ones sonm-io/core is built with.
+type otlpExporter struct {
+	url    string
+	client *http.Client
+}
+
+// newOTLPExporter sends spans to http(s)://<endpoint>/v1/traces.
+func newOTLPExporter(endpoint string, insecure bool) *otlpExporter {
+	scheme := "https"
+	if insecure {
+		scheme = "http"
+	}
+
+	return &otlpExporter{
+		url:    scheme + "://" + endpoint + "/v1/traces",
+		client: &http.Client{Timeout: exportTimeout},
+	}
+}
+
+func (e *otlpExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(otlpRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("cannot export spans: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("cannot export spans: collector responded with %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Shutdown does nothing, requests are made by ExportSpans only.
+func (e *otlpExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// The types below are the JSON mapping of the OTLP trace request,
+// ids are hex encoded and 64 bit integers are strings.
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+// otlpStatus codes differ from the ones of the codes package:
+// 0 is unset, 1 is ok and 2 is error.
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// otlpRequest groups spans by their resource and tracer, spans
+// of a process usually share both.
+func otlpRequest(spans []sdktrace.ReadOnlySpan) *otlpTraces {
+	req := &otlpTraces{}
+	resources := map[*resource.Resource]int{}
+	scopes := map[*resource.Resource]map[string]int{}
+
+	for _, s := range spans {
+		res := s.Resource()
+		i, ok := resources[res]
+		if !ok {
+			i = len(req.ResourceSpans)
+			resources[res] = i
+			scopes[res] = map[string]int{}
+			req.ResourceSpans = append(req.ResourceSpans, otlpResourceSpans{
+				Resource: otlpResource{Attributes: otlpAttributes(res.Attributes())},
+			})
+		}
+
+		rs := &req.ResourceSpans[i]
+		lib := s.InstrumentationLibrary()
+		j, ok := scopes[res][lib.Name]
+		if !ok {
+			j = len(rs.ScopeSpans)
+			scopes[res][lib.Name] = j
+			rs.ScopeSpans = append(rs.ScopeSpans, otlpScopeSpans{Scope: otlpScope{Name: lib.Name, Version: lib.Version}})
+		}
+
+		rs.ScopeSpans[j].Spans = append(rs.ScopeSpans[j].Spans, newOTLPSpan(s))
+	}
+
+	return req
+}
+
+func newOTLPSpan(s sdktrace.ReadOnlySpan) otlpSpan {
+	span := otlpSpan{
+		TraceID:           s.SpanContext().TraceID().String(),
+		SpanID:            s.SpanContext().SpanID().String(),
+		Name:              s.Name(),
+		Kind:              int(s.SpanKind()),
+		StartTimeUnixNano: unixNano(s.StartTime()),
+		EndTimeUnixNano:   unixNano(s.EndTime()),
+		Attributes:        otlpAttributes(s.Attributes()),
+	}
+
+	if s.Parent().IsValid() {
+		span.ParentSpanID = s.Parent().SpanID().String()
+	}
+
+	for _, e := range s.Events() {
+		span.Events = append(span.Events, otlpEvent{
+			TimeUnixNano: unixNano(e.Time),
+			Name:         e.Name,
+			Attributes:   otlpAttributes(e.Attributes),
+		})
+	}
+
+	switch s.Status().Code {
+	case codes.Ok:
+		span.Status.Code = 1
+	case codes.Error:
+		span.Status = otlpStatus{Code: 2, Message: s.Status().Description}
+	}
+
+	return span
+}
+
+// otlpAttributes converts attributes, slices are sent as
+// their string form.
+func otlpAttributes(attrs []attribute.KeyValue) []otlpKeyValue {
+	var kvs []otlpKeyValue
+	for _, a := range attrs {
+		var v otlpAnyValue
+		switch a.Value.Type() {
+		case attribute.BOOL:
+			b := a.Value.AsBool()
+			v.BoolValue = &b
+		case attribute.INT64:
+			i := strconv.FormatInt(a.Value.AsInt64(), 10)
+			v.IntValue = &i
+		case attribute.FLOAT64:
+			f := a.Value.AsFloat64()
+			v.DoubleValue = &f
+		default:
+			s := a.Value.Emit()
+			v.StringValue = &s
+		}
+
+		kvs = append(kvs, otlpKeyValue{Key: string(a.Key), Value: v})
+	}
+
+	return kvs
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
@@ -0,0 +1,93 @@
+// Package tracing exports spans of the tools over OTLP, so a slow
+// poll is broken down into the gRPC calls it is made of. Calls are
+// traced by sonmclient connections, tools add a span per poll.
+package tracing
+
+import (
+	"context"
+	"flag"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation names the tracer of spans made by the tools.
+const instrumentation = "github.com/sshaman1101/sonm-monitoring-tools"
+
+// shutdownTimeout limits flushing spans on exit.
+const shutdownTimeout = 5 * time.Second
+
+// Options configure the exporter, tracing is disabled
+// unless the endpoint is set.
+type Options struct {
+	// Endpoint is the host:port of the OTLP/HTTP collector.
+	Endpoint string
+	// Insecure disables TLS, e.g. for a collector on localhost.
+	Insecure bool
+	// Ratio is the fraction of polls traced.
+	Ratio float64
+}
+
+// RegisterFlags registers the tracing flags, current values
+// of the options are defaults.
+func (o *Options) RegisterFlags(fs *flag.FlagSet) {
+	if o.Ratio == 0 {
+		o.Ratio = 1
+	}
+
+	fs.StringVar(&o.Endpoint, "otlp-endpoint", o.Endpoint, "OTLP/HTTP collector host:port to export traces to, usually port 4318, disabled if empty (OTEL_EXPORTER_OTLP_ENDPOINT)")
+	fs.BoolVar(&o.Insecure, "otlp-insecure", o.Insecure, "connect to the OTLP collector without TLS")
+	fs.Float64Var(&o.Ratio, "trace-ratio", o.Ratio, "fraction of polls to trace, from 0 to 1")
+}
+
+// setUp is set by the first Setup of the process, e.g. by the agent
+// before its jobs, so they export spans the way it was set up.
+var (
+	mu    sync.Mutex
+	setUp bool
+)
+
+// Setup installs the global tracer provider exporting spans of the
+// service, the returned function flushes pending spans. Nothing is
+// installed if tracing is disabled, so spans are no-ops. Only the
+// first call of the process takes effect, later ones do nothing.
+func (o *Options) Setup(service string) (func(), error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if setUp || len(o.Endpoint) == 0 {
+		setUp = true
+		return func() {}, nil
+	}
+
+	// an unavailable collector only makes spans dropped
+	exporter := newOTLPExporter(o.Endpoint, o.Insecure)
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(service))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(o.Ratio))),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	setUp = true
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		provider.Shutdown(ctx)
+	}, nil
+}
+
+// StartSpan starts a span of the tools, the caller must end it.
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, trace.WithAttributes(attrs...))
+}
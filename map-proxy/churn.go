@@ -0,0 +1,64 @@
+package mapproxy
+
+import (
+	"time"
+)
+
+const churnWindow = 24 * time.Hour
+
+type dealRecord struct {
+	id        string
+	startTime time.Time
+}
+
+// churn remembers supplier's deals seen during the last day, so deals
+// that were opened and closed between two refreshes are still counted
+// if at least one refresh has caught them. Used by the collector only.
+type churn struct {
+	deals map[string]map[string]time.Time
+}
+
+func (c *churn) observe(eth string, deals []dealRecord) {
+	if c.deals == nil {
+		c.deals = map[string]map[string]time.Time{}
+	}
+
+	if _, ok := c.deals[eth]; !ok {
+		c.deals[eth] = map[string]time.Time{}
+	}
+
+	for _, d := range deals {
+		c.deals[eth][d.id] = d.startTime
+	}
+}
+
+// rates returns how many deals the supplier has opened
+// during the last hour and the last day.
+func (c *churn) rates(eth string, now time.Time) (perHour, perDay int) {
+	for _, started := range c.deals[eth] {
+		age := now.Sub(started)
+		if age <= time.Hour {
+			perHour++
+		}
+		if age <= churnWindow {
+			perDay++
+		}
+	}
+
+	return perHour, perDay
+}
+
+// expire forgets deals that were opened before the churn window.
+func (c *churn) expire(now time.Time) {
+	for eth, deals := range c.deals {
+		for id, started := range deals {
+			if now.Sub(started) > churnWindow {
+				delete(deals, id)
+			}
+		}
+
+		if len(deals) == 0 {
+			delete(c.deals, eth)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package mapproxy
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const proxyHeaderTimeout = 5 * time.Second
+
+// trustedProxies is a list of networks allowed to tell us the real
+// client address via X-Forwarded-For or the PROXY protocol.
+type trustedProxies []*net.IPNet
+
+// parseTrustedProxies parses comma-separated list of IPs and CIDRs.
+func parseTrustedProxies(s string) (trustedProxies, error) {
+	var nets trustedProxies
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if len(v) == 0 {
+			continue
+		}
+
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("cannot parse `%s` as IP address", v)
+			}
+
+			if ip.To4() != nil {
+				v += "/32"
+			} else {
+				v += "/128"
+			}
+		}
+
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, err
+		}
+
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
+
+func (t trustedProxies) contains(ip net.IP) bool {
+	for _, n := range t {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clientIP returns the address of the client that issued the request.
+// X-Forwarded-For is honored only when the request came from a trusted
+// proxy, the chain is walked from the right until the first untrusted hop.
+func (t trustedProxies) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !t.contains(ip) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header["X-Forwarded-For"], ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+
+		host = hop.String()
+		if !t.contains(hop) {
+			break
+		}
+	}
+
+	return host
+}
+
+// proxyListener accepts PROXY protocol (v1) headers from trusted
+// proxies and reports the client address from the header as
+// the connection's remote address.
+type proxyListener struct {
+	net.Listener
+	trusted trustedProxies
+}
+
+func (l *proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok || !l.trusted.contains(addr.IP) {
+		return conn, nil
+	}
+
+	return &proxyConn{Conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+// proxyConn reads the PROXY header lazily, so a slow proxy
+// cannot block the accept loop.
+type proxyConn struct {
+	net.Conn
+	r      *bufio.Reader
+	once   sync.Once
+	remote net.Addr
+	err    error
+}
+
+func (c *proxyConn) readHeader() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+		defer c.Conn.SetReadDeadline(time.Time{})
+
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			c.err = fmt.Errorf("cannot read PROXY header: %v", err)
+			return
+		}
+
+		c.remote, c.err = parseProxyHeader(line)
+	})
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.r.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.remote != nil {
+		return c.remote
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+// parseProxyHeader parses lines like "PROXY TCP4 1.2.3.4 5.6.7.8 1111 2222\r\n",
+// nil address is returned for the "PROXY UNKNOWN" header.
+func parseProxyHeader(line string) (net.Addr, error) {
+	if !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("malformed PROXY header")
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return nil, fmt.Errorf("malformed PROXY header")
+	}
+
+	if fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("malformed PROXY header")
+	}
+
+	ip := net.ParseIP(fields[2])
+	if ip == nil {
+		return nil, fmt.Errorf("cannot parse `%s` as IP address", fields[2])
+	}
+
+	port, err := strconv.Atoi(fields[4])
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse `%s` as port: %v", fields[4], err)
+	}
+
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
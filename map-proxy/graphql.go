@@ -0,0 +1,231 @@
+package mapproxy
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+)
+
+const graphqlSchema = `
+schema {
+	query: Query
+}
+
+type Query {
+	networks: [String!]!
+	peers(network: String, lang: String): [Peer!]!
+	clusters(network: String, lang: String): [Cluster!]!
+	summary(network: String): Summary!
+	history(network: String, last: Int): [Summary!]!
+}
+
+type Peer {
+	eth: String!
+	lat: Float!
+	lon: Float!
+	country: String!
+	countryName: String!
+	city: String!
+	deals: Int!
+	dealsPerHour: Int!
+	dealsPerDay: Int!
+	income: Float!
+	cpuCount: Int!
+	gpuCount: Int!
+	ramSize: Float!
+	ethHashrate: Float!
+	gpuPrice: Float!
+	corePrice: Float!
+}
+
+type Cluster {
+	country: String!
+	countryName: String!
+	lat: Float!
+	lon: Float!
+	summary: Summary!
+}
+
+type Summary {
+	time: String!
+	peers: Int!
+	deals: Int!
+	dealsPerHour: Int!
+	dealsPerDay: Int!
+	income: Float!
+	cpuCount: Int!
+	gpuCount: Int!
+	ramSize: Float!
+}
+`
+
+// newGraphQLHandler exposes the collected data of all networks,
+// queries without a network argument use the first one.
+func newGraphQLHandler(upstreams []*upstream) http.Handler {
+	schema := graphql.MustParseSchema(graphqlSchema, &queryResolver{upstreams: upstreams})
+	h := &relay.Handler{Schema: schema}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("handling graphql request from %s\n", proxies.clientIP(r))
+		w.Header().Add("Access-Control-Allow-Origin", "*")
+		w.Header().Add("Access-Control-Allow-Headers", "Content-Type")
+		if r.Method == http.MethodOptions {
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+type networkArgs struct {
+	Network *string
+}
+
+type localizedArgs struct {
+	Network *string
+	Lang    *string
+}
+
+func (a localizedArgs) lang() string {
+	if a.Lang == nil {
+		return defaultLang
+	}
+
+	return *a.Lang
+}
+
+type queryResolver struct {
+	upstreams []*upstream
+}
+
+func (r *queryResolver) find(name *string) (*upstream, error) {
+	if name == nil {
+		return r.upstreams[0], nil
+	}
+
+	for _, u := range r.upstreams {
+		if u.Name == *name {
+			return u, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown network `%s`", *name)
+}
+
+func (r *queryResolver) Networks() []string {
+	var names []string
+	for _, u := range r.upstreams {
+		names = append(names, u.Name)
+	}
+
+	return names
+}
+
+func (r *queryResolver) Peers(args localizedArgs) ([]*peerResolver, error) {
+	u, err := r.find(args.Network)
+	if err != nil {
+		return nil, err
+	}
+
+	peers := []*peerResolver{}
+	for eth, p := range localize(u.data.get(), args.lang()) {
+		peers = append(peers, &peerResolver{eth: eth, p: p})
+	}
+
+	return peers, nil
+}
+
+func (r *queryResolver) Clusters(args localizedArgs) ([]*clusterResolver, error) {
+	u, err := r.find(args.Network)
+	if err != nil {
+		return nil, err
+	}
+
+	clusters := []*clusterResolver{}
+	for _, c := range clusterize(localize(u.data.get(), args.lang())) {
+		clusters = append(clusters, &clusterResolver{c: c})
+	}
+
+	return clusters, nil
+}
+
+func (r *queryResolver) Summary(args networkArgs) (*summaryResolver, error) {
+	u, err := r.find(args.Network)
+	if err != nil {
+		return nil, err
+	}
+
+	return &summaryResolver{s: summarize(u.data.get())}, nil
+}
+
+func (r *queryResolver) History(args struct {
+	Network *string
+	Last    *int32
+}) ([]*summaryResolver, error) {
+	u, err := r.find(args.Network)
+	if err != nil {
+		return nil, err
+	}
+
+	last := 0
+	if args.Last != nil {
+		last = int(*args.Last)
+	}
+
+	items := []*summaryResolver{}
+	for _, s := range u.history.last(last) {
+		items = append(items, &summaryResolver{s: s})
+	}
+
+	return items, nil
+}
+
+type peerResolver struct {
+	eth string
+	p   PeerPoint
+}
+
+func (r *peerResolver) Eth() string          { return r.eth }
+func (r *peerResolver) Lat() float64         { return r.p.Lat }
+func (r *peerResolver) Lon() float64         { return r.p.Lon }
+func (r *peerResolver) Country() string      { return r.p.Country }
+func (r *peerResolver) CountryName() string  { return r.p.CountryName }
+func (r *peerResolver) City() string         { return r.p.City }
+func (r *peerResolver) Deals() int32         { return int32(r.p.Count) }
+func (r *peerResolver) DealsPerHour() int32  { return int32(r.p.DealsPerHour) }
+func (r *peerResolver) DealsPerDay() int32   { return int32(r.p.DealsPerDay) }
+func (r *peerResolver) Income() float64      { return r.p.Income }
+func (r *peerResolver) CpuCount() int32      { return int32(r.p.CPUCount) }
+func (r *peerResolver) GpuCount() int32      { return int32(r.p.GPUCount) }
+func (r *peerResolver) RamSize() float64     { return float64(r.p.RAMSize) }
+func (r *peerResolver) EthHashrate() float64 { return float64(r.p.EthHashrate) }
+func (r *peerResolver) GpuPrice() float64    { return r.p.GPUPrice }
+func (r *peerResolver) CorePrice() float64   { return r.p.CorePrice }
+
+type clusterResolver struct {
+	c Cluster
+}
+
+func (r *clusterResolver) Country() string           { return r.c.Country }
+func (r *clusterResolver) CountryName() string       { return r.c.CountryName }
+func (r *clusterResolver) Lat() float64              { return r.c.Lat }
+func (r *clusterResolver) Lon() float64              { return r.c.Lon }
+func (r *clusterResolver) Summary() *summaryResolver { return &summaryResolver{s: r.c.Summary} }
+
+type summaryResolver struct {
+	s Summary
+}
+
+func (r *summaryResolver) Time() string        { return r.s.Time.Format(time.RFC3339) }
+func (r *summaryResolver) Peers() int32        { return int32(r.s.Peers) }
+func (r *summaryResolver) Deals() int32        { return int32(r.s.Deals) }
+func (r *summaryResolver) DealsPerHour() int32 { return int32(r.s.DealsPerHour) }
+func (r *summaryResolver) DealsPerDay() int32  { return int32(r.s.DealsPerDay) }
+func (r *summaryResolver) Income() float64     { return r.s.Income }
+func (r *summaryResolver) CpuCount() int32     { return int32(r.s.CPUCount) }
+func (r *summaryResolver) GpuCount() int32     { return int32(r.s.GPUCount) }
+func (r *summaryResolver) RamSize() float64    { return float64(r.s.RAMSize) }
@@ -1,51 +1,83 @@
-package main
+// Package mapproxy serves SONM supplier locations for the network map.
+package mapproxy
 
 import (
 	"context"
 	"encoding/json"
 	"flag"
+	"fmt"
 	"log"
 	"math/big"
 	"net"
 	"net/http"
 	"net/http/pprof"
 	"os"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/params"
-	"github.com/oschwald/geoip2-golang"
-	"github.com/sonm-io/core/insonmnia/auth"
 	"github.com/sonm-io/core/proto"
-	"github.com/sonm-io/core/util"
-	"github.com/sonm-io/core/util/xgrpc"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/daemon"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/geoip"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/health"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/logging"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sonmclient"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/tracing"
+	"go.uber.org/zap"
 
 	_ "net/http/pprof"
 )
 
 const (
-	rvAddr     = "rendezvous.livenet.sonm.com:14099"
-	rvEth      = "0x5b7d6516fad04e10db726933bcd75447fd7b4b17"
-	dwhAddr    = "dwh.livenet.sonm.com:15021"
-	dwhEth     = "0xadffcac607a0a1b583c489977eae413a62d4bc73"
-	listedAddr = ":8090"
+	rvAddr  = "rendezvous.livenet.sonm.com:14099"
+	rvEth   = "0x5b7d6516fad04e10db726933bcd75447fd7b4b17"
+	dwhAddr = "dwh.livenet.sonm.com:15021"
+	dwhEth  = "0xadffcac607a0a1b583c489977eae413a62d4bc73"
+	// refreshInterval is how often upstreams reload peers.
+	refreshInterval = 120 * time.Second
 
 	pprofPrefix = "/debug/pprof"
 )
 
 var (
+	listenAddr   string
 	databasePath string
-	dwh          sonm.DWHClient
-	rv           sonm.RendezvousClient
-	db           *geoip2.Reader
+	networksPath string
+	defaultLang  string
+	proxiesFlag  string
+	proxyProto   bool
+	rateLimit    float64
+	rateBurst    uint
+	relaysURL    string
+	keyPath      string
+	keyPassword  string
+	db           *geoip.DB
+	proxies      trustedProxies
+	logOptions   = logging.Options{Name: "map-proxy"}
+	traceOptions tracing.Options
+	// selfHealth is ready once peers of any network are loaded
+	// and healthy while all of them refresh, see networkHealth.
+	selfHealth = health.New("map-proxy")
 )
 
+// Flags are command line flags of the tool, parsed by Run.
+var Flags = flag.NewFlagSet("sonm-mon map", flag.ExitOnError)
+
 func init() {
-	flag.StringVar(&databasePath, "db", "geo.mmdb", "path to geoip database")
-	flag.Parse()
+	Flags.StringVar(&listenAddr, "listen", ":8090", "address to serve the map endpoints at")
+	Flags.StringVar(&databasePath, "db", "geo.mmdb", "path to geoip database")
+	Flags.StringVar(&networksPath, "networks", "", "path to networks config, livenet only if empty")
+	Flags.StringVar(&defaultLang, "lang", "en", "default language for place names")
+	Flags.StringVar(&proxiesFlag, "trusted-proxies", "", "comma-separated IPs or CIDRs of trusted reverse proxies")
+	Flags.BoolVar(&proxyProto, "proxy-protocol", false, "accept PROXY protocol headers from trusted proxies")
+	Flags.Float64Var(&rateLimit, "rate-limit", 0, "requests per second a client IP may make on average, 0 to disable")
+	Flags.UintVar(&rateBurst, "rate-burst", 20, "requests a client IP may make at once, see -rate-limit")
+	Flags.StringVar(&keyPath, "key-file", "", "keystore or hex private key file, a new key is generated every run if empty")
+	Flags.StringVar(&keyPassword, "key-password", "", "keystore password (KEY_PASSWORD)")
+	Flags.StringVar(&relaysURL, "relays-url", "", "relay-mon /relays URL to show relays on the map, served at /relays")
+	logOptions.RegisterFlags(Flags)
+	traceOptions.RegisterFlags(Flags)
 }
 
 type PeerPoint struct {
@@ -57,6 +89,23 @@ type PeerPoint struct {
 	GPUCount    uint64  `json:"gpu_count"`
 	RAMSize     uint64  `json:"ram_size"`
 	EthHashrate uint64  `json:"eth_hashrate"`
+	// GPUPrice and CorePrice are the supplier's income per
+	// single GPU-hour and CPU core-hour respectively, GPU deals
+	// are priced by cards and CPU-only deals by cores.
+	GPUPrice  float64 `json:"gpu_price"`
+	CorePrice float64 `json:"core_price"`
+	Country   string  `json:"country"`
+	// City and CountryName are localized on each request.
+	City        string `json:"city"`
+	CountryName string `json:"country_name"`
+	// DealsPerHour and DealsPerDay count deals opened recently.
+	DealsPerHour int `json:"deals_per_hour"`
+	DealsPerDay  int `json:"deals_per_day"`
+
+	names placeNames
+	deals []dealRecord
+	// cpuDealCores are cores sold in CPU-only deals.
+	cpuDealCores uint64
 }
 
 type cache struct {
@@ -89,41 +138,31 @@ func (c *cache) get() map[string]PeerPoint {
 	}
 }
 
-func initConnections(ctx context.Context) {
-	key, err := crypto.GenerateKey()
-	if err != nil {
-		log.Printf("cannot generate key: %v\n", err)
-		os.Exit(1)
-	}
-
-	_, TLSConfig, err := util.NewHitlessCertRotator(ctx, key)
+func initConnections(ctx context.Context, networks []network) []*upstream {
+	id, err := sonmclient.NewIdentity(keyPath, keyPassword)
 	if err != nil {
-		log.Printf("cannot create TLS config: %v\n", err)
+		log.Printf("cannot set up identity: %v\n", err)
 		os.Exit(1)
 	}
 
-	rvCreds := auth.NewWalletAuthenticator(util.NewTLS(TLSConfig), common.HexToAddress(rvEth))
-	rvClient, err := xgrpc.NewClient(ctx, rvAddr, rvCreds)
-	if err != nil {
-		log.Printf("cannot create client connection (rv): %v\n", err)
-		os.Exit(1)
-	}
+	var upstreams []*upstream
+	for _, n := range networks {
+		u, err := newUpstream(ctx, n, id)
+		if err != nil {
+			log.Printf("cannot connect to %s: %v\n", n.Name, err)
+			os.Exit(1)
+		}
 
-	dwhCreds := auth.NewWalletAuthenticator(util.NewTLS(TLSConfig), common.HexToAddress(dwhEth))
-	dwhClient, err := xgrpc.NewClient(ctx, dwhAddr, dwhCreds)
-	if err != nil {
-		log.Printf("cannot create client connection (dwh): %v\n", err)
-		os.Exit(1)
+		upstreams = append(upstreams, u)
 	}
 
-	dwh = sonm.NewDWHClient(dwhClient)
-	rv = sonm.NewRendezvousClient(rvClient)
-
-	db, err = geoip2.Open(databasePath)
+	db, err = geoip.Open(databasePath)
 	if err != nil {
 		log.Printf("cannot open geoip db: %v\n", err)
 		os.Exit(1)
 	}
+
+	return upstreams
 }
 
 func loadDeals(ctx context.Context, dwh sonm.DWHClient, addr common.Address) (PeerPoint, error) {
@@ -141,7 +180,9 @@ func loadDeals(ctx context.Context, dwh sonm.DWHClient, addr common.Address) (Pe
 	}
 
 	p := PeerPoint{}
-	income := big.NewInt(0)
+	// GPU deals pay for cards, the cores coming with them are
+	// not priced, so prices are calculated by the deal kind
+	income, gpuIncome, cpuIncome := big.NewInt(0), big.NewInt(0), big.NewInt(0)
 	log.Printf("got %d deals for peer %s\n", len(deals.GetDeals()), addr.Hex())
 
 	for _, deal := range deals.GetDeals() {
@@ -150,134 +191,156 @@ func loadDeals(ctx context.Context, dwh sonm.DWHClient, addr common.Address) (Pe
 		p.GPUCount += deal.GetDeal().GetBenchmarks().GPUCount()
 		p.RAMSize += deal.GetDeal().GetBenchmarks().RAMSize()
 		p.EthHashrate += deal.GetDeal().GetBenchmarks().GPUEthHashrate()
-		income = big.NewInt(0).Add(income, deal.GetDeal().GetPrice().Unwrap())
+		price := deal.GetDeal().GetPrice().Unwrap()
+		income = big.NewInt(0).Add(income, price)
+		if deal.GetDeal().GetBenchmarks().GPUCount() > 0 {
+			gpuIncome = big.NewInt(0).Add(gpuIncome, price)
+		} else {
+			cpuIncome = big.NewInt(0).Add(cpuIncome, price)
+			p.cpuDealCores += deal.GetDeal().GetBenchmarks().CPUCores()
+		}
+		p.deals = append(p.deals, dealRecord{
+			id:        deal.GetDeal().GetId().Unwrap().String(),
+			startTime: deal.GetDeal().GetStartTime().Unix(),
+		})
 	}
 
-	perHour := big.NewInt(0).Mul(income, big.NewInt(3600))
-	perHourF := big.NewFloat(0).SetInt(perHour)
-	total := big.NewFloat(0).Quo(perHourF, big.NewFloat(params.Ether))
-	p.Income, _ = total.Float64()
+	p.Income = hourlySNM(income)
+
+	if p.GPUCount > 0 {
+		p.GPUPrice = hourlySNM(gpuIncome) / float64(p.GPUCount)
+	}
+
+	if p.cpuDealCores > 0 {
+		p.CorePrice = hourlySNM(cpuIncome) / float64(p.cpuDealCores)
+	}
 
-	// log.Printf("    %s -> %v $/h (%d x %d)", addr.Hex(), p.Income, p.GPUCount, p.EthHashrate)
 	return p, nil
 }
 
-func loadPeersData(ctx context.Context) (map[string]PeerPoint, error) {
-	rvCtx, cancelRv := context.WithTimeout(ctx, 60*time.Second)
-	info, err := rv.Info(rvCtx, &sonm.Empty{})
+// hourlySNM converts the deal price in wei per second to SNM per hour.
+func hourlySNM(price *big.Int) float64 {
+	v := big.NewFloat(0).SetInt(big.NewInt(0).Mul(price, big.NewInt(3600)))
+	f, _ := big.NewFloat(0).Quo(v, big.NewFloat(params.Ether)).Float64()
+	return f
+}
+
+// Run parses the arguments and runs the tool,
+// the process exits on failures.
+func Run(args []string) {
+	Flags.Parse(args)
+
+	logger, err := logOptions.New()
 	if err != nil {
-		cancelRv()
-		return nil, err
+		fmt.Fprintf(os.Stderr, "cannot create logger: %v\n", err)
+		os.Exit(1)
 	}
-	cancelRv()
-	log.Printf("total peers count from rv: %d\n", len(info.State))
-
-	// collect unique peers
-	peerIPs := map[string]string{}
-	for addr, state := range info.GetState() {
-		for _, srv := range state.GetServers() {
-			parts := strings.Split(addr, "//")
-			peerEth := common.HexToAddress(parts[1])
-
-			ip := net.ParseIP(srv.PublicAddr.Addr.Addr)
-			if ip == nil {
-				log.Printf("failed to parse `%v` as IP address\n", srv.PublicAddr.Addr.Addr)
-				continue
-			}
-
-			peerIPs[peerEth.Hex()] = ip.String()
-		}
+	defer logger.Sync()
 
-		for _, srv := range state.GetClients() {
-			parts := strings.Split(addr, "//")
-			peerEth := common.HexToAddress(parts[1])
+	// the proxy logs with the standard logger, which is redirected
+	// to the configured one, the agent redirects it to its own
+	if !daemon.Enabled() {
+		defer zap.RedirectStdLog(logger)()
+	}
+
+	stopTracing, err := traceOptions.Setup("map-proxy")
+	if err != nil {
+		log.Printf("cannot set up tracing: %v\n", err)
+		os.Exit(1)
+	}
+	defer stopTracing()
 
-			ip := net.ParseIP(srv.PublicAddr.Addr.Addr)
-			if ip == nil {
-				log.Printf("failed to parse `%v` as IP address\n", srv.PublicAddr.Addr.Addr)
-				continue
-			}
+	log.Println("starting map proxy")
+	go startPprof()
 
-			peerIPs[peerEth.Hex()] = ip.String()
-		}
+	networks, err := loadNetworks(networksPath)
+	if err != nil {
+		log.Printf("cannot load networks config: %v\n", err)
+		os.Exit(1)
 	}
 
-	log.Printf("found %d unique peers\n", len(peerIPs))
+	proxies, err = parseTrustedProxies(proxiesFlag)
+	if err != nil {
+		log.Printf("cannot parse trusted proxies list: %v\n", err)
+		os.Exit(1)
+	}
 
-	peers := map[string]PeerPoint{}
-	for eth, ipa := range peerIPs {
-		point, err := loadDeals(ctx, dwh, common.HexToAddress(eth))
-		if err != nil {
-			log.Printf("failed to query DWH: %v\n", err)
-			continue
-		}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-		rec, err := db.City(net.ParseIP(ipa))
-		if err != nil {
-			log.Printf("cannot find IP `%s` with geoip: %v\n", ipa, err)
-			continue
-		}
+	upstreams := initConnections(ctx, networks)
+	defer db.Close()
+
+	// pick up databases replaced by the geoip command
+	go db.Watch(ctx, time.Minute, func(err error) {
+		log.Printf("cannot reload geoip db: %v\n", err)
+	})
 
-		point.Lat = rec.Location.Latitude
-		point.Lon = rec.Location.Longitude
-		peers[eth] = point
+	mux := http.NewServeMux()
+	for _, u := range upstreams {
+		go u.run(ctx, refreshInterval)
+		registerHandlers(mux, "/"+u.Name, u)
 	}
 
-	return peers, nil
-}
+	// the first network is also served from the root for compatibility
+	// with clients that were written before the multi-network mode.
+	registerHandlers(mux, "", upstreams[0])
+	mux.Handle("/graphql", newGraphQLHandler(upstreams))
+	selfHealth.SetStaleAfter(3 * refreshInterval)
+	selfHealth.Register(mux)
+
+	if len(relaysURL) > 0 {
+		relays := &relaySource{url: relaysURL}
+		go relays.run(ctx, 60*time.Second)
+		mux.HandleFunc("/relays", serveRelays(relays))
+	}
 
-func main() {
-	log.Println("starting map proxy")
-	go startPprof()
+	handler := http.Handler(mux)
+	if rateLimit > 0 {
+		handler = newRateLimiter(rateLimit, rateBurst).limit(proxies, mux)
+	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	log.Printf("starting http server at %s\n", listenAddr)
+	if err := serve(handler); err != nil {
+		log.Printf("cannot serve map endpoints: %v\n", err)
+		os.Exit(1)
+	}
 
-	initConnections(ctx)
-	defer db.Close()
+	// the agent serves the endpoints, upstreams are refreshed
+	// until it exits without waiting for the proxy
+	select {}
+}
 
-	peers, err := loadPeersData(ctx)
-	if err != nil {
-		log.Printf("failed to load initial data from rv: %v\n", err)
-		return
+// serve serves the endpoints at -listen until the server fails, within
+// the agent they are mounted under /map-proxy/ and nil is returned at
+// once. PROXY protocol headers are accepted only on the own listener.
+func serve(h http.Handler) error {
+	if !proxyProto || daemon.Enabled() {
+		return daemon.Serve("map-proxy", listenAddr, h)
 	}
 
-	log.Printf("> initial peer points: %v\n", len(peers))
-	data := cache{green: peers}
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return err
+	}
 
-	tk := time.NewTicker(120 * time.Second)
-	defer tk.Stop()
+	return http.Serve(&proxyListener{Listener: listener, trusted: proxies}, h)
+}
 
-	go func() {
-		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-			log.Println("handling http request")
-			w.Header().Add("Content-Type", "application/json")
-			w.Header().Add("Access-Control-Allow-Origin", "*")
+func registerHandlers(mux *http.ServeMux, prefix string, u *upstream) {
+	mux.HandleFunc(prefix+"/", servePoints(u))
+	mux.HandleFunc(prefix+"/prices", servePrices(u))
+}
 
-			points := data.get()
-			b, _ := json.Marshal(points)
-			w.Write(b)
-		})
+func servePoints(u *upstream) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("handling http request for %s from %s\n", u.Name, proxies.clientIP(r))
+		w.Header().Add("Content-Type", "application/json")
+		w.Header().Add("Access-Control-Allow-Origin", "*")
 
-		log.Printf("starting http server at %s\n", listedAddr)
-		log.Fatal(http.ListenAndServe(listedAddr, nil))
-	}()
-
-	for {
-		select {
-		case <-ctx.Done():
-			log.Println("context cancelled")
-			os.Exit(0)
-		case <-tk.C:
-			peers, err := loadPeersData(ctx)
-			if err != nil {
-				log.Printf("failed to update peers list: %v\n", err)
-				continue
-			}
-
-			log.Printf("> loaded %d peer points\n", len(peers))
-			data.update(peers)
-		}
+		points := localize(u.data.get(), requestLang(r))
+		b, _ := json.Marshal(points)
+		w.Write(b)
 	}
 }
 
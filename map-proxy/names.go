@@ -0,0 +1,43 @@
+package mapproxy
+
+import (
+	"net/http"
+)
+
+// placeNames keeps names of the peer's location in all languages
+// provided by the geoip database.
+type placeNames struct {
+	city    map[string]string
+	country map[string]string
+}
+
+// localizedName falls back to english when there is
+// no name for the requested language.
+func localizedName(names map[string]string, lang string) string {
+	if name, ok := names[lang]; ok {
+		return name
+	}
+
+	return names["en"]
+}
+
+// localize returns a copy of the points with place names in the given language.
+func localize(points map[string]PeerPoint, lang string) map[string]PeerPoint {
+	localized := make(map[string]PeerPoint, len(points))
+	for eth, p := range points {
+		p.City = localizedName(p.names.city, lang)
+		p.CountryName = localizedName(p.names.country, lang)
+		localized[eth] = p
+	}
+
+	return localized
+}
+
+// requestLang extracts the `?lang=` parameter, e.g. "ru" or "pt-BR".
+func requestLang(r *http.Request) string {
+	if lang := r.URL.Query().Get("lang"); len(lang) > 0 {
+		return lang
+	}
+
+	return defaultLang
+}
@@ -0,0 +1,251 @@
+package mapproxy
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sonm-io/core/proto"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/daemon"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sonmclient"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"gopkg.in/yaml.v2"
+)
+
+// network describes a set of upstream services the map is collected from.
+type network struct {
+	Name    string `yaml:"name"`
+	RvAddr  string `yaml:"rv_addr"`
+	RvEth   string `yaml:"rv_eth"`
+	DWHAddr string `yaml:"dwh_addr"`
+	DWHEth  string `yaml:"dwh_eth"`
+}
+
+var livenet = network{
+	Name:    "livenet",
+	RvAddr:  rvAddr,
+	RvEth:   rvEth,
+	DWHAddr: dwhAddr,
+	DWHEth:  dwhEth,
+}
+
+// loadNetworks reads the list of networks to serve, the first one is
+// also served from the root path. Only livenet is used if path is empty.
+//
+// networks:
+//   - name: testnet
+//     rv_addr: rendezvous.testnet.example.com:14099
+//     rv_eth: 0x...
+//     dwh_addr: dwh.testnet.example.com:15021
+//     dwh_eth: 0x...
+func loadNetworks(path string) ([]network, error) {
+	if len(path) == 0 {
+		return []network{livenet}, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := struct {
+		Networks []network `yaml:"networks"`
+	}{}
+
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Networks) == 0 {
+		return nil, fmt.Errorf("no networks defined in %s", path)
+	}
+
+	seen := map[string]bool{}
+	for _, n := range cfg.Networks {
+		if len(n.Name) == 0 || strings.Contains(n.Name, "/") {
+			return nil, fmt.Errorf("invalid network name `%s`", n.Name)
+		}
+
+		if seen[n.Name] {
+			return nil, fmt.Errorf("network `%s` is defined twice", n.Name)
+		}
+
+		if len(n.RvAddr) == 0 || len(n.DWHAddr) == 0 {
+			return nil, fmt.Errorf("network `%s` has no rv or dwh address", n.Name)
+		}
+
+		seen[n.Name] = true
+	}
+
+	return cfg.Networks, nil
+}
+
+// networkHealth keeps results of the latest refresh of every network,
+// so a network refreshed fine does not hide another one failing. The
+// proxy is healthy while all networks refresh and ready once any of
+// them is loaded.
+type networkHealth struct {
+	mu   sync.Mutex
+	errs map[string]error
+}
+
+var upstreamsHealth = &networkHealth{errs: map[string]error{}}
+
+func (h *networkHealth) record(name string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.errs[name] = err
+
+	var failed []string
+	for n, err := range h.errs {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", n, err))
+		}
+	}
+
+	if err == nil {
+		selfHealth.SetReady(true)
+	}
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		selfHealth.Record(fmt.Errorf("%s", strings.Join(failed, "; ")))
+		return
+	}
+
+	selfHealth.Record(nil)
+}
+
+// upstream holds clients and collected data for the single network.
+type upstream struct {
+	network
+	rv      sonm.RendezvousClient
+	dwh     sonm.DWHClient
+	data    cache
+	history history
+	churn   churn
+}
+
+func newUpstream(ctx context.Context, n network, id *sonmclient.Identity) (*upstream, error) {
+	rvClient, err := id.Dial(ctx, n.RvEth+"@"+n.RvAddr, sonmclient.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot create client connection (rv): %v", err)
+	}
+
+	dwhClient, err := id.Dial(ctx, n.DWHEth+"@"+n.DWHAddr, sonmclient.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot create client connection (dwh): %v", err)
+	}
+
+	return &upstream{
+		network: n,
+		rv:      sonm.NewRendezvousClient(rvClient.ClientConn),
+		dwh:     sonm.NewDWHClient(dwhClient.ClientConn),
+	}, nil
+}
+
+// run refreshes network's data with the given interval until
+// the context is cancelled.
+func (u *upstream) run(ctx context.Context, interval time.Duration) {
+	daemon.Loop(ctx, interval, func() {
+		u.refresh(ctx)
+	})
+
+	log.Printf("[%s] context cancelled\n", u.Name)
+}
+
+func (u *upstream) refresh(ctx context.Context) {
+	ctx, span := tracing.StartSpan(ctx, "refresh", attribute.String("network", u.Name))
+	defer span.End()
+
+	peers, err := u.loadPeersData(ctx)
+	upstreamsHealth.record(u.Name, err)
+	if err != nil {
+		log.Printf("[%s] failed to update peers list: %v\n", u.Name, err)
+		return
+	}
+
+	log.Printf("[%s] loaded %d peer points\n", u.Name, len(peers))
+	u.data.update(peers)
+	u.history.push(summarize(peers))
+}
+
+func (u *upstream) loadPeersData(ctx context.Context) (map[string]PeerPoint, error) {
+	rvCtx, cancelRv := context.WithTimeout(ctx, 60*time.Second)
+	info, err := u.rv.Info(rvCtx, &sonm.Empty{})
+	if err != nil {
+		cancelRv()
+		return nil, err
+	}
+	cancelRv()
+	log.Printf("[%s] total peers count from rv: %d\n", u.Name, len(info.State))
+
+	// collect unique peers
+	peerIPs := map[string]string{}
+	for addr, state := range info.GetState() {
+		for _, srv := range state.GetServers() {
+			parts := strings.Split(addr, "//")
+			peerEth := common.HexToAddress(parts[1])
+
+			ip := net.ParseIP(srv.PublicAddr.Addr.Addr)
+			if ip == nil {
+				log.Printf("failed to parse `%v` as IP address\n", srv.PublicAddr.Addr.Addr)
+				continue
+			}
+
+			peerIPs[peerEth.Hex()] = ip.String()
+		}
+
+		for _, srv := range state.GetClients() {
+			parts := strings.Split(addr, "//")
+			peerEth := common.HexToAddress(parts[1])
+
+			ip := net.ParseIP(srv.PublicAddr.Addr.Addr)
+			if ip == nil {
+				log.Printf("failed to parse `%v` as IP address\n", srv.PublicAddr.Addr.Addr)
+				continue
+			}
+
+			peerIPs[peerEth.Hex()] = ip.String()
+		}
+	}
+
+	log.Printf("[%s] found %d unique peers\n", u.Name, len(peerIPs))
+
+	now := time.Now()
+	peers := map[string]PeerPoint{}
+	for eth, ipa := range peerIPs {
+		point, err := loadDeals(ctx, u.dwh, common.HexToAddress(eth))
+		if err != nil {
+			log.Printf("failed to query DWH: %v\n", err)
+			continue
+		}
+
+		u.churn.observe(eth, point.deals)
+		point.DealsPerHour, point.DealsPerDay = u.churn.rates(eth, now)
+
+		rec, err := db.City(net.ParseIP(ipa))
+		if err != nil {
+			log.Printf("cannot find IP `%s` with geoip: %v\n", ipa, err)
+			continue
+		}
+
+		point.Lat = rec.Location.Latitude
+		point.Lon = rec.Location.Longitude
+		point.Country = rec.Country.IsoCode
+		point.names = placeNames{city: rec.City.Names, country: rec.Country.Names}
+		peers[eth] = point
+	}
+
+	u.churn.expire(now)
+	return peers, nil
+}
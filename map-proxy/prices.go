@@ -0,0 +1,85 @@
+package mapproxy
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// PriceRank is a single supplier's position in the price ranking.
+type PriceRank struct {
+	Eth     string  `json:"eth"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	Country string  `json:"country"`
+	City    string  `json:"city"`
+	Price   float64 `json:"price"`
+	Units   uint64  `json:"units"`
+}
+
+// rankPrices returns suppliers that sell at least one unit of the
+// given kind ("gpu" or "cpu") ordered from the cheapest one.
+func rankPrices(points map[string]PeerPoint, unit string) []PriceRank {
+	ranks := []PriceRank{}
+	for eth, p := range points {
+		r := PriceRank{Eth: eth, Lat: p.Lat, Lon: p.Lon, Country: p.Country, City: p.City}
+		if unit == "cpu" {
+			r.Price, r.Units = p.CorePrice, p.cpuDealCores
+		} else {
+			r.Price, r.Units = p.GPUPrice, p.GPUCount
+		}
+
+		if r.Units == 0 {
+			continue
+		}
+
+		ranks = append(ranks, r)
+	}
+
+	sort.Slice(ranks, func(i, j int) bool {
+		if ranks[i].Price == ranks[j].Price {
+			return ranks[i].Eth < ranks[j].Eth
+		}
+		return ranks[i].Price < ranks[j].Price
+	})
+
+	return ranks
+}
+
+// servePrices handles `?unit=gpu|cpu&limit=N&lang=xx` requests.
+func servePrices(u *upstream) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("handling prices request for %s from %s\n", u.Name, proxies.clientIP(r))
+
+		unit := r.URL.Query().Get("unit")
+		if len(unit) == 0 {
+			unit = "gpu"
+		}
+
+		if unit != "gpu" && unit != "cpu" {
+			http.Error(w, "unit must be either `gpu` or `cpu`", http.StatusBadRequest)
+			return
+		}
+
+		ranks := rankPrices(localize(u.data.get(), requestLang(r)), unit)
+		if v := r.URL.Query().Get("limit"); len(v) > 0 {
+			limit, err := strconv.Atoi(v)
+			if err != nil || limit < 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+
+			if limit < len(ranks) {
+				ranks = ranks[:limit]
+			}
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		w.Header().Add("Access-Control-Allow-Origin", "*")
+
+		b, _ := json.Marshal(ranks)
+		w.Write(b)
+	}
+}
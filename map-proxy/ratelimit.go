@@ -0,0 +1,97 @@
+package mapproxy
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// rateSweepInterval is how often buckets of idle clients are dropped.
+const rateSweepInterval = time.Minute
+
+// rateLimiter limits requests of every client IP with a token bucket,
+// buckets refilled completely are dropped, so idle clients do not
+// stay in memory.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	swept   time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter allows rate requests per second of a client
+// on average and up to burst requests at once.
+func newRateLimiter(rate float64, burst uint) *rateLimiter {
+	if burst == 0 {
+		burst = 1
+	}
+
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: map[string]*tokenBucket{},
+	}
+}
+
+// allow takes a token of the client, false is returned if there is none.
+func (l *rateLimiter) allow(client string) bool {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.swept) >= rateSweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// sweep drops buckets which would be full by now,
+// they are the same as buckets of new clients.
+func (l *rateLimiter) sweep(now time.Time) {
+	l.swept = now
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	for client, b := range l.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// limit responds with 429 to clients over the limit, the client is
+// the one reported by trusted proxies, see clientIP.
+func (l *rateLimiter) limit(proxies trustedProxies, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !l.allow(proxies.clientIP(r)) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
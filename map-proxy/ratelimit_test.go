@@ -0,0 +1,125 @@
+package mapproxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterClients(t *testing.T) {
+	proxies, err := parseTrustedProxies("10.0.0.1, 192.168.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		// client is the address the limit applies to.
+		client string
+	}{
+		{
+			name:       "direct client",
+			remoteAddr: "1.2.3.4:5000",
+			client:     "1.2.3.4",
+		},
+		{
+			name:       "forwarded by a trusted proxy",
+			remoteAddr: "10.0.0.1:5000",
+			forwarded:  "1.2.3.4",
+			client:     "1.2.3.4",
+		},
+		{
+			name:       "forwarded through a chain of trusted proxies",
+			remoteAddr: "10.0.0.1:5000",
+			forwarded:  "1.2.3.4, 192.168.1.1",
+			client:     "1.2.3.4",
+		},
+		{
+			name:       "spoofed hops left of the first untrusted one are ignored",
+			remoteAddr: "10.0.0.1:5000",
+			forwarded:  "5.6.7.8, 1.2.3.4",
+			client:     "1.2.3.4",
+		},
+		{
+			name:       "forwarded by an untrusted proxy",
+			remoteAddr: "1.2.3.4:5000",
+			forwarded:  "5.6.7.8",
+			client:     "1.2.3.4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newRateLimiter(1, 1)
+			now := time.Unix(1000, 0)
+			l.now = func() time.Time { return now }
+
+			h := l.limit(proxies, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			get := func() int {
+				r := httptest.NewRequest("GET", "/", nil)
+				r.RemoteAddr = tt.remoteAddr
+				if len(tt.forwarded) > 0 {
+					r.Header.Set("X-Forwarded-For", tt.forwarded)
+				}
+
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+				return w.Code
+			}
+
+			if code := get(); code != http.StatusOK {
+				t.Fatalf("first request: got %d, want %d", code, http.StatusOK)
+			}
+
+			if code := get(); code != http.StatusTooManyRequests {
+				t.Fatalf("second request: got %d, want %d", code, http.StatusTooManyRequests)
+			}
+
+			if _, ok := l.buckets[tt.client]; !ok || len(l.buckets) != 1 {
+				t.Errorf("buckets = %v, want the one of %s", l.buckets, tt.client)
+			}
+
+			if !l.allow("9.9.9.9") {
+				t.Errorf("another client is limited")
+			}
+		})
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	l := newRateLimiter(2, 3)
+	now := time.Unix(1000, 0)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if !l.allow("1.2.3.4") {
+			t.Fatalf("request %d of the burst is limited", i+1)
+		}
+	}
+
+	if l.allow("1.2.3.4") {
+		t.Fatalf("request over the burst is allowed")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if !l.allow("1.2.3.4") {
+		t.Fatalf("request is limited after a token is refilled")
+	}
+
+	if l.allow("1.2.3.4") {
+		t.Fatalf("request is allowed before the next token is refilled")
+	}
+
+	// the bucket is full again long before the sweep
+	now = now.Add(rateSweepInterval)
+	if !l.allow("5.6.7.8") {
+		t.Fatalf("request of a new client is limited")
+	}
+
+	if _, ok := l.buckets["1.2.3.4"]; ok {
+		t.Errorf("bucket of the idle client is kept")
+	}
+}
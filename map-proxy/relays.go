@@ -0,0 +1,122 @@
+package mapproxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const relaysTimeout = 30 * time.Second
+
+// RelayPoint is a relay node published by relay-mon, rendered
+// on the map as a distinct marker along with suppliers.
+type RelayPoint struct {
+	// Type is always "relay" to tell markers apart on the client.
+	Type        string    `json:"type"`
+	Endpoint    string    `json:"endpoint"`
+	Cluster     string    `json:"cluster,omitempty"`
+	Time        time.Time `json:"time"`
+	Up          bool      `json:"up"`
+	Lat         float64   `json:"lat"`
+	Lon         float64   `json:"lon"`
+	City        string    `json:"city,omitempty"`
+	Country     string    `json:"country,omitempty"`
+	Members     int       `json:"members"`
+	ConnCurrent uint64    `json:"conn_current"`
+	Capacity    uint64    `json:"capacity,omitempty"`
+	Utilization float64   `json:"utilization,omitempty"`
+}
+
+// relaySource polls relays from the relay-mon /relays endpoint.
+type relaySource struct {
+	url string
+
+	mu     sync.Mutex
+	relays []RelayPoint
+}
+
+func (s *relaySource) run(ctx context.Context, interval time.Duration) {
+	s.refresh(ctx)
+
+	tk := time.NewTicker(interval)
+	defer tk.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tk.C:
+			s.refresh(ctx)
+		}
+	}
+}
+
+func (s *relaySource) refresh(ctx context.Context) {
+	relays, err := s.load(ctx)
+	if err != nil {
+		log.Printf("failed to update relays list: %v\n", err)
+		return
+	}
+
+	log.Printf("loaded %d relay points\n", len(relays))
+	s.mu.Lock()
+	s.relays = relays
+	s.mu.Unlock()
+}
+
+func (s *relaySource) load(ctx context.Context) ([]RelayPoint, error) {
+	ctx, cancel := context.WithTimeout(ctx, relaysTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("relay-mon responded with %s", resp.Status)
+	}
+
+	var relays []RelayPoint
+	if err := json.NewDecoder(resp.Body).Decode(&relays); err != nil {
+		return nil, err
+	}
+
+	for i := range relays {
+		relays[i].Type = "relay"
+	}
+
+	return relays, nil
+}
+
+func (s *relaySource) get() []RelayPoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.relays == nil {
+		return []RelayPoint{}
+	}
+
+	return s.relays
+}
+
+func serveRelays(s *relaySource) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("handling relays request from %s\n", proxies.clientIP(r))
+		w.Header().Add("Content-Type", "application/json")
+		w.Header().Add("Access-Control-Allow-Origin", "*")
+
+		b, _ := json.Marshal(s.get())
+		w.Write(b)
+	}
+}
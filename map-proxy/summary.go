@@ -0,0 +1,115 @@
+package mapproxy
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// historySize is how many summaries are kept per network,
+// a day long with the default refresh interval.
+const historySize = 720
+
+// Summary aggregates peer points of a single snapshot.
+type Summary struct {
+	Time     time.Time `json:"time"`
+	Peers    int       `json:"peers"`
+	Deals    int       `json:"deals"`
+	Income   float64   `json:"income"`
+	CPUCount uint64    `json:"cpu_count"`
+	GPUCount uint64    `json:"gpu_count"`
+	RAMSize  uint64    `json:"ram_size"`
+	// DealsPerHour and DealsPerDay count deals opened recently.
+	DealsPerHour int `json:"deals_per_hour"`
+	DealsPerDay  int `json:"deals_per_day"`
+}
+
+func (s *Summary) add(p PeerPoint) {
+	s.Peers += 1
+	s.Deals += p.Count
+	s.Income += p.Income
+	s.CPUCount += p.CPUCount
+	s.GPUCount += p.GPUCount
+	s.RAMSize += p.RAMSize
+	s.DealsPerHour += p.DealsPerHour
+	s.DealsPerDay += p.DealsPerDay
+}
+
+func summarize(points map[string]PeerPoint) Summary {
+	s := Summary{Time: time.Now()}
+	for _, p := range points {
+		s.add(p)
+	}
+
+	return s
+}
+
+// Cluster groups peers located in the same country,
+// the location is the mean of peers' coordinates.
+type Cluster struct {
+	Summary
+	Country     string  `json:"country"`
+	CountryName string  `json:"country_name"`
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
+}
+
+func clusterize(points map[string]PeerPoint) []Cluster {
+	byCountry := map[string]*Cluster{}
+	for _, p := range points {
+		c, ok := byCountry[p.Country]
+		if !ok {
+			c = &Cluster{Country: p.Country, CountryName: p.CountryName}
+			byCountry[p.Country] = c
+		}
+
+		c.add(p)
+		c.Lat += p.Lat
+		c.Lon += p.Lon
+	}
+
+	clusters := make([]Cluster, 0, len(byCountry))
+	for _, c := range byCountry {
+		c.Lat /= float64(c.Peers)
+		c.Lon /= float64(c.Peers)
+		clusters = append(clusters, *c)
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].Peers == clusters[j].Peers {
+			return clusters[i].Country < clusters[j].Country
+		}
+		return clusters[i].Peers > clusters[j].Peers
+	})
+
+	return clusters
+}
+
+type history struct {
+	mu    sync.Mutex
+	items []Summary
+}
+
+func (h *history) push(s Summary) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.items = append(h.items, s)
+	if len(h.items) > historySize {
+		h.items = h.items[len(h.items)-historySize:]
+	}
+}
+
+// last returns up to n most recent summaries, oldest first.
+func (h *history) last(n int) []Summary {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if n <= 0 || n > len(h.items) {
+		n = len(h.items)
+	}
+
+	items := make([]Summary, n)
+	copy(items, h.items[len(h.items)-n:])
+	return items
+}
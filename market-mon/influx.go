@@ -0,0 +1,44 @@
+package marketmon
+
+import (
+	"math"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+)
+
+// influxPoints returns a point per orderbook side.
+func influxPoints(book *orderbook) []sink.Point {
+	var points []sink.Point
+	for _, s := range book.sides {
+		points = append(points, sink.Point{
+			Measurement: influxMeasurementFlag,
+			Tags:        map[string]string{"side": s.side},
+			Fields:      sideFields(s),
+			Time:        book.time,
+		})
+	}
+
+	return points
+}
+
+// sideFields are fields of the side's line or point,
+// percentiles of sides without orders are omitted.
+func sideFields(s *sideStats) map[string]interface{} {
+	fields := map[string]interface{}{
+		"orders":    s.orders,
+		"truncated": s.truncated,
+		"gpus":      s.gpus,
+		"cpu_cores": s.cpuCores,
+	}
+
+	for _, p := range percentiles {
+		if v := percentile(s.prices, p); !math.IsNaN(v) {
+			fields["price_"+percentileName(p)] = v
+		}
+		if v := percentile(s.gpuPrices, p); !math.IsNaN(v) {
+			fields["gpu_price_"+percentileName(p)] = v
+		}
+	}
+
+	return fields
+}
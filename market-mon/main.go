@@ -0,0 +1,197 @@
+// Package marketmon samples open orders of the SONM marketplace.
+package marketmon
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/daemon"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/health"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/logging"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sonmclient"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/tracing"
+	"go.uber.org/zap"
+)
+
+const defaultDWH = "0xadffcac607a0a1b583c489977eae413a62d4bc73@dwh.livenet.sonm.com:15021"
+
+var (
+	dwhAddrFlag      string
+	keyFileFlag      string
+	keyPasswordFlag  string
+	daemonFlag       bool
+	intervalFlag     time.Duration
+	timeoutFlag      time.Duration
+	retriesFlag      uint
+	retryBackoffFlag time.Duration
+	maxOrdersFlag    uint64
+	pageSizeFlag     uint64
+	percentilesFlag  string
+	listenFlag       string
+	alertRulesFlag   string
+
+	influxMeasurementFlag string
+)
+
+// percentiles are parsed from -percentiles.
+var percentiles []float64
+
+// Flags are command line flags of the tool, parsed by Run.
+var Flags = flag.NewFlagSet("sonm-mon market", flag.ExitOnError)
+
+// logger is used for diagnostics, results printed by
+// console sinks do not go through it.
+var logger = zap.NewNop()
+
+// logOptions are set by the -log flags.
+var logOptions = logging.Options{Name: "market-mon"}
+
+// sinkOptions configure metrics backends, see the sink package.
+var sinkOptions = sink.Options{InfluxDatabase: "sonm", StatsdPrefix: "sonm", PushJob: "market_mon"}
+
+// selfHealth tracks polls of the daemon, so the monitor can be monitored.
+var selfHealth = health.New("market-mon")
+
+// traceOptions configure exporting spans, see the tracing package.
+var traceOptions tracing.Options
+
+func init() {
+	Flags.StringVar(&dwhAddrFlag, "dwh", defaultDWH, "DWH address: 0xEth@ip:port")
+	Flags.StringVar(&keyFileFlag, "key-file", "", "keystore or hex private key file, a new key is generated every run if empty")
+	Flags.StringVar(&keyPasswordFlag, "key-password", "", "keystore password (KEY_PASSWORD)")
+	Flags.BoolVar(&daemonFlag, "daemon", false, "keep running and sample orders periodically")
+	Flags.DurationVar(&intervalFlag, "interval", 5*time.Minute, "polling interval for the daemon mode")
+	Flags.DurationVar(&timeoutFlag, "timeout", 120*time.Second, "how long sampling orders of both sides may take")
+	Flags.UintVar(&retriesFlag, "retries", 3, "how many times to retry failed DWH requests")
+	Flags.DurationVar(&retryBackoffFlag, "retry-backoff", time.Second, "delay before the first retry, doubled on every next one")
+	Flags.Uint64Var(&maxOrdersFlag, "max-orders", 10000, "sample at most this many orders of each side")
+	Flags.Uint64Var(&pageSizeFlag, "page-size", 500, "how many orders to request at once")
+	Flags.StringVar(&percentilesFlag, "percentiles", "10,50,90", "comma-separated price percentiles to report")
+	Flags.StringVar(&listenFlag, "listen", "", "serve prometheus metrics and health endpoints at the given address, implies -daemon")
+	Flags.StringVar(&alertRulesFlag, "alert-rules", "", "evaluate alert rules of the YAML file against exported metrics, implies -daemon")
+	logOptions.RegisterFlags(Flags)
+	traceOptions.RegisterFlags(Flags)
+	Flags.StringVar(&influxMeasurementFlag, "influx-measurement", "market", "measurement name of points")
+	sinkOptions.RegisterFlags(Flags)
+}
+
+// parsePercentiles parses comma-separated percentiles in the (0, 100] range.
+func parsePercentiles(s string) ([]float64, error) {
+	var ps []float64
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); len(v) == 0 {
+			continue
+		}
+
+		p, err := strconv.ParseFloat(v, 64)
+		if err != nil || p <= 0 || p > 100 {
+			return nil, fmt.Errorf("percentile `%s` is not a number in the (0, 100] range", v)
+		}
+
+		ps = append(ps, p)
+	}
+
+	return ps, nil
+}
+
+// Run parses the arguments and runs the tool,
+// the process exits on failures.
+func Run(args []string) {
+	Flags.Parse(args)
+
+	var err error
+	percentiles, err = parsePercentiles(percentilesFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot parse percentiles: %v\n", err)
+		os.Exit(1)
+	}
+
+	if pageSizeFlag == 0 {
+		fmt.Fprintln(os.Stderr, "page size cannot be zero")
+		os.Exit(1)
+	}
+
+	logger, err = logOptions.New()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot create logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer logger.Sync()
+
+	stopTracing, err := traceOptions.Setup("market-mon")
+	if err != nil {
+		logger.Error("cannot set up tracing", zap.Error(err))
+		os.Exit(1)
+	}
+	defer stopTracing()
+
+	if err := sinkOptions.Validate(); err != nil {
+		logger.Error("invalid output flags", zap.Error(err))
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	id, err := sonmclient.NewIdentity(keyFileFlag, keyPasswordFlag)
+	if err != nil {
+		logger.Error("cannot set up identity", zap.Error(err))
+		os.Exit(1)
+	}
+
+	logger.Info("using identity", zap.String("eth", id.Eth().Hex()))
+
+	opts := sonmclient.Options{DialTimeout: timeoutFlag, Retries: retriesFlag, Backoff: retryBackoffFlag, Logger: logger}
+	conn, err := id.Dial(ctx, dwhAddrFlag, opts)
+	if err != nil {
+		logger.Error("cannot connect to DWH", zap.Error(err))
+		os.Exit(1)
+	}
+	defer conn.ClientConn.Close()
+
+	s := newSampler(conn, opts)
+
+	tool := &daemon.Tool{
+		Name:       "market-mon",
+		Logger:     logger,
+		Health:     selfHealth,
+		Daemon:     daemonFlag,
+		Interval:   intervalFlag,
+		Timeout:    timeoutFlag,
+		Listen:     listenFlag,
+		AlertRules: alertRulesFlag,
+	}
+
+	backends := sinkOptions.Outputs(tool.Exporter())
+	err = tool.Run(ctx, func(ctx context.Context) error {
+		return poll(ctx, s, backends)
+	})
+	if err != nil {
+		logger.Error("run failed", zap.Error(err))
+		logger.Sync()
+		os.Exit(1)
+	}
+}
+
+// poll samples both sides of the orderbook and writes results,
+// nothing is written unless both sides are sampled.
+func poll(ctx context.Context, s *sampler, backends []sink.Sink) error {
+	ctx, span := tracing.StartSpan(ctx, "poll")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, timeoutFlag)
+	defer cancel()
+
+	book, err := s.sample(ctx)
+	if err != nil {
+		return err
+	}
+
+	return sink.WriteAll(backends, influxPoints(book))
+}
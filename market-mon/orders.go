@@ -0,0 +1,146 @@
+package marketmon
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/sonm-io/core/proto"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sonmclient"
+	"go.uber.org/zap"
+)
+
+// sides are the orderbook sides, named as the order types.
+var sides = []sonm.OrderType{sonm.OrderType_ASK, sonm.OrderType_BID}
+
+// sideStats summarizes active orders of one side of the orderbook.
+type sideStats struct {
+	side   string
+	orders int
+	// truncated is set when -max-orders are sampled,
+	// there may be more orders on the side.
+	truncated bool
+	gpus      uint64
+	cpuCores  uint64
+	// prices are per hour in SNM, gpuPrices are per GPU-hour
+	// of orders requesting or offering GPUs, both sorted.
+	prices    []float64
+	gpuPrices []float64
+}
+
+// orderbook is a single sample of both sides.
+type orderbook struct {
+	time  time.Time
+	sides []*sideStats
+}
+
+type sampler struct {
+	dwh  sonm.DWHClient
+	opts sonmclient.Options
+}
+
+func newSampler(conn *sonmclient.Conn, opts sonmclient.Options) *sampler {
+	return &sampler{dwh: sonm.NewDWHClient(conn.ClientConn), opts: opts}
+}
+
+func (s *sampler) sample(ctx context.Context) (*orderbook, error) {
+	book := &orderbook{time: time.Now()}
+	for _, side := range sides {
+		stats, err := s.sampleSide(ctx, side)
+		if err != nil {
+			return nil, fmt.Errorf("cannot sample %s orders: %v", side, err)
+		}
+
+		book.sides = append(book.sides, stats)
+	}
+
+	return book, nil
+}
+
+// sampleSide loads active orders page by page up to -max-orders.
+func (s *sampler) sampleSide(ctx context.Context, side sonm.OrderType) (*sideStats, error) {
+	stats := &sideStats{side: side.String()}
+	for offset := uint64(0); offset < maxOrdersFlag; offset += pageSizeFlag {
+		var reply *sonm.DWHOrdersReply
+		err := s.opts.Retry(ctx, fmt.Sprintf("%s orders at %d", side, offset), func() error {
+			var err error
+			reply, err = s.dwh.GetOrders(ctx, &sonm.OrdersRequest{
+				Type:   side,
+				Status: sonm.OrderStatus_ORDER_ACTIVE,
+				Limit:  pageSizeFlag,
+				Offset: offset,
+			})
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, o := range reply.GetOrders() {
+			if uint64(stats.orders) < maxOrdersFlag {
+				stats.add(o.GetOrder())
+			}
+		}
+
+		if uint64(len(reply.GetOrders())) < pageSizeFlag {
+			break
+		}
+	}
+
+	stats.truncated = uint64(stats.orders) >= maxOrdersFlag
+	if stats.truncated {
+		logger.Warn("orders sample is truncated", zap.String("side", stats.side), zap.Uint64("max", maxOrdersFlag))
+	}
+
+	sort.Float64s(stats.prices)
+	sort.Float64s(stats.gpuPrices)
+	return stats, nil
+}
+
+func (s *sideStats) add(order *sonm.Order) {
+	b := order.GetBenchmarks()
+	s.orders++
+	s.gpus += b.GPUCount()
+	s.cpuCores += b.CPUCores()
+
+	price := perHour(order.GetPrice().Unwrap())
+	s.prices = append(s.prices, price)
+	if b.GPUCount() > 0 {
+		s.gpuPrices = append(s.gpuPrices, price/float64(b.GPUCount()))
+	}
+}
+
+// perHour converts the price in wei per second to SNM per hour.
+func perHour(price *big.Int) float64 {
+	if price == nil {
+		return 0
+	}
+
+	perHour := big.NewFloat(0).SetInt(big.NewInt(0).Mul(price, big.NewInt(3600)))
+	v, _ := big.NewFloat(0).Quo(perHour, big.NewFloat(params.Ether)).Float64()
+	return v
+}
+
+// percentile returns the nearest-rank percentile of sorted values,
+// NaN if there are none.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
+
+// percentileName formats the percentile for field and label names.
+func percentileName(p float64) string {
+	return "p" + fmt.Sprint(p)
+}
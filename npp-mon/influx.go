@@ -0,0 +1,40 @@
+package nppmon
+
+import "github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+
+// influxPoints returns a point per peer followed by a point with totals.
+func influxPoints(result *pollResult) []sink.Point {
+	var points []sink.Point
+	for _, p := range result.probes {
+		tags := map[string]string{"target": p.target}
+		fields := map[string]interface{}{"connected": p.err == nil}
+		if p.err == nil {
+			tags["method"] = p.method
+			fields["setup_ms"] = sink.Millis(p.setup)
+		}
+
+		points = append(points, sink.Point{
+			Measurement: influxMeasurementFlag,
+			Tags:        tags,
+			Fields:      fields,
+			Time:        result.time,
+		})
+	}
+
+	totals := map[string]interface{}{
+		"total":        len(result.probes),
+		"connected":    result.connected(),
+		"success_rate": result.successRate(),
+	}
+	for method, n := range result.byMethod() {
+		totals[method] = n
+	}
+
+	points = append(points, sink.Point{
+		Measurement: influxMeasurementFlag + "_totals",
+		Fields:      totals,
+		Time:        result.time,
+	})
+
+	return points
+}
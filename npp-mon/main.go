@@ -0,0 +1,159 @@
+// Package nppmon connects to peers the way clients do, through the
+// rendezvous with a fallback to relays, and reports how many succeed,
+// which way and how fast, which rendezvous and relay health checks
+// only approximate.
+package nppmon
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/daemon"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/health"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/logging"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sonmclient"
+	"go.uber.org/zap"
+)
+
+const defaultRendezvous = "0x5b7d6516fad04e10db726933bcd75447fd7b4b17@rendezvous.livenet.sonm.com:14099"
+
+var (
+	targetsFlag     string
+	targetsFileFlag string
+	rvAddrFlag      string
+	relaysFlag      string
+	keyFileFlag     string
+	keyPasswordFlag string
+	daemonFlag      bool
+	intervalFlag    time.Duration
+	timeoutFlag     time.Duration
+	dialTimeoutFlag time.Duration
+	parallelFlag    uint
+	listenFlag      string
+	alertRulesFlag  string
+
+	influxMeasurementFlag string
+)
+
+// Flags are command line flags of the tool, parsed by Run.
+var Flags = flag.NewFlagSet("sonm-mon npp", flag.ExitOnError)
+
+// logger is used for diagnostics, results printed by
+// console sinks do not go through it.
+var logger = zap.NewNop()
+
+// logOptions are set by the -log flags.
+var logOptions = logging.Options{Name: "npp-mon"}
+
+// sinkOptions configure metrics backends, see the sink package.
+var sinkOptions = sink.Options{InfluxDatabase: "sonm", StatsdPrefix: "sonm", PushJob: "npp_mon"}
+
+// selfHealth tracks polls of the daemon, so the monitor can be monitored.
+var selfHealth = health.New("npp-mon")
+
+func init() {
+	Flags.StringVar(&targetsFlag, "targets", "", "comma-separated wallet addresses of peers to connect to")
+	Flags.StringVar(&targetsFileFlag, "targets-file", "", "file with wallet addresses of peers, one per line")
+	Flags.StringVar(&rvAddrFlag, "rv", defaultRendezvous, "rendezvous address to resolve peers on: 0xEth@ip:port")
+	Flags.StringVar(&relaysFlag, "relays", "", "comma-separated relay addresses to fall back to: ip:port, no fallback if empty")
+	Flags.StringVar(&keyFileFlag, "key-file", "", "keystore or hex private key file, a new key is generated every run if empty")
+	Flags.StringVar(&keyPasswordFlag, "key-password", "", "keystore password (KEY_PASSWORD)")
+	Flags.BoolVar(&daemonFlag, "daemon", false, "keep running and probe peers periodically")
+	Flags.DurationVar(&intervalFlag, "interval", 5*time.Minute, "polling interval for the daemon mode")
+	Flags.DurationVar(&timeoutFlag, "timeout", 5*time.Minute, "how long probing all peers may take")
+	Flags.DurationVar(&dialTimeoutFlag, "dial-timeout", 60*time.Second, "how long connecting to a single peer may take, punching and relaying included")
+	Flags.UintVar(&parallelFlag, "parallel", 10, "how many peers to connect to at once")
+	Flags.StringVar(&listenFlag, "listen", "", "serve prometheus metrics and health endpoints at the given address, implies -daemon")
+	Flags.StringVar(&alertRulesFlag, "alert-rules", "", "evaluate alert rules of the YAML file against exported metrics, implies -daemon")
+	logOptions.RegisterFlags(Flags)
+	Flags.StringVar(&influxMeasurementFlag, "influx-measurement", "npp_probe", "measurement name of points")
+	sinkOptions.RegisterFlags(Flags)
+}
+
+// Run parses the arguments and runs the tool,
+// the process exits on failures.
+func Run(args []string) {
+	Flags.Parse(args)
+
+	targets, err := loadTargets(targetsFlag, targetsFileFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot load targets: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(targets) == 0 {
+		fmt.Fprintln(os.Stderr, "no peers to connect to, set -targets or -targets-file")
+		os.Exit(1)
+	}
+
+	if parallelFlag == 0 {
+		fmt.Fprintln(os.Stderr, "parallelism cannot be zero")
+		os.Exit(1)
+	}
+
+	logger, err = logOptions.New()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot create logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer logger.Sync()
+
+	if err := sinkOptions.Validate(); err != nil {
+		logger.Error("invalid output flags", zap.Error(err))
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	id, err := sonmclient.NewIdentity(keyFileFlag, keyPasswordFlag)
+	if err != nil {
+		logger.Error("cannot set up identity", zap.Error(err))
+		os.Exit(1)
+	}
+
+	logger.Info("using identity", zap.String("eth", id.Eth().Hex()))
+
+	p, err := newProber(id, rvAddrFlag, relaysFlag)
+	if err != nil {
+		logger.Error("cannot set up NPP dialer", zap.Error(err))
+		os.Exit(1)
+	}
+	defer p.Close()
+
+	tool := &daemon.Tool{
+		Name:       "npp-mon",
+		Logger:     logger,
+		Health:     selfHealth,
+		Daemon:     daemonFlag,
+		Interval:   intervalFlag,
+		Timeout:    timeoutFlag,
+		Listen:     listenFlag,
+		AlertRules: alertRulesFlag,
+	}
+
+	backends := sinkOptions.Outputs(tool.Exporter())
+	err = tool.Run(ctx, func(ctx context.Context) error {
+		return poll(ctx, p, targets, backends)
+	})
+	if err != nil {
+		logger.Error("run failed", zap.Error(err))
+		logger.Sync()
+		os.Exit(1)
+	}
+}
+
+// poll connects to peers and writes results, unreachable
+// peers are results too, so they fail no poll.
+func poll(ctx context.Context, p *prober, targets []string, backends []sink.Sink) error {
+	ctx, cancel := context.WithTimeout(ctx, timeoutFlag)
+	defer cancel()
+
+	result := p.probeAll(ctx, targets)
+
+	return sink.WriteAll(backends, influxPoints(result))
+}
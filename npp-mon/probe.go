@@ -0,0 +1,257 @@
+package nppmon
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sonm-io/core/insonmnia/auth"
+	"github.com/sonm-io/core/insonmnia/npp"
+	"github.com/sonm-io/core/insonmnia/npp/relay"
+	"github.com/sonm-io/core/insonmnia/npp/rendezvous"
+	"github.com/sonm-io/core/util"
+	"github.com/sonm-io/core/util/netutil"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/ipaddr"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sonmclient"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// Methods the connection is made with, failed probes have none.
+const (
+	methodPublic  = "public"
+	methodPrivate = "private"
+	methodRelay   = "relay"
+)
+
+var methods = []string{methodPublic, methodPrivate, methodRelay}
+
+// loadTargets merges comma-separated list of peers with the ones
+// listed in the file. Empty lines and lines starting with # are ignored.
+func loadTargets(list, path string) ([]string, error) {
+	var targets []string
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			targets = append(targets, v)
+		}
+	}
+
+	if len(path) == 0 {
+		return targets, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		v := strings.TrimSpace(scanner.Text())
+		if len(v) == 0 || strings.HasPrefix(v, "#") {
+			continue
+		}
+
+		targets = append(targets, v)
+	}
+
+	return targets, scanner.Err()
+}
+
+// probeResult is a result of connecting to a single peer.
+type probeResult struct {
+	target string
+	// method tells how the connection has been made, see methods.
+	method string
+	// setup is how long it took to get connected,
+	// resolving, punching and relaying included.
+	setup time.Duration
+	err   error
+}
+
+// pollResult has results of all peers probed by a poll.
+type pollResult struct {
+	time   time.Time
+	probes []*probeResult
+}
+
+func (r *pollResult) connected() int {
+	n := 0
+	for _, p := range r.probes {
+		if p.err == nil {
+			n++
+		}
+	}
+
+	return n
+}
+
+// successRate is the percentage of peers connected to.
+func (r *pollResult) successRate() float64 {
+	if len(r.probes) == 0 {
+		return 0
+	}
+
+	return 100 * float64(r.connected()) / float64(len(r.probes))
+}
+
+// byMethod counts connections made with every method.
+func (r *pollResult) byMethod() map[string]int {
+	counts := map[string]int{}
+	for _, m := range methods {
+		counts[m] = 0
+	}
+
+	for _, p := range r.probes {
+		if p.err == nil {
+			counts[p.method]++
+		}
+	}
+
+	return counts
+}
+
+// prober connects to peers with the same NPP dialer workers'
+// clients use: the peer is resolved on the rendezvous, its public
+// and private addresses are tried and punched, relays are the last
+// resort.
+type prober struct {
+	dialer *npp.Dialer
+	// relays are resolved addresses of relay servers,
+	// a connection to one of them is a relayed one.
+	relays map[string]bool
+}
+
+func newProber(id *sonmclient.Identity, rvAddr, relays string) (*prober, error) {
+	_, rvEth, err := sonmclient.ParseTarget(rvAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	rv, err := auth.ParseAddr(rvAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &prober{relays: map[string]bool{}}
+	opts := []npp.Option{
+		npp.WithRendezvous(rendezvous.Config{Endpoints: []auth.Addr{*rv}}, auth.NewWalletAuthenticator(util.NewTLS(id.TLS), rvEth)),
+		npp.WithLogger(logger),
+	}
+
+	var endpoints []netutil.TCPAddr
+	for _, v := range strings.Split(relays, ",") {
+		if v = strings.TrimSpace(v); len(v) == 0 {
+			continue
+		}
+
+		addr, err := net.ResolveTCPAddr("tcp", v)
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve relay `%s`: %v", v, err)
+		}
+
+		endpoints = append(endpoints, netutil.TCPAddr{TCPAddr: *addr})
+		p.relays[addr.String()] = true
+	}
+
+	if len(endpoints) > 0 {
+		opts = append(opts, npp.WithRelay(relay.Config{Endpoints: endpoints}, id.Key))
+	}
+
+	p.dialer, err = npp.NewDialer(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *prober) Close() error {
+	return p.dialer.Close()
+}
+
+// probeAll connects to up to -parallel peers at once, each within -dial-timeout.
+func (p *prober) probeAll(ctx context.Context, targets []string) *pollResult {
+	result := &pollResult{time: time.Now(), probes: make([]*probeResult, len(targets))}
+
+	g := errgroup.Group{}
+	sem := make(chan struct{}, parallelFlag)
+	for i, target := range targets {
+		i, target := i, target
+		g.Go(func() error {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				result.probes[i] = &probeResult{target: target, err: ctx.Err()}
+				return nil
+			}
+			defer func() { <-sem }()
+
+			result.probes[i] = p.probe(ctx, target)
+			return nil
+		})
+	}
+
+	g.Wait()
+
+	for _, r := range result.probes {
+		if r.err != nil {
+			logger.Debug("cannot connect to peer", zap.String("target", r.target), zap.Error(r.err))
+		}
+	}
+
+	sort.Slice(result.probes, func(i, j int) bool {
+		return result.probes[i].target < result.probes[j].target
+	})
+
+	return result
+}
+
+func (p *prober) probe(ctx context.Context, target string) *probeResult {
+	ctx, cancel := context.WithTimeout(ctx, dialTimeoutFlag)
+	defer cancel()
+
+	res := &probeResult{target: target}
+	addr, err := auth.ParseAddr(target)
+	if err != nil {
+		res.err = err
+		return res
+	}
+
+	started := time.Now()
+	conn, err := p.dialer.DialContext(ctx, *addr)
+	res.setup = time.Since(started)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	defer conn.Close()
+
+	res.method = p.method(conn.RemoteAddr())
+	return res
+}
+
+// method tells how the connection has been made by its remote
+// address, which is the relay's one for relayed connections.
+func (p *prober) method(remote net.Addr) string {
+	if p.relays[remote.String()] {
+		return methodRelay
+	}
+
+	host, _, err := net.SplitHostPort(remote.String())
+	if err != nil {
+		return methodPublic
+	}
+
+	if ip := net.ParseIP(host); ip != nil && ipaddr.IsPrivate(ip) {
+		return methodPrivate
+	}
+
+	return methodPublic
+}
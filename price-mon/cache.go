@@ -0,0 +1,57 @@
+package pricemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// medianSource labels the median of all sources.
+const medianSource = "median"
+
+// cachedPrice is the latest median served on /price.
+type cachedPrice struct {
+	Time    time.Time `json:"time"`
+	ETH     float64   `json:"eth"`
+	USD     float64   `json:"usd"`
+	Sources []string  `json:"sources"`
+}
+
+var cache struct {
+	mu    sync.Mutex
+	price *cachedPrice
+}
+
+func servePrice(w http.ResponseWriter, r *http.Request) {
+	cache.mu.Lock()
+	price := cache.price
+	cache.mu.Unlock()
+
+	if price == nil {
+		http.Error(w, "no price yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(price)
+}
+
+// cachePrice replaces the cached median, failed
+// polls keep the previous one.
+func cachePrice(result *priceResult) {
+	if !result.ok() {
+		return
+	}
+
+	var sources []string
+	for _, q := range result.quotes {
+		if q.err == nil {
+			sources = append(sources, q.source)
+		}
+	}
+
+	cache.mu.Lock()
+	cache.price = &cachedPrice{Time: result.time, ETH: result.eth, USD: result.usd, Sources: sources}
+	cache.mu.Unlock()
+}
@@ -0,0 +1,157 @@
+package pricemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// quote is the SNM price reported by a single source.
+type quote struct {
+	source string
+	eth    float64
+	usd    float64
+	err    error
+}
+
+// priceResult are quotes of a poll, eth and usd are medians
+// of quotes that succeeded and zero if none did.
+type priceResult struct {
+	time   time.Time
+	quotes []quote
+	eth    float64
+	usd    float64
+}
+
+func (r *priceResult) ok() bool {
+	return r.usd > 0 || r.eth > 0
+}
+
+// source fetches the SNM price in ETH and USD.
+type source struct {
+	name  string
+	fetch func(ctx context.Context, client *http.Client) (eth, usd float64, err error)
+}
+
+var sourcesByName = map[string]source{
+	"coingecko":     {"coingecko", fetchCoinGecko},
+	"cryptocompare": {"cryptocompare", fetchCryptoCompare},
+}
+
+// fetcher queries sources concurrently.
+type fetcher struct {
+	sources []source
+	client  *http.Client
+}
+
+func (f *fetcher) fetch(ctx context.Context) *priceResult {
+	result := &priceResult{time: time.Now(), quotes: make([]quote, len(f.sources))}
+
+	wg := sync.WaitGroup{}
+	for i, s := range f.sources {
+		wg.Add(1)
+		go func(i int, s source) {
+			defer wg.Done()
+
+			q := quote{source: s.name}
+			q.eth, q.usd, q.err = s.fetch(ctx, f.client)
+			result.quotes[i] = q
+		}(i, s)
+	}
+	wg.Wait()
+
+	var eth, usd []float64
+	for _, q := range result.quotes {
+		if q.err != nil {
+			continue
+		}
+
+		eth = append(eth, q.eth)
+		usd = append(usd, q.usd)
+	}
+
+	result.eth, result.usd = median(eth), median(usd)
+	return result
+}
+
+// median of the values, zero if there are none.
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func fetchCoinGecko(ctx context.Context, client *http.Client) (float64, float64, error) {
+	var doc map[string]struct {
+		ETH float64 `json:"eth"`
+		USD float64 `json:"usd"`
+	}
+
+	err := getJSON(ctx, client, "https://api.coingecko.com/api/v3/simple/price?ids=sonm&vs_currencies=eth,usd", &doc)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	price, ok := doc["sonm"]
+	if !ok || price.USD <= 0 || price.ETH <= 0 {
+		return 0, 0, fmt.Errorf("no SNM price in the response")
+	}
+
+	return price.ETH, price.USD, nil
+}
+
+func fetchCryptoCompare(ctx context.Context, client *http.Client) (float64, float64, error) {
+	var doc struct {
+		ETH      float64 `json:"ETH"`
+		USD      float64 `json:"USD"`
+		Response string  `json:"Response"`
+		Message  string  `json:"Message"`
+	}
+
+	err := getJSON(ctx, client, "https://min-api.cryptocompare.com/data/price?fsym=SNM&tsyms=ETH,USD", &doc)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// errors come with 200 OK
+	if doc.Response == "Error" {
+		return 0, 0, fmt.Errorf("cryptocompare: %s", doc.Message)
+	}
+
+	if doc.USD <= 0 || doc.ETH <= 0 {
+		return 0, 0, fmt.Errorf("no SNM price in the response")
+	}
+
+	return doc.ETH, doc.USD, nil
+}
@@ -0,0 +1,32 @@
+package pricemon
+
+import "github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+
+// influxPoints returns a point per source that responded
+// followed by the median, no points if none did.
+func influxPoints(result *priceResult) []sink.Point {
+	var points []sink.Point
+	for _, q := range result.quotes {
+		if q.err != nil {
+			continue
+		}
+
+		points = append(points, sink.Point{
+			Measurement: influxMeasurementFlag,
+			Tags:        map[string]string{"source": q.source},
+			Fields:      map[string]interface{}{"eth": q.eth, "usd": q.usd},
+			Time:        result.time,
+		})
+	}
+
+	if result.ok() {
+		points = append(points, sink.Point{
+			Measurement: influxMeasurementFlag,
+			Tags:        map[string]string{"source": medianSource},
+			Fields:      map[string]interface{}{"eth": result.eth, "usd": result.usd},
+			Time:        result.time,
+		})
+	}
+
+	return points
+}
@@ -0,0 +1,145 @@
+// Package pricemon polls exchange APIs for the SNM token price, so
+// income figures can be converted to fiat from one cached source.
+package pricemon
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/daemon"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/health"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/logging"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+	"go.uber.org/zap"
+)
+
+var (
+	sourcesFlag    string
+	daemonFlag     bool
+	intervalFlag   time.Duration
+	timeoutFlag    time.Duration
+	listenFlag     string
+	alertRulesFlag string
+
+	influxMeasurementFlag string
+)
+
+// Flags are command line flags of the tool, parsed by Run.
+var Flags = flag.NewFlagSet("sonm-mon price", flag.ExitOnError)
+
+// logger is used for diagnostics, results printed by
+// console sinks do not go through it.
+var logger = zap.NewNop()
+
+// logOptions are set by the -log flags.
+var logOptions = logging.Options{Name: "price-mon"}
+
+// sinkOptions configure metrics backends, see the sink package.
+var sinkOptions = sink.Options{InfluxDatabase: "sonm", StatsdPrefix: "sonm", PushJob: "price_mon"}
+
+// selfHealth tracks polls of the daemon, so the monitor can be monitored.
+var selfHealth = health.New("price-mon")
+
+func init() {
+	Flags.StringVar(&sourcesFlag, "sources", "coingecko,cryptocompare", "comma-separated exchange APIs to poll")
+	Flags.BoolVar(&daemonFlag, "daemon", false, "keep running and poll prices periodically")
+	Flags.DurationVar(&intervalFlag, "interval", 5*time.Minute, "polling interval for the daemon mode")
+	Flags.DurationVar(&timeoutFlag, "timeout", 10*time.Second, "timeout of a single API request")
+	Flags.StringVar(&listenFlag, "listen", "", "serve prometheus metrics, health endpoints and the cached price on /price at the given address, implies -daemon")
+	Flags.StringVar(&alertRulesFlag, "alert-rules", "", "evaluate alert rules of the YAML file against exported metrics, implies -daemon")
+	logOptions.RegisterFlags(Flags)
+	Flags.StringVar(&influxMeasurementFlag, "influx-measurement", "snm_price", "measurement name of points")
+	sinkOptions.RegisterFlags(Flags)
+}
+
+// Run parses the arguments and runs the tool,
+// the process exits on failures.
+func Run(args []string) {
+	Flags.Parse(args)
+
+	var err error
+	logger, err = logOptions.New()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot create logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer logger.Sync()
+
+	if err := sinkOptions.Validate(); err != nil {
+		logger.Error("invalid output flags", zap.Error(err))
+		os.Exit(1)
+	}
+
+	var sources []source
+	for _, name := range strings.Split(sourcesFlag, ",") {
+		if name = strings.TrimSpace(name); len(name) == 0 {
+			continue
+		}
+
+		s, ok := sourcesByName[name]
+		if !ok {
+			logger.Error("unknown price source", zap.String("source", name))
+			os.Exit(1)
+		}
+
+		sources = append(sources, s)
+	}
+
+	if len(sources) == 0 {
+		logger.Error("no price sources given")
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	f := &fetcher{sources: sources, client: &http.Client{Timeout: timeoutFlag}}
+
+	tool := &daemon.Tool{
+		Name:       "price-mon",
+		Logger:     logger,
+		Health:     selfHealth,
+		Daemon:     daemonFlag,
+		Interval:   intervalFlag,
+		Timeout:    timeoutFlag,
+		Listen:     listenFlag,
+		Handlers:   map[string]http.Handler{"/price": http.HandlerFunc(servePrice)},
+		AlertRules: alertRulesFlag,
+	}
+
+	backends := sinkOptions.Outputs(tool.Exporter())
+	err = tool.Run(ctx, func(ctx context.Context) error {
+		return poll(ctx, f, backends)
+	})
+	if err != nil {
+		logger.Error("run failed", zap.Error(err))
+		logger.Sync()
+		os.Exit(1)
+	}
+}
+
+// poll fetches prices once and writes results, the poll fails
+// if no source responded or outputs failed.
+func poll(ctx context.Context, f *fetcher, backends []sink.Sink) error {
+	result := f.fetch(ctx)
+	for _, q := range result.quotes {
+		if q.err != nil {
+			logger.Warn("cannot fetch price", zap.String("source", q.source), zap.Error(q.err))
+		}
+	}
+
+	cachePrice(result)
+
+	err := sink.WriteAll(backends, influxPoints(result))
+
+	if !result.ok() {
+		return fmt.Errorf("no source responded")
+	}
+
+	return err
+}
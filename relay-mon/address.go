@@ -0,0 +1,101 @@
+package relaymon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/ipaddr"
+)
+
+// lookupHost returns IP addresses of the host, which may be an IP itself.
+func lookupHost(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	ips := make([]net.IP, 0, len(addrs))
+	for _, a := range addrs {
+		ips = append(ips, a.IP)
+	}
+
+	return ips, nil
+}
+
+// verifyAddresses cross-checks addresses advertised by cluster members
+// with the address the relay is reached at and with the cluster DNS
+// record if it is configured. Mismatches are explained one per line.
+func (t *relayTarget) verifyAddresses(ctx context.Context, members []string) []string {
+	var mismatches []string
+	advertised := map[string]string{}
+	for _, m := range members {
+		host, _, err := net.SplitHostPort(memberAddr(m))
+		if err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("member %s advertises malformed address", m))
+			continue
+		}
+
+		ips, err := lookupHost(ctx, host)
+		if err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("member %s advertises unresolvable host: %v", m, err))
+			continue
+		}
+
+		for _, ip := range ips {
+			if ipaddr.IsPrivate(ip) {
+				mismatches = append(mismatches, fmt.Sprintf("member %s advertises non-public address %s", m, ip))
+			}
+			advertised[ip.String()] = m
+		}
+	}
+
+	host, _, err := net.SplitHostPort(t.endpoint)
+	if err != nil {
+		host = t.endpoint
+	}
+
+	if ips, err := lookupHost(ctx, host); err == nil && !anyAdvertised(ips, advertised) {
+		mismatches = append(mismatches, fmt.Sprintf("relay is reached at %s, but no member advertises it", host))
+	}
+
+	if dns := t.cluster.DNS; len(dns) > 0 {
+		ips, err := lookupHost(ctx, dns)
+		if err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("cannot resolve %s: %v", dns, err))
+			ips = nil
+		}
+
+		inDNS := map[string]bool{}
+		for _, ip := range ips {
+			inDNS[ip.String()] = true
+			if _, ok := advertised[ip.String()]; !ok {
+				mismatches = append(mismatches, fmt.Sprintf("%s resolves to %s, but no member advertises it", dns, ip))
+			}
+		}
+
+		for ip, m := range advertised {
+			if err == nil && !inDNS[ip] {
+				mismatches = append(mismatches, fmt.Sprintf("member %s advertises %s missing in %s", m, ip, dns))
+			}
+		}
+	}
+
+	sort.Strings(mismatches)
+	return mismatches
+}
+
+func anyAdvertised(ips []net.IP, advertised map[string]string) bool {
+	for _, ip := range ips {
+		if _, ok := advertised[ip.String()]; ok {
+			return true
+		}
+	}
+
+	return false
+}
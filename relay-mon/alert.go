@@ -0,0 +1,139 @@
+package relaymon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const alertTimeout = 10 * time.Second
+
+// relayAlert is the webhook payload.
+type relayAlert struct {
+	Endpoint    string    `json:"endpoint"`
+	Cluster     string    `json:"cluster,omitempty"`
+	Time        time.Time `json:"time"`
+	Reasons     []string  `json:"reasons"`
+	Members     []string  `json:"members"`
+	Count       int       `json:"count"`
+	Expected    uint      `json:"expected"`
+	ConnCurrent uint64    `json:"conn_current"`
+	MaxConn     uint64    `json:"max_conn,omitempty"`
+	Down        []string  `json:"down,omitempty"`
+	// Recovered is set when the relay does not deviate anymore.
+	Recovered bool `json:"recovered,omitempty"`
+}
+
+// alertReasons explains why the relay deviates from
+// the expected state, nothing is returned if it does not.
+func alertReasons(stats *relayStats) []string {
+	var reasons []string
+	if stats.expected > 0 && len(stats.members) != int(stats.expected) {
+		reasons = append(reasons, fmt.Sprintf("cluster has %d members, expected %d", len(stats.members), stats.expected))
+	}
+
+	if n := len(stats.partitions); n > 1 {
+		reasons = append(reasons, fmt.Sprintf("cluster is split into %d partitions", n))
+	}
+
+	if cs := stats.consensus; cs != nil {
+		switch {
+		case !cs.quorum:
+			reasons = append(reasons, fmt.Sprintf("no member set is seen by the majority of %d relays", cs.relays))
+		case !cs.agrees:
+			reasons = append(reasons, fmt.Sprintf("relay disagrees with the majority seeing %d members", cs.size))
+		}
+	}
+
+	maxConn := *stats.cluster.MaxConn
+	if conn := stats.metrics.GetConnCurrent(); maxConn > 0 && conn > maxConn {
+		reasons = append(reasons, fmt.Sprintf("%d connections exceed the limit of %d", conn, maxConn))
+	}
+
+	if dp := stats.dataPlane; dp != nil && dp.err != nil {
+		reasons = append(reasons, fmt.Sprintf("relay does not forward traffic: %v", dp.err))
+	}
+
+	if ch := stats.membership; ch != nil {
+		for _, member := range ch.flapping() {
+			reasons = append(reasons, fmt.Sprintf("member %s is flapping, %d changes over the last %d runs", member, ch.flaps[member], ch.window+1))
+		}
+	}
+
+	reasons = append(reasons, stats.addressMismatches...)
+
+	for _, member := range stats.downMembers() {
+		reasons = append(reasons, fmt.Sprintf("member %s is unreachable", member))
+	}
+
+	return reasons
+}
+
+// webhookAlerter posts an alert when a relay starts deviating, repeats
+// it while the relay deviates and posts the recovery.
+type webhookAlerter struct {
+	tracker *alertTracker
+}
+
+func newWebhookAlerter() *webhookAlerter {
+	return &webhookAlerter{tracker: newAlertTracker()}
+}
+
+func (w *webhookAlerter) Write(results []*relayStats) error {
+	failed := 0
+	for _, stats := range results {
+		reasons := alertReasons(stats)
+		action := w.tracker.update(stats.endpoint, reasons, stats.time)
+		if action == alertNone {
+			continue
+		}
+
+		err := postAlert(relayAlert{
+			Endpoint:    stats.endpoint,
+			Time:        stats.time,
+			Reasons:     reasons,
+			Members:     stats.members,
+			Count:       len(stats.members),
+			Cluster:     stats.cluster.Name,
+			Expected:    stats.expected,
+			ConnCurrent: stats.metrics.GetConnCurrent(),
+			MaxConn:     *stats.cluster.MaxConn,
+			Down:        stats.downMembers(),
+			Recovered:   action == alertRecovered,
+		})
+		if err != nil {
+			logger.Warn("cannot send alert", zap.String("endpoint", stats.endpoint), zap.Error(err))
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d alerts failed", failed)
+	}
+
+	return nil
+}
+
+func postAlert(alert relayAlert) error {
+	b, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: alertTimeout}
+	resp, err := client.Post(alertURLFlag, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package relaymon
+
+// capacity is the number of connections the relay is sized for,
+// zero if it is not configured.
+func (s *relayStats) capacity() uint64 {
+	return *s.cluster.Capacity
+}
+
+// utilization is the share of the capacity in use in percent,
+// it may exceed 100. It is zero if the capacity is not configured.
+func (s *relayStats) utilization() float64 {
+	if s.capacity() == 0 {
+		return 0
+	}
+
+	return 100 * float64(s.metrics.GetConnCurrent()) / float64(s.capacity())
+}
+
+// fleetCapacity sums up connections and capacities of relays
+// having the capacity configured.
+type fleetCapacity struct {
+	conn     uint64
+	capacity uint64
+}
+
+func newFleetCapacity(results []*relayStats) fleetCapacity {
+	var f fleetCapacity
+	for _, stats := range results {
+		if stats.capacity() == 0 {
+			continue
+		}
+
+		f.conn += stats.metrics.GetConnCurrent()
+		f.capacity += stats.capacity()
+	}
+
+	return f
+}
+
+// headroom is how many more connections the fleet can take,
+// negative if it is overloaded.
+func (f fleetCapacity) headroom() int64 {
+	return int64(f.capacity) - int64(f.conn)
+}
+
+func (f fleetCapacity) utilization() float64 {
+	if f.capacity == 0 {
+		return 0
+	}
+
+	return 100 * float64(f.conn) / float64(f.capacity)
+}
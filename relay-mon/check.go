@@ -0,0 +1,161 @@
+package relaymon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Nagios plugin exit codes.
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+var checkStatusNames = map[int]string{
+	checkOK:       "OK",
+	checkWarning:  "WARNING",
+	checkCritical: "CRITICAL",
+	checkUnknown:  "UNKNOWN",
+}
+
+// deviationStatus maps the members deviation to the check status
+// using thresholds of the relay cluster, zero thresholds are disabled.
+func deviationStatus(stats *relayStats) int {
+	deviation, warn, crit := stats.deviation(), *stats.cluster.Warn, *stats.cluster.Crit
+	if cs := stats.consensus; cs != nil && !cs.agrees {
+		return checkCritical
+	}
+
+	if len(stats.partitions) > 1 {
+		return checkCritical
+	}
+
+	switch {
+	case stats.expected == 0:
+		return checkOK
+	case crit > 0 && deviation >= crit:
+		return checkCritical
+	case warn > 0 && deviation >= warn:
+		return checkWarning
+	}
+
+	return checkOK
+}
+
+// threshold formats the perfdata threshold, zero is omitted.
+func threshold(v uint64) string {
+	if v == 0 {
+		return ""
+	}
+
+	return fmt.Sprint(v)
+}
+
+// runCheck polls relays once, prints a single status line with
+// perfdata and returns the exit code. An unreachable relay is always
+// CRITICAL, too many connections or unreachable members are WARNING.
+func runCheck(ctx context.Context, targets []*relayTarget) int {
+	var results []*relayStats
+	err := poll(ctx, targets, []Sink{SinkFunc(func(r []*relayStats) error {
+		results = r
+		return nil
+	})})
+
+	status := checkOK
+	var details, perfdata []string
+	for _, stats := range results {
+		if s := deviationStatus(stats); s > status {
+			status = s
+		}
+
+		details = append(details, fmt.Sprintf("%s: %d/%d members", stats.endpoint, len(stats.members), stats.expected))
+		if dp := stats.dataPlane; dp != nil && dp.err != nil {
+			status = checkCritical
+			details = append(details, fmt.Sprintf("%s: data-plane probe failed: %v", stats.endpoint, dp.err))
+		}
+
+		if down := stats.downMembers(); len(down) > 0 {
+			if status < checkWarning {
+				status = checkWarning
+			}
+			details = append(details, fmt.Sprintf("%s: unreachable %s", stats.endpoint, strings.Join(down, " ")))
+		}
+		if ch := stats.membership; ch != nil && len(ch.flapping()) > 0 {
+			if status < checkWarning {
+				status = checkWarning
+			}
+			details = append(details, fmt.Sprintf("%s: flapping %s", stats.endpoint, strings.Join(ch.flapping(), " ")))
+		}
+
+		if len(stats.addressMismatches) > 0 {
+			if status < checkWarning {
+				status = checkWarning
+			}
+			for _, m := range stats.addressMismatches {
+				details = append(details, stats.endpoint+": "+m)
+			}
+		}
+
+		if n := len(stats.partitions); n > 1 {
+			details = append(details, fmt.Sprintf("%s: cluster is split into %d partitions", stats.endpoint, n))
+		}
+
+		if cs := stats.consensus; cs != nil && !cs.agrees {
+			details = append(details, fmt.Sprintf("%s: disagrees with the cluster majority", stats.endpoint))
+		}
+
+		conn, maxConn := stats.metrics.GetConnCurrent(), *stats.cluster.MaxConn
+		if maxConn > 0 && conn > maxConn {
+			if status < checkWarning {
+				status = checkWarning
+			}
+			details = append(details, fmt.Sprintf("%s: %d connections exceed %d", stats.endpoint, conn, maxConn))
+		}
+
+		perfdata = append(perfdata,
+			fmt.Sprintf("'%s members'=%d;;;0", stats.endpoint, len(stats.members)),
+			fmt.Sprintf("'%s diff'=%d", stats.endpoint, stats.diff()),
+			fmt.Sprintf("'%s deviation'=%d;%d;%d;0", stats.endpoint, stats.deviation(), *stats.cluster.Warn, *stats.cluster.Crit),
+			fmt.Sprintf("'%s conns'=%d;%s;;0", stats.endpoint, conn, threshold(maxConn)),
+		)
+	}
+
+	for _, t := range targets {
+		if t.tls == nil {
+			continue
+		}
+
+		if t.tls.err != nil {
+			status = checkCritical
+		} else if len(tlsReasons(t)) > 0 && status < checkWarning {
+			status = checkWarning
+		}
+
+		for _, r := range tlsReasons(t) {
+			details = append(details, t.endpoint+": "+r)
+		}
+	}
+
+	for _, t := range targets {
+		if reasons := healthReasons(t); len(reasons) > 0 {
+			status = checkCritical
+			details = append(details, t.endpoint+": "+reasons[0])
+		}
+	}
+
+	if err != nil {
+		status = checkCritical
+		details = append(details, err.Error())
+	}
+
+	if len(results) == 0 && err == nil {
+		status = checkUnknown
+		details = append(details, "no data")
+	}
+
+	fmt.Printf("RELAY %s - %s | %s\n", checkStatusNames[status], strings.Join(details, ", "), strings.Join(perfdata, " "))
+	return status
+}
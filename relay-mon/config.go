@@ -0,0 +1,123 @@
+package relaymon
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gopkg.in/yaml.v2"
+)
+
+// cluster is a set of relays expected to see the same members.
+// Omitted peer and thresholds are taken from flags.
+type cluster struct {
+	Name    string  `yaml:"name"`
+	Peer    string  `yaml:"peer"`
+	Count   uint    `yaml:"count"`
+	Warn    *uint   `yaml:"warn"`
+	Crit    *uint   `yaml:"crit"`
+	MaxConn *uint64 `yaml:"max_conn"`
+	// Capacity is the number of connections every relay of
+	// the cluster is sized for, used to report utilization.
+	Capacity *uint64 `yaml:"capacity"`
+	// DNS is the record expected to resolve to addresses
+	// advertised by members, checked with -verify-addresses.
+	DNS       string   `yaml:"dns"`
+	Endpoints []string `yaml:"endpoints"`
+}
+
+// loadClusters reads relay clusters from the config, a single unnamed
+// cluster is built from -endpoint, -endpoints-file and -count if
+// path is empty.
+//
+// clusters:
+//   - name: eu
+//     count: 3
+//     warn: 1
+//     crit: 2
+//     max_conn: 5000
+//     capacity: 6000
+//     dns: relay.example.com
+//     endpoints: [relay1.example.com:12241, 0x181b6f75B00e79382aa32D81c7734a46E9F9aF40@relay2.example.com:12241]
+func loadClusters(path string) ([]*cluster, error) {
+	if len(path) == 0 {
+		endpoints, err := loadEndpoints(endpointFlag, endpointsFileFlag)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load endpoints: %v", err)
+		}
+
+		c := &cluster{Count: expectedCountFlag, DNS: clusterDNSFlag, Endpoints: endpoints}
+		return []*cluster{c.withDefaults()}, c.validate()
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := struct {
+		Clusters []*cluster `yaml:"clusters"`
+	}{}
+
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Clusters) == 0 {
+		return nil, fmt.Errorf("no clusters defined in %s", path)
+	}
+
+	seen := map[string]bool{}
+	for _, c := range cfg.Clusters {
+		if len(c.Name) == 0 {
+			return nil, fmt.Errorf("cluster with endpoints %v has no name", c.Endpoints)
+		}
+
+		if seen[c.Name] {
+			return nil, fmt.Errorf("cluster `%s` is defined twice", c.Name)
+		}
+		seen[c.Name] = true
+
+		if err := c.withDefaults().validate(); err != nil {
+			return nil, fmt.Errorf("cluster `%s`: %v", c.Name, err)
+		}
+	}
+
+	return cfg.Clusters, nil
+}
+
+func (c *cluster) withDefaults() *cluster {
+	if len(c.Peer) == 0 {
+		c.Peer = peerAddrFlag
+	}
+	if c.Warn == nil {
+		c.Warn = &warnFlag
+	}
+	if c.Crit == nil {
+		c.Crit = &critFlag
+	}
+	if c.MaxConn == nil {
+		c.MaxConn = &maxConnFlag
+	}
+	if c.Capacity == nil {
+		c.Capacity = &capacityFlag
+	}
+
+	return c
+}
+
+func (c *cluster) validate() error {
+	if len(c.Endpoints) == 0 {
+		return fmt.Errorf("host list is empty")
+	}
+
+	if c.Count == 0 && !consensusFlag {
+		return fmt.Errorf("expected count cannot be zero without -consensus")
+	}
+
+	if !common.IsHexAddress(c.Peer) {
+		return fmt.Errorf("invalid peer address `%s`", c.Peer)
+	}
+
+	return nil
+}
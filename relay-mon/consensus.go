@@ -0,0 +1,83 @@
+package relaymon
+
+import (
+	"sort"
+	"strings"
+)
+
+// consensus is what relays of the cluster agree upon,
+// the majority is strictly more than half of responded relays.
+type consensus struct {
+	// quorum is false if no member set is seen by the majority.
+	quorum bool
+	// agrees is true if the relay sees the same members as the majority.
+	agrees bool
+	// size is the number of members seen by the majority.
+	size uint
+	// relays is how many relays of the cluster have responded.
+	relays int
+}
+
+// applyConsensus compares member sets seen by relays of the same
+// cluster. Relays outside the majority are flagged, the size of the
+// majority set becomes the expected one for clusters configured
+// without the count.
+func applyConsensus(results []*relayStats) {
+	byCluster := map[*cluster][]*relayStats{}
+	for _, stats := range results {
+		byCluster[stats.cluster] = append(byCluster[stats.cluster], stats)
+	}
+
+	for c, group := range byCluster {
+		views := map[string]int{}
+		for _, stats := range group {
+			views[memberSetKey(stats.members)]++
+		}
+
+		majority, votes := "", 0
+		for key, n := range views {
+			if n > votes {
+				majority, votes = key, n
+			}
+		}
+
+		quorum := votes*2 > len(group)
+		for _, stats := range group {
+			stats.consensus = &consensus{
+				quorum: quorum,
+				agrees: quorum && memberSetKey(stats.members) == majority,
+				relays: len(group),
+			}
+
+			if quorum {
+				stats.consensus.size = uint(len(stats.members))
+				if !stats.consensus.agrees {
+					stats.consensus.size = majoritySize(group, majority)
+				}
+			}
+
+			if c.Count == 0 && quorum {
+				stats.expected = stats.consensus.size
+			}
+		}
+	}
+}
+
+func majoritySize(group []*relayStats, majority string) uint {
+	for _, stats := range group {
+		if memberSetKey(stats.members) == majority {
+			return uint(len(stats.members))
+		}
+	}
+
+	return 0
+}
+
+// memberSetKey identifies the set of members regardless of the order.
+func memberSetKey(members []string) string {
+	sorted := make([]string, len(members))
+	copy(sorted, members)
+	sort.Strings(sorted)
+
+	return strings.Join(sorted, ",")
+}
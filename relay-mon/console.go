@@ -0,0 +1,309 @@
+package relaymon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+)
+
+// consoleSinks maps -format values to sinks.
+var consoleSinks = map[string]Sink{
+	"telegraf": SinkFunc(writeTelegraf),
+	"json":     SinkFunc(writeJSON),
+}
+
+// writeTelegraf prints a line per relay for the telegraf exec input,
+// followed by a line per cluster member unless legacy names are used.
+func writeTelegraf(results []*relayStats) error {
+	for _, stats := range results {
+		// calculate metrics
+		members := len(stats.members)
+		membersDiff := stats.diff()
+		connCount := stats.metrics.GetConnCurrent()
+		tx, rx := stats.traffic()
+
+		changes := ""
+		if stats.capacity() > 0 {
+			changes = fmt.Sprintf(",capacity=%d,utilization=%.1f", stats.capacity(), stats.utilization())
+		}
+
+		if stats.reachable != nil {
+			changes += fmt.Sprintf(",members_down=%d", len(stats.downMembers()))
+		}
+
+		if dp := stats.dataPlane; dp != nil {
+			changes += fmt.Sprintf(",dataplane_ok=%t,dataplane_setup_ms=%.1f,dataplane_bps=%.0f",
+				dp.err == nil, sink.Millis(dp.setup), dp.throughput)
+		}
+
+		if tr := stats.trend; tr != nil {
+			changes += fmt.Sprintf(",conn_trend_short=%.2f,conn_trend_long=%.2f", tr.short, tr.long)
+			if !tr.saturation.IsZero() {
+				changes += fmt.Sprintf(",saturation=%d", tr.saturation.Unix())
+			}
+		}
+
+		if verifyAddressesFlag {
+			changes += fmt.Sprintf(",address_mismatches=%d", len(stats.addressMismatches))
+		}
+
+		if stats.partitions != nil {
+			changes += fmt.Sprintf(",partitions=%d", len(stats.partitions))
+		}
+
+		if cs := stats.consensus; cs != nil {
+			changes += fmt.Sprintf(",consensus=%t,quorum=%t", cs.agrees, cs.quorum)
+		}
+
+		if ch := stats.membership; ch != nil {
+			changes += fmt.Sprintf(",joined=%d,left=%d,flapping=%d", len(ch.joined), len(ch.left), len(ch.flapping()))
+		}
+
+		// show metrics to telegraf collector
+		fmt.Printf("%s count=%d,expect=%d,diff=%d,conn_count=%d,uptime=%d,tx_bytes=%d,rx_bytes=%d%s\n",
+			telegrafSeries(stats, measurementFlag, nil), members, stats.expected, membersDiff, connCount, stats.metrics.GetUptime(), tx, rx, changes)
+
+		if legacyNamesFlag {
+			continue
+		}
+
+		fields := memberFields(stats)
+		for _, m := range memberKeys(fields) {
+			series := telegrafSeries(stats, measurementFlag+"_member", map[string]string{"member": m})
+			fmt.Printf("%s %s\n", series, formatFields(fields[m]))
+		}
+	}
+
+	if f := newFleetCapacity(results); f.capacity > 0 && !legacyNamesFlag {
+		fmt.Printf("%s conn_count=%d,capacity=%d,headroom=%d,utilization=%.1f\n",
+			telegrafSeries(nil, measurementFlag+"_fleet", nil), f.conn, f.capacity, f.headroom(), f.utilization())
+	}
+
+	return nil
+}
+
+type jsonRelay struct {
+	Endpoint    string    `json:"endpoint"`
+	Cluster     string    `json:"cluster,omitempty"`
+	Time        time.Time `json:"time"`
+	Members     []string  `json:"members"`
+	Expected    uint      `json:"expected"`
+	Diff        int       `json:"diff"`
+	ConnCurrent uint64    `json:"conn_current"`
+	Uptime      uint64    `json:"uptime"`
+	LatencyMs   float64   `json:"latency_ms"`
+	Capacity    uint64    `json:"capacity,omitempty"`
+	Utilization float64   `json:"utilization,omitempty"`
+	TxBytes     uint64    `json:"tx_bytes"`
+	RxBytes     uint64    `json:"rx_bytes"`
+	// DataPlane is set when the data-plane probe is enabled.
+	DataPlane *jsonDataPlane `json:"dataplane,omitempty"`
+	// AddressMismatches are set with -verify-addresses.
+	AddressMismatches []string `json:"address_mismatches,omitempty"`
+	// Trend is set with -conn-trend.
+	Trend *jsonTrend `json:"conn_trend,omitempty"`
+	// Locations are set when members are located with -geoip-db.
+	Locations []jsonLocation `json:"locations,omitempty"`
+	// Partitions are set in the -split-brain mode.
+	Partitions []jsonPartition `json:"partitions,omitempty"`
+	// Consensus is set in the -consensus mode.
+	Consensus *jsonConsensus `json:"consensus,omitempty"`
+	// Down is set when members are probed.
+	Down []string `json:"down,omitempty"`
+	// Joined, Left and Flaps are set when the state file is used.
+	Joined []string       `json:"joined,omitempty"`
+	Left   []string       `json:"left,omitempty"`
+	Flaps  map[string]int `json:"flaps,omitempty"`
+	// Flapping are members changed presence at least -flap-threshold times.
+	Flapping []string `json:"flapping,omitempty"`
+	// Net has traffic counters keyed the same way the relay does.
+	Net map[string]jsonNet `json:"net,omitempty"`
+}
+
+type jsonNet struct {
+	TxBytes uint64 `json:"tx_bytes"`
+	RxBytes uint64 `json:"rx_bytes"`
+}
+
+type jsonTrend struct {
+	Short      float64    `json:"short_per_hour"`
+	Long       float64    `json:"long_per_hour"`
+	Saturation *time.Time `json:"saturation,omitempty"`
+}
+
+type jsonLocation struct {
+	Geohash string  `json:"geohash"`
+	City    string  `json:"city,omitempty"`
+	Country string  `json:"country,omitempty"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	Count   int     `json:"count"`
+}
+
+type jsonPartition struct {
+	View   []string `json:"view"`
+	SeenBy []string `json:"seen_by"`
+}
+
+type jsonConsensus struct {
+	Quorum bool `json:"quorum"`
+	Agrees bool `json:"agrees"`
+	Size   uint `json:"size,omitempty"`
+	Relays int  `json:"relays"`
+}
+
+type jsonDataPlane struct {
+	OK         bool    `json:"ok"`
+	Error      string  `json:"error,omitempty"`
+	SetupMs    float64 `json:"setup_ms"`
+	Throughput float64 `json:"bytes_per_second"`
+}
+
+// telegrafSeries returns the measurement with tags of the relay line,
+// fleet-wide lines have no relay. Legacy names embed the IP into the
+// measurement and have no tags.
+func telegrafSeries(stats *relayStats, measurement string, extra map[string]string) string {
+	if legacyNamesFlag {
+		iponly := strings.Replace(strings.Split(stats.endpoint, ":")[0], ".", "_", 4)
+		return fmt.Sprintf("relay_%s_members", iponly)
+	}
+
+	tags := map[string]string{}
+	if stats != nil {
+		tags["endpoint"] = stats.endpoint
+		if len(stats.cluster.Name) > 0 {
+			tags["cluster"] = stats.cluster.Name
+		}
+	}
+	for k, v := range extra {
+		tags[k] = v
+	}
+	for k, v := range staticTags {
+		tags[k] = v
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	// telegraf expects tags sorted by the key
+	sort.Strings(keys)
+
+	series := measurementEscaper.Replace(measurement)
+	for _, k := range keys {
+		series += "," + tagEscaper.Replace(k) + "=" + tagEscaper.Replace(tags[k])
+	}
+
+	return series
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+)
+
+// parseTags parses comma-separated key=value pairs.
+func parseTags(s string) (map[string]string, error) {
+	tags := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); len(pair) == 0 {
+			continue
+		}
+
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || len(kv[0]) == 0 || len(kv[1]) == 0 {
+			return nil, fmt.Errorf("tag `%s` is not in the key=value form", pair)
+		}
+
+		tags[kv[0]] = kv[1]
+	}
+
+	return tags, nil
+}
+
+// writeJSON prints a single document per poll to stdout.
+func writeJSON(results []*relayStats) error {
+	return json.NewEncoder(os.Stdout).Encode(jsonRelays(results))
+}
+
+// jsonRelays converts results to the form printed as JSON
+// and passed to the -template.
+func jsonRelays(results []*relayStats) []jsonRelay {
+	doc := []jsonRelay{}
+	for _, stats := range results {
+		members := stats.members
+		if members == nil {
+			members = []string{}
+		}
+
+		tx, rx := stats.traffic()
+		net := map[string]jsonNet{}
+		for key, m := range stats.metrics.GetNet() {
+			net[key] = jsonNet{TxBytes: m.GetTxBytes(), RxBytes: m.GetRxBytes()}
+		}
+
+		jr := jsonRelay{
+			Endpoint:    stats.endpoint,
+			Time:        stats.time,
+			Members:     members,
+			Cluster:     stats.cluster.Name,
+			Expected:    stats.expected,
+			Diff:        stats.diff(),
+			ConnCurrent: stats.metrics.GetConnCurrent(),
+			Uptime:      stats.metrics.GetUptime(),
+			LatencyMs:   sink.Millis(stats.scrapeTime),
+			Capacity:    stats.capacity(),
+			Utilization: stats.utilization(),
+			TxBytes:     tx,
+			RxBytes:     rx,
+			Net:         net,
+		}
+
+		if dp := stats.dataPlane; dp != nil {
+			jr.DataPlane = &jsonDataPlane{OK: dp.err == nil, SetupMs: sink.Millis(dp.setup), Throughput: dp.throughput}
+			if dp.err != nil {
+				jr.DataPlane.Error = dp.err.Error()
+			}
+		}
+
+		jr.AddressMismatches = stats.addressMismatches
+
+		if tr := stats.trend; tr != nil {
+			jr.Trend = &jsonTrend{Short: tr.short, Long: tr.long}
+			if !tr.saturation.IsZero() {
+				jr.Trend.Saturation = &tr.saturation
+			}
+		}
+
+		for _, l := range stats.locations {
+			jr.Locations = append(jr.Locations, jsonLocation{
+				Geohash: l.geohash, City: l.city, Country: l.country, Lat: l.lat, Lon: l.lon, Count: l.count,
+			})
+		}
+
+		for _, p := range stats.partitions {
+			jr.Partitions = append(jr.Partitions, jsonPartition{View: p.view, SeenBy: p.seenBy})
+		}
+
+		if cs := stats.consensus; cs != nil {
+			jr.Consensus = &jsonConsensus{Quorum: cs.quorum, Agrees: cs.agrees, Size: cs.size, Relays: cs.relays}
+		}
+
+		if stats.reachable != nil {
+			jr.Down = stats.downMembers()
+		}
+
+		if ch := stats.membership; ch != nil {
+			jr.Joined, jr.Left, jr.Flaps, jr.Flapping = ch.joined, ch.left, ch.flaps, ch.flapping()
+		}
+
+		doc = append(doc, jr)
+	}
+
+	return doc
+}
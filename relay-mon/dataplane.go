@@ -0,0 +1,116 @@
+package relaymon
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/sonm-io/core/insonmnia/npp/relay"
+)
+
+// dataPlaneResult tells whether the relay forwards traffic
+// between a server and a client both run by relay-mon.
+type dataPlaneResult struct {
+	// setup is how long it took both sides to get connected.
+	setup time.Duration
+	// throughput is in bytes per second, the payload is sent
+	// by the client and echoed back by the server.
+	throughput float64
+	err        error
+}
+
+// probeDataPlane publishes a server on the relay under the own wallet,
+// dials it through the relay and transfers the payload back and forth.
+func (t *relayTarget) probeDataPlane(ctx context.Context) *dataPlaneResult {
+	ctx, cancel := context.WithTimeout(ctx, dataPlaneTimeoutFlag)
+	defer cancel()
+
+	host, _, err := net.SplitHostPort(t.endpoint)
+	if err != nil {
+		return &dataPlaneResult{err: err}
+	}
+
+	addr, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(host, fmt.Sprint(dataPlanePortFlag)))
+	if err != nil {
+		return &dataPlaneResult{err: err}
+	}
+
+	id := uuid.New()
+	started := time.Now()
+	serverChan := make(chan net.Conn, 1)
+	errChan := make(chan error, 1)
+
+	go func() {
+		conn, err := relay.Listen(ctx, addr, relay.NewEthSigner(t.id.Key), id, logger)
+		if err != nil {
+			errChan <- fmt.Errorf("cannot publish server: %v", err)
+			return
+		}
+
+		serverChan <- conn
+	}()
+
+	// abandon stops publishing the server, the one connected
+	// nevertheless is closed as soon as it is.
+	abandon := func() {
+		cancel()
+		go func() {
+			select {
+			case conn := <-serverChan:
+				conn.Close()
+			case <-errChan:
+			}
+		}()
+	}
+
+	client, err := relay.Dial(ctx, addr, t.id.Eth(), id, logger)
+	if err != nil {
+		abandon()
+		return &dataPlaneResult{err: fmt.Errorf("cannot dial server: %v", err)}
+	}
+	defer client.Close()
+
+	var server net.Conn
+	select {
+	case err := <-errChan:
+		return &dataPlaneResult{err: err}
+	case server = <-serverChan:
+	case <-ctx.Done():
+		abandon()
+		return &dataPlaneResult{err: fmt.Errorf("server has not been connected: %v", ctx.Err())}
+	}
+	defer server.Close()
+
+	res := &dataPlaneResult{setup: time.Since(started)}
+	if deadline, ok := ctx.Deadline(); ok {
+		client.SetDeadline(deadline)
+		server.SetDeadline(deadline)
+	}
+
+	payload := make([]byte, dataPlaneBytesFlag)
+	rand.Read(payload)
+
+	go io.Copy(server, server)
+
+	transferStarted := time.Now()
+	go client.Write(payload)
+
+	echoed := make([]byte, len(payload))
+	if _, err := io.ReadFull(client, echoed); err != nil {
+		res.err = fmt.Errorf("cannot read echoed payload: %v", err)
+		return res
+	}
+
+	if !bytes.Equal(payload, echoed) {
+		res.err = fmt.Errorf("echoed payload differs from the sent one")
+		return res
+	}
+
+	res.throughput = float64(2*len(payload)) / time.Since(transferStarted).Seconds()
+	return res
+}
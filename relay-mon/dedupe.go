@@ -0,0 +1,76 @@
+package relaymon
+
+import (
+	"strings"
+	"time"
+)
+
+// Actions returned by the alert tracker.
+const (
+	alertNone = iota
+	alertFiring
+	alertRepeat
+	alertRecovered
+)
+
+// alertTracker suppresses repeated notifications for an ongoing
+// condition. A condition is notified when it starts, again every
+// -renotify while it lasts and once when it clears. Notifications
+// about the same relay are not sent more often than -alert-cooldown,
+// a suppressed start is notified once the cooldown passes.
+type alertTracker struct {
+	active   map[string]*activeAlert
+	lastSent map[string]time.Time
+}
+
+type activeAlert struct {
+	reasons string
+	// notified is zero until the start is notified.
+	notified time.Time
+}
+
+func newAlertTracker() *alertTracker {
+	return &alertTracker{active: map[string]*activeAlert{}, lastSent: map[string]time.Time{}}
+}
+
+// update records the current reasons of the relay
+// and tells whether a notification should be sent.
+func (t *alertTracker) update(endpoint string, reasons []string, now time.Time) int {
+	a, ok := t.active[endpoint]
+	if len(reasons) == 0 {
+		if !ok {
+			return alertNone
+		}
+
+		delete(t.active, endpoint)
+		if a.notified.IsZero() {
+			return alertNone
+		}
+
+		t.lastSent[endpoint] = now
+		return alertRecovered
+	}
+
+	if !ok {
+		a = &activeAlert{}
+		t.active[endpoint] = a
+	}
+	a.reasons = strings.Join(reasons, ", ")
+
+	if now.Sub(t.lastSent[endpoint]) < alertCooldownFlag {
+		return alertNone
+	}
+
+	action := alertNone
+	switch {
+	case a.notified.IsZero():
+		action = alertFiring
+	case renotifyFlag > 0 && now.Sub(a.notified) >= renotifyFlag:
+		action = alertRepeat
+	default:
+		return alertNone
+	}
+
+	a.notified, t.lastSent[endpoint] = now, now
+	return action
+}
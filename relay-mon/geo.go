@@ -0,0 +1,79 @@
+package relaymon
+
+import (
+	"net"
+	"sort"
+
+	"github.com/mmcloughlin/geohash"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/geo"
+	"go.uber.org/zap"
+)
+
+// memberLocation is a number of members seen by the
+// relay sharing the same geohash.
+type memberLocation struct {
+	geohash string
+	city    string
+	country string
+	lat     float64
+	lon     float64
+	count   int
+}
+
+// locateMembers groups members by the geohash of their IP addresses,
+// hostnames are resolved first. Members which cannot be located are
+// counted under the empty geohash.
+func locateMembers(resolver geo.Resolver, members []string) []memberLocation {
+	byHash := map[string]*memberLocation{}
+	for _, m := range members {
+		loc := &memberLocation{}
+		if l, err := locateMember(resolver, m); err != nil {
+			logger.Debug("cannot locate member", zap.String("member", m), zap.Error(err))
+		} else {
+			loc = &memberLocation{
+				geohash: geohash.EncodeWithPrecision(l.Lat, l.Lon, geoPrecisionFlag),
+				city:    l.City,
+				country: l.Country,
+				lat:     l.Lat,
+				lon:     l.Lon,
+			}
+		}
+
+		if existing, ok := byHash[loc.geohash]; ok {
+			loc = existing
+		} else {
+			byHash[loc.geohash] = loc
+		}
+
+		loc.count++
+	}
+
+	locations := make([]memberLocation, 0, len(byHash))
+	for _, loc := range byHash {
+		locations = append(locations, *loc)
+	}
+
+	sort.Slice(locations, func(i, j int) bool {
+		return locations[i].geohash < locations[j].geohash
+	})
+
+	return locations
+}
+
+func locateMember(resolver geo.Resolver, member string) (*geo.Location, error) {
+	host, _, err := net.SplitHostPort(memberAddr(member))
+	if err != nil {
+		return nil, err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		ips, err := net.LookupIP(host)
+		if err != nil {
+			return nil, err
+		}
+		ip = ips[0]
+	}
+
+	return resolver.Resolve(ip)
+}
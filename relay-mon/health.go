@@ -0,0 +1,102 @@
+package relaymon
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// healthUnimplemented is reported for relays not exposing the health service.
+const healthUnimplemented = "UNIMPLEMENTED"
+
+var (
+	healthServingGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "relay_health_serving",
+		Help: "Whether the gRPC health service of the relay reports SERVING, -health-check mode.",
+	}, []string{"endpoint"})
+
+	healthTransitionsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "relay_health_transitions_total",
+		Help: "Number of serving status changes reported by the gRPC health service of the relay.",
+	}, []string{"endpoint", "from", "to"})
+)
+
+func init() {
+	prometheus.MustRegister(healthServingGauge, healthTransitionsCounter)
+}
+
+// checkHealth asks the standard gRPC health service of the relay for
+// the overall serving status. Relays without the service are reported
+// as unimplemented, failed requests as unknown.
+func (t *relayTarget) checkHealth(ctx context.Context) string {
+	resp, err := grpc_health_v1.NewHealthClient(t.conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if status.Code(err) == codes.Unimplemented {
+		return healthUnimplemented
+	}
+
+	if err != nil {
+		logger.Debug("health check failed", zap.String("endpoint", t.endpoint), zap.Error(err))
+		return grpc_health_v1.HealthCheckResponse_UNKNOWN.String()
+	}
+
+	return resp.GetStatus().String()
+}
+
+// healthReasons explains why the relay is not serving,
+// nothing is returned if the check has not been made.
+func healthReasons(t *relayTarget) []string {
+	switch t.health {
+	case "", healthUnimplemented, grpc_health_v1.HealthCheckResponse_SERVING.String():
+		return nil
+	}
+
+	return []string{"health status is " + t.health}
+}
+
+// writeHealth reports serving statuses of relays and records their
+// transitions, the webhook alert is posted when a relay stops serving.
+func writeHealth(targets []*relayTarget, previous map[string]string) {
+	healthServingGauge.Reset()
+
+	for _, t := range targets {
+		if t.health == healthUnimplemented {
+			continue
+		}
+
+		serving := 0.0
+		if len(healthReasons(t)) == 0 {
+			serving = 1
+		}
+		healthServingGauge.WithLabelValues(t.endpoint).Set(serving)
+
+		was, seen := previous[t.endpoint]
+		previous[t.endpoint] = t.health
+		if !seen || was == t.health {
+			continue
+		}
+
+		healthTransitionsCounter.WithLabelValues(t.endpoint, was, t.health).Inc()
+		logger.Info("relay health status changed", zap.String("endpoint", t.endpoint),
+			zap.String("from", was), zap.String("to", t.health))
+
+		if serving == 1 || len(alertURLFlag) == 0 {
+			continue
+		}
+
+		err := postAlert(relayAlert{
+			Endpoint: t.endpoint,
+			Time:     time.Now(),
+			Reasons:  healthReasons(t),
+			Cluster:  t.cluster.Name,
+			Expected: t.cluster.Count,
+		})
+		if err != nil {
+			logger.Warn("cannot send health alert", zap.String("endpoint", t.endpoint), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package relaymon
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"go.uber.org/zap"
+)
+
+// membershipChange is the difference between cluster members seen
+// by the relay during the current and the previous polls.
+type membershipChange struct {
+	joined []string
+	left   []string
+	// flaps counts how many times a member has appeared or
+	// disappeared over the kept history, stable ones are omitted.
+	flaps map[string]int
+	// window is how many changes could have happened over
+	// the kept history, i.e. the number of runs minus one.
+	window int
+}
+
+// flapScore is the share of runs the member has changed its presence
+// on, a member lost once scores low while a chronically flapping one
+// approaches 1.
+func (ch *membershipChange) flapScore(member string) float64 {
+	if ch.window == 0 {
+		return 0
+	}
+
+	return float64(ch.flaps[member]) / float64(ch.window)
+}
+
+// flapping returns members sorted which have changed their presence
+// at least -flap-threshold times over the kept history.
+func (ch *membershipChange) flapping() []string {
+	var members []string
+	for m, n := range ch.flaps {
+		if flapThresholdFlag > 0 && n >= int(flapThresholdFlag) {
+			members = append(members, m)
+		}
+	}
+
+	sort.Strings(members)
+	return members
+}
+
+// membershipState keeps sorted member lists of the last polls per relay,
+// the oldest first.
+type membershipState map[string][][]string
+
+func loadMembershipState(path string) (membershipState, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return membershipState{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := membershipState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// saveMembershipState replaces the state file atomically.
+func saveMembershipState(path string, state membershipState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// applyMembership compares members with the ones stored in the state
+// file and appends the current ones to the history, keeping at most
+// size lists. Relays seen for the first time get no change.
+func applyMembership(path string, size int, results []*relayStats) error {
+	state, err := loadMembershipState(path)
+	if err != nil {
+		return err
+	}
+
+	for _, stats := range results {
+		current := make([]string, len(stats.members))
+		copy(current, stats.members)
+		sort.Strings(current)
+
+		history := append(state[stats.endpoint], current)
+		if len(history) > size {
+			history = history[len(history)-size:]
+		}
+
+		if len(history) > 1 {
+			stats.membership = diffMembers(history[len(history)-2], current)
+			stats.membership.flaps = countFlaps(history)
+			stats.membership.window = len(history) - 1
+			for _, m := range stats.membership.joined {
+				logger.Info("member joined the cluster", zap.String("endpoint", stats.endpoint), zap.String("member", m))
+			}
+			for _, m := range stats.membership.left {
+				logger.Info("member left the cluster", zap.String("endpoint", stats.endpoint), zap.String("member", m))
+			}
+		}
+
+		state[stats.endpoint] = history
+	}
+
+	return saveMembershipState(path, state)
+}
+
+func diffMembers(previous, current []string) *membershipChange {
+	prev := map[string]bool{}
+	for _, m := range previous {
+		prev[m] = true
+	}
+
+	ch := &membershipChange{joined: []string{}, left: []string{}}
+	for _, m := range current {
+		if prev[m] {
+			delete(prev, m)
+			continue
+		}
+
+		ch.joined = append(ch.joined, m)
+	}
+
+	for m := range prev {
+		ch.left = append(ch.left, m)
+	}
+
+	sort.Strings(ch.left)
+	return ch
+}
+
+// countFlaps counts presence changes of every member ever seen in the history.
+func countFlaps(history [][]string) map[string]int {
+	present := make([]map[string]bool, len(history))
+	all := map[string]bool{}
+	for i, members := range history {
+		present[i] = map[string]bool{}
+		for _, m := range members {
+			present[i][m] = true
+			all[m] = true
+		}
+	}
+
+	flaps := map[string]int{}
+	for m := range all {
+		for i := 1; i < len(history); i++ {
+			if present[i][m] != present[i-1][m] {
+				flaps[m]++
+			}
+		}
+	}
+
+	return flaps
+}
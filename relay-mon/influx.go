@@ -0,0 +1,167 @@
+package relaymon
+
+import "github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+
+// influxPoints returns a point per relay tagged with its endpoint,
+// fields are the same as of the telegraf line.
+func influxPoints(results []*relayStats) []sink.Point {
+	var infPoints []sink.Point
+	for _, stats := range results {
+		first := len(infPoints)
+		members := len(stats.members)
+		tx, rx := stats.traffic()
+		infPoints = append(infPoints, sink.Point{
+			Measurement: influxMeasurementFlag,
+			Tags: map[string]string{
+				"endpoint": stats.endpoint,
+			},
+			Fields: map[string]interface{}{
+				"count":      members,
+				"expect":     int(stats.expected),
+				"diff":       stats.diff(),
+				"conn_count": int64(stats.metrics.GetConnCurrent()),
+				"uptime":     int64(stats.metrics.GetUptime()),
+				"tx_bytes":   int64(tx),
+				"rx_bytes":   int64(rx),
+				"scrape_ms":  sink.Millis(stats.scrapeTime),
+			},
+			Time: stats.time,
+		})
+
+		if stats.capacity() > 0 {
+			infPoints[len(infPoints)-1].Fields["capacity"] = int64(stats.capacity())
+			infPoints[len(infPoints)-1].Fields["utilization"] = stats.utilization()
+		}
+
+		if dp := stats.dataPlane; dp != nil {
+			infPoints[len(infPoints)-1].Fields["dataplane_ok"] = dp.err == nil
+			infPoints[len(infPoints)-1].Fields["dataplane_setup_ms"] = sink.Millis(dp.setup)
+			infPoints[len(infPoints)-1].Fields["dataplane_bps"] = dp.throughput
+		}
+
+		if tr := stats.trend; tr != nil {
+			infPoints[len(infPoints)-1].Fields["conn_trend_short"] = tr.short
+			infPoints[len(infPoints)-1].Fields["conn_trend_long"] = tr.long
+			if !tr.saturation.IsZero() {
+				infPoints[len(infPoints)-1].Fields["saturation"] = tr.saturation.Unix()
+			}
+		}
+
+		if verifyAddressesFlag {
+			infPoints[len(infPoints)-1].Fields["address_mismatches"] = len(stats.addressMismatches)
+		}
+
+		if stats.partitions != nil {
+			infPoints[len(infPoints)-1].Fields["partitions"] = len(stats.partitions)
+		}
+
+		if cs := stats.consensus; cs != nil {
+			infPoints[len(infPoints)-1].Fields["consensus"] = cs.agrees
+			infPoints[len(infPoints)-1].Fields["quorum"] = cs.quorum
+		}
+
+		if stats.reachable != nil {
+			infPoints[len(infPoints)-1].Fields["members_down"] = len(stats.downMembers())
+		}
+
+		if ch := stats.membership; ch != nil {
+			infPoints = append(infPoints, sink.Point{
+				Measurement: influxMeasurementFlag + "_changes",
+				Tags: map[string]string{
+					"endpoint": stats.endpoint,
+				},
+				Fields: map[string]interface{}{
+					"joined":   len(ch.joined),
+					"left":     len(ch.left),
+					"flapping": len(ch.flapping()),
+				},
+				Time: stats.time,
+			})
+		}
+
+		for _, l := range stats.locations {
+			infPoints = append(infPoints, sink.Point{
+				Measurement: influxMeasurementFlag + "_geo",
+				Tags: map[string]string{
+					"endpoint": stats.endpoint,
+					"geohash":  l.geohash,
+					"city":     l.city,
+					"country":  l.country,
+				},
+				Fields: map[string]interface{}{
+					"count": l.count,
+					"lat":   l.lat,
+					"lon":   l.lon,
+				},
+				Time: stats.time,
+			})
+		}
+
+		fields := memberFields(stats)
+		for _, m := range memberKeys(fields) {
+			infPoints = append(infPoints, sink.Point{
+				Measurement: influxMeasurementFlag + "_member",
+				Tags: map[string]string{
+					"endpoint": stats.endpoint,
+					"member":   m,
+				},
+				Fields: fields[m],
+				Time:   stats.time,
+			})
+		}
+
+		for _, key := range stats.netKeys() {
+			m := stats.metrics.GetNet()[key]
+			infPoints = append(infPoints, sink.Point{
+				Measurement: influxMeasurementFlag + "_net",
+				Tags: map[string]string{
+					"endpoint": stats.endpoint,
+					"peer":     key,
+				},
+				Fields: map[string]interface{}{
+					"tx_bytes": int64(m.GetTxBytes()),
+					"rx_bytes": int64(m.GetRxBytes()),
+				},
+				Time: stats.time,
+			})
+		}
+
+		tagPoints(stats, infPoints[first:])
+	}
+
+	if f := newFleetCapacity(results); f.capacity > 0 && len(results) > 0 {
+		infPoints = append(infPoints, sink.Point{
+			Measurement: influxMeasurementFlag + "_fleet",
+			Tags:        map[string]string{},
+			Fields: map[string]interface{}{
+				"conn_count":  int64(f.conn),
+				"capacity":    int64(f.capacity),
+				"headroom":    f.headroom(),
+				"utilization": f.utilization(),
+			},
+			Time: results[0].time,
+		})
+
+		for k, v := range staticTags {
+			infPoints[len(infPoints)-1].Tags[k] = v
+		}
+	}
+
+	return infPoints
+}
+
+// tagPoints adds the cluster name and -tags to points of the relay,
+// tags set by the point itself are kept.
+func tagPoints(stats *relayStats, infPoints []sink.Point) {
+	for _, p := range infPoints {
+		if len(stats.cluster.Name) > 0 {
+			p.Tags["cluster"] = stats.cluster.Name
+		}
+
+		for k, v := range staticTags {
+			if _, ok := p.Tags[k]; !ok {
+				p.Tags[k] = v
+			}
+		}
+	}
+}
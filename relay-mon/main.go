@@ -1,114 +1,434 @@
-package main
+// Package relaymon monitors SONM relay clusters.
+package relaymon
 
 import (
 	"context"
 	"flag"
 	"fmt"
-	"log"
+	"net/http"
 	"os"
-	"strings"
+	"text/template"
 	"time"
 
-	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/sonm-io/core/insonmnia/auth"
-	"github.com/sonm-io/core/proto"
-	"github.com/sonm-io/core/util"
-	"github.com/sonm-io/core/util/xgrpc"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/daemon"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/health"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/logging"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sonmclient"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/tracing"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/geo"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 )
 
 var (
 	endpointFlag      string
+	endpointsFileFlag string
+	configFlag        string
+	consensusFlag     bool
+	splitBrainFlag    bool
+	geoipDBFlag       string
+	keyFileFlag       string
+	keyPasswordFlag   string
+	geoPrecisionFlag  uint
+	memberPortFlag    uint
 	peerAddrFlag      string
 	expectedCountFlag uint
 	debugLogPath      string
+	daemonFlag        bool
+	intervalFlag      time.Duration
+	listenFlag        string
+	alertRulesFlag    string
+	formatFlag        string
+	templateFlag      string
+	measurementFlag   string
+	tagsFlag          string
+	legacyNamesFlag   bool
+	alertURLFlag      string
+	alertCooldownFlag time.Duration
+	renotifyFlag      time.Duration
+	maxConnFlag       uint64
+	telegramTokenFlag string
+	telegramChatFlag  string
+	slackWebhookFlag  string
+	checkFlag         bool
+	stateFlag         string
+	tlsCheckFlag      bool
+	healthCheckFlag   bool
+	probeMembersFlag  bool
+	memberTimeoutFlag time.Duration
+	connTrendFlag     bool
+	connHistoryFlag   string
+	trendShortFlag    time.Duration
+	trendLongFlag     time.Duration
+
+	verifyAddressesFlag  bool
+	clusterDNSFlag       string
+	capacityFlag         uint64
+	dataPlanePortFlag    uint
+	dataPlaneBytesFlag   uint
+	dataPlaneTimeoutFlag time.Duration
+	certWarnFlag         time.Duration
+	historyFlag          uint
+	flapThresholdFlag    uint
+	timeoutFlag          time.Duration
+	relayTimeoutFlag     time.Duration
+	parallelFlag         uint
+	retriesFlag          uint
+	retryBackoffFlag     time.Duration
+	warnFlag             uint
+	critFlag             uint
+
+	influxMeasurementFlag string
 )
 
+// Flags are command line flags of the tool, parsed by Run.
+var Flags = flag.NewFlagSet("sonm-mon relay", flag.ExitOnError)
+
+// logger is used for diagnostics, results printed by
+// console sinks do not go through it.
+var logger = zap.NewNop()
+
+// logOptions are set by the -log flags, logs are JSON
+// as they were before the flags were shared.
+var logOptions = logging.Options{Name: "relay-mon", Format: "json"}
+
+// sinkOptions configure metrics backends, see the sink package.
+var sinkOptions = sink.Options{InfluxDatabase: "telegraf", InfluxBucket: "telegraf", StatsdPrefix: "relay", PushJob: "relay_mon"}
+
+// traceOptions configure exporting spans, see the tracing package.
+var traceOptions tracing.Options
+
+// selfHealth tracks polls of the daemon, so the monitor can be monitored.
+var selfHealth = health.New("relay-mon")
+
 func init() {
-	flag.StringVar(&endpointFlag, "endpoint", "", "relay monitoring endpoint")
-	flag.StringVar(&peerAddrFlag, "peer", "0x181b6f75B00e79382aa32D81c7734a46E9F9aF40", "relay peer address")
-	flag.UintVar(&expectedCountFlag, "count", 0, "how many members expect to see in the cluster")
-	flag.StringVar(&debugLogPath, "debugLog", "/tmp/relay_mon.log", "file to write debug info")
-	flag.Parse()
+	Flags.StringVar(&endpointFlag, "endpoint", "", "comma-separated relay monitoring endpoints: ip:port or 0xEth@ip:port")
+	Flags.StringVar(&endpointsFileFlag, "endpoints-file", "", "file with relay monitoring endpoints, one per line")
+	Flags.StringVar(&configFlag, "config", "", "YAML file describing relay clusters, replaces -endpoint, -endpoints-file and -count")
+	Flags.BoolVar(&consensusFlag, "consensus", false, "compare members seen by relays of the cluster, the majority view is expected if -count is not set")
+	Flags.BoolVar(&splitBrainFlag, "split-brain", false, "query cluster members seen by every member and report partitions")
+	Flags.UintVar(&memberPortFlag, "member-port", 0, "monitoring port of cluster members for -split-brain, the advertised one if 0")
+	Flags.BoolVar(&verifyAddressesFlag, "verify-addresses", false, "check addresses advertised by members against the relay address and the -dns record")
+	Flags.StringVar(&clusterDNSFlag, "dns", "", "DNS record expected to resolve to addresses advertised by members, see -verify-addresses")
+	Flags.StringVar(&geoipDBFlag, "geoip-db", "", "MaxMind city database to locate cluster members with")
+	Flags.UintVar(&geoPrecisionFlag, "geohash-precision", 4, "geohash precision of member locations")
+	Flags.StringVar(&keyFileFlag, "key-file", "", "keystore or hex private key file, a new key is generated every run if empty")
+	Flags.StringVar(&keyPasswordFlag, "key-password", "", "keystore password (KEY_PASSWORD)")
+	Flags.StringVar(&peerAddrFlag, "peer", "0x181b6f75B00e79382aa32D81c7734a46E9F9aF40", "relay peer address for endpoints given without one")
+	Flags.UintVar(&expectedCountFlag, "count", 0, "how many members expect to see in the cluster")
+	logOptions.RegisterFlags(Flags)
+	traceOptions.RegisterFlags(Flags)
+	Flags.StringVar(&debugLogPath, "debugLog", "", "deprecated, same as -log file:/path")
+	Flags.BoolVar(&daemonFlag, "daemon", false, "keep running and poll relays periodically")
+	Flags.DurationVar(&intervalFlag, "interval", 60*time.Second, "polling interval for the daemon mode")
+	Flags.StringVar(&formatFlag, "format", "telegraf", "console output format: telegraf or json")
+	Flags.StringVar(&templateFlag, "template", "", "Go text/template to print results with instead of -format, @path to read it from the file")
+	Flags.StringVar(&measurementFlag, "measurement", "relay_members", "measurement name of telegraf lines, the endpoint is a tag")
+	Flags.StringVar(&tagsFlag, "tags", "", "extra comma-separated key=value tags added to telegraf lines and influx points")
+	Flags.BoolVar(&legacyNamesFlag, "legacy-names", false, "print telegraf lines as relay_<ip>_members without tags")
+	Flags.StringVar(&alertURLFlag, "alert-url", "", "URL to POST an alert to when members differ from expected or there are too many connections")
+	Flags.DurationVar(&alertCooldownFlag, "alert-cooldown", 5*time.Minute, "minimal interval between notifications about the same relay")
+	Flags.DurationVar(&renotifyFlag, "renotify", time.Hour, "repeat notifications about ongoing conditions this often, 0 to notify once")
+	Flags.Uint64Var(&capacityFlag, "capacity", 0, "connections a relay is sized for, enables utilization and fleet headroom reports")
+	Flags.Uint64Var(&maxConnFlag, "max-conn", 0, "alert when a relay has more connections, 0 to disable")
+	Flags.DurationVar(&timeoutFlag, "timeout", 30*time.Second, "how long a single poll of all relays may take, retries included")
+	Flags.DurationVar(&relayTimeoutFlag, "relay-timeout", 10*time.Second, "how long querying a single relay may take, retries and probes included")
+	Flags.UintVar(&parallelFlag, "parallel", 10, "how many relays to query at once")
+	Flags.UintVar(&retriesFlag, "retries", 3, "how many times to retry failed relay requests")
+	Flags.DurationVar(&retryBackoffFlag, "retry-backoff", time.Second, "delay before the first retry, doubled on every next one")
+	Flags.StringVar(&telegramTokenFlag, "telegram-token", "", "telegram bot token to notify about degraded relays (TELEGRAM_TOKEN)")
+	Flags.StringVar(&telegramChatFlag, "telegram-chat", "", "telegram chat id to notify")
+	Flags.StringVar(&slackWebhookFlag, "slack-webhook", "", "slack incoming webhook to notify about degraded relays (SLACK_WEBHOOK)")
+	Flags.BoolVar(&probeMembersFlag, "probe-members", false, "dial every cluster member and report unreachable ones")
+	Flags.DurationVar(&memberTimeoutFlag, "member-timeout", 3*time.Second, "how long to wait for a cluster member to accept the connection")
+	Flags.UintVar(&dataPlanePortFlag, "dataplane-port", 0, "relay port to send test traffic through on every poll, 0 to disable")
+	Flags.UintVar(&dataPlaneBytesFlag, "dataplane-bytes", 1<<20, "how many bytes of test traffic to send through the relay")
+	Flags.DurationVar(&dataPlaneTimeoutFlag, "dataplane-timeout", 10*time.Second, "how long the data-plane probe may take")
+	Flags.BoolVar(&tlsCheckFlag, "tls-check", false, "verify TLS handshake, relay wallet and certificate validity on every poll")
+	Flags.BoolVar(&healthCheckFlag, "health-check", false, "query the standard gRPC health service of relays on every poll")
+	Flags.DurationVar(&certWarnFlag, "cert-warn", 24*time.Hour, "treat the relay as degraded when its certificate expires sooner, 0 to disable")
+	Flags.BoolVar(&connTrendFlag, "conn-trend", false, "report connection count trends and when -max-conn is going to be reached")
+	Flags.StringVar(&connHistoryFlag, "conn-history", "", "file to keep connection counts between runs in, up to 1024 samples per relay, kept in memory of the daemon if empty")
+	Flags.DurationVar(&trendShortFlag, "trend-short", time.Hour, "short connection trend window")
+	Flags.DurationVar(&trendLongFlag, "trend-long", 7*24*time.Hour, "long connection trend window, older samples are dropped")
+	Flags.StringVar(&stateFlag, "state", "", "file to keep member lists between runs in, enables joined/left reports")
+	Flags.UintVar(&historyFlag, "history", 10, "number of runs kept in the state file to count member flaps over")
+	Flags.UintVar(&flapThresholdFlag, "flap-threshold", 3, "treat a member as flapping if it joins or leaves this many times over -history runs, 0 to disable")
+	Flags.BoolVar(&checkFlag, "check", false, "run as a nagios check: print status line and exit with 0/1/2/3")
+	Flags.UintVar(&warnFlag, "warn", 1, "check mode: warning if members differ from expected by this many, 0 to disable")
+	Flags.UintVar(&critFlag, "crit", 2, "check mode: critical if members differ from expected by this many, 0 to disable")
+	Flags.StringVar(&listenFlag, "listen", "", "serve prometheus metrics and health endpoints at the given address, implies -daemon")
+	Flags.StringVar(&alertRulesFlag, "alert-rules", "", "evaluate alert rules of the YAML file against exported metrics, implies -daemon")
+	Flags.BoolVar(&sinkOptions.Influx, "write", false, "write data to influx instead of printing telegraf lines, same as -influx")
+
+	Flags.StringVar(&influxMeasurementFlag, "influx-measurement", "relay_members", "influx measurement name (INFLUX_MEASUREMENT)")
+	sinkOptions.RegisterFlags(Flags)
 }
 
-func main() {
-	if len(endpointFlag) == 0 {
-		fmt.Fprintln(os.Stderr, "host list is empty, exiting")
-		os.Exit(1)
+// Run parses the arguments and runs the tool,
+// the process exits on failures.
+func Run(args []string) {
+	if code := run(args); code != 0 {
+		os.Exit(code)
 	}
+}
 
-	if expectedCountFlag == 0 {
-		fmt.Fprintln(os.Stderr, "expected count cannot be zero")
-		os.Exit(1)
+// run returns the exit code instead of exiting, so deferred
+// connections, tracing and logs are closed and flushed first.
+func run(args []string) int {
+	Flags.Parse(args)
+
+	clusters, err := loadClusters(configFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot load clusters: %v\n", err)
+		return 1
+	}
+
+	if err := sinkOptions.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid output flags: %v\n", err)
+		return 1
+	}
+
+	if _, ok := consoleSinks[formatFlag]; !ok {
+		fmt.Fprintf(os.Stderr, "unknown output format `%s`\n", formatFlag)
+		return 1
+	}
+
+	var tmpl *template.Template
+	if len(templateFlag) > 0 {
+		if tmpl, err = parseTemplate(templateFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot parse template: %v\n", err)
+			return 1
+		}
 	}
 
-	// init logger
-	logFile, err := os.OpenFile(debugLogPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if parallelFlag == 0 {
+		fmt.Fprintln(os.Stderr, "parallelism cannot be zero")
+		return 1
+	}
+
+	if geoPrecisionFlag < 1 || geoPrecisionFlag > 12 {
+		fmt.Fprintln(os.Stderr, "geohash precision must be between 1 and 12")
+		return 1
+	}
+
+	staticTags, err = parseTags(tagsFlag)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "cannot open file for wrinig: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "cannot parse tags: %v\n", err)
+		return 1
 	}
 
-	defer logFile.Close()
-	log.SetOutput(logFile)
+	if len(debugLogPath) > 0 {
+		logOptions.Output = "file:" + debugLogPath
+	}
 
-	key, err := crypto.GenerateKey()
+	logger, err = logOptions.New()
 	if err != nil {
-		log.Printf("cannot generate key: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "cannot create logger: %v\n", err)
+		return 1
+	}
+	defer logger.Sync()
+
+	stopTracing, err := traceOptions.Setup("relay-mon")
+	if err != nil {
+		logger.Error("cannot set up tracing", zap.Error(err))
+		return 1
+	}
+	defer stopTracing()
+
+	if len(geoipDBFlag) > 0 {
+		db, err := geo.OpenMaxMind(geoipDBFlag)
+		if err != nil {
+			logger.Error("cannot open geoip db", zap.Error(err))
+			return 1
+		}
+
+		defer db.Close()
+		memberResolver = db
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	_, TLSConfig, err := util.NewHitlessCertRotator(ctx, key)
+	id, err := sonmclient.NewIdentity(keyFileFlag, keyPasswordFlag)
 	if err != nil {
-		log.Printf("cannot create TLS config: %v\n", err)
-		os.Exit(1)
+		logger.Error("cannot set up identity", zap.Error(err))
+		return 1
+	}
+	logger.Info("using identity", zap.String("eth", id.Eth().Hex()))
+
+	var targets []*relayTarget
+	for _, c := range clusters {
+		for _, endpoint := range c.Endpoints {
+			t, err := newRelayTarget(ctx, endpoint, c, id)
+			if err != nil {
+				if checkFlag {
+					fmt.Printf("RELAY UNKNOWN - %v\n", err)
+					return checkUnknown
+				}
+
+				logger.Error("cannot connect to relay", zap.Error(err))
+				return 1
+			}
+
+			defer t.Close()
+			targets = append(targets, t)
+		}
 	}
 
-	creds := auth.NewWalletAuthenticator(util.NewTLS(TLSConfig), common.HexToAddress(peerAddrFlag))
-	client, err := xgrpc.NewClient(ctx, endpointFlag, creds)
+	if checkFlag {
+		return runCheck(ctx, targets)
+	}
+
+	// relays of the latest poll are served for map-proxy
+	tool := &daemon.Tool{
+		Name:       "relay-mon",
+		Logger:     logger,
+		Health:     selfHealth,
+		Daemon:     daemonFlag,
+		Interval:   intervalFlag,
+		Timeout:    timeoutFlag,
+		Listen:     listenFlag,
+		Handlers:   map[string]http.Handler{"/relays": relayNodes},
+		AlertRules: alertRulesFlag,
+	}
+
+	sinks := outputSinks(targets, tmpl, tool.Exporter())
+	err = tool.Run(ctx, func(ctx context.Context) error {
+		return poll(ctx, targets, sinks)
+	})
 	if err != nil {
-		log.Printf("cannot create client connection: %v\n", err)
-		os.Exit(1)
+		logger.Error("run failed", zap.Error(err))
+		return 1
 	}
 
-	relay := sonm.NewRelayClient(client)
-	clusterChan := make(chan *sonm.RelayClusterReply)
-	metricsChan := make(chan *sonm.RelayMetrics)
+	return 0
+}
+
+// memberResolver locates cluster members, it is set with -geoip-db.
+var memberResolver geo.Resolver
+
+// staticTags are parsed from -tags.
+var staticTags map[string]string
+
+// tlsPassed keeps whether TLS checks of relays have passed
+// during the previous poll to alert on failures only once.
+var tlsPassed = map[string]bool{}
+
+// healthStatuses keeps serving statuses reported by relays
+// during the previous poll to record status transitions.
+var healthStatuses = map[string]string{}
+
+// poll queries up to -parallel relays at once, each within -relay-timeout,
+// and writes results of reachable relays even if some of the others
+// have failed or timed out.
+func poll(ctx context.Context, targets []*relayTarget, sinks []Sink) error {
+	ctx, span := tracing.StartSpan(ctx, "poll")
+	defer span.End()
 
-	go func() {
-		cluster, err := relay.Cluster(ctx, &sonm.Empty{})
+	started := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, timeoutFlag)
+	defer cancel()
+
+	// errors are kept per relay instead of being returned to
+	// the group, so a failed relay does not cancel the others
+	g := errgroup.Group{}
+	sem := make(chan struct{}, parallelFlag)
+	results := make([]*relayStats, len(targets))
+	errs := make([]error, len(targets))
+
+	for i, t := range targets {
+		i, t := i, t
+		g.Go(func() error {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				// TLS results are reported for every relay
+				if tlsCheckFlag {
+					t.tls = &tlsStatus{err: fmt.Errorf("relay has not been queried: %v", ctx.Err())}
+				}
+				return nil
+			}
+			defer func() { <-sem }()
+
+			ctx, cancel := context.WithTimeout(ctx, relayTimeoutFlag)
+			defer cancel()
+
+			if tlsCheckFlag {
+				t.tls = t.checkTLS(ctx)
+			}
+			if healthCheckFlag {
+				t.health = t.checkHealth(ctx)
+			}
+			results[i], errs[i] = t.collect(ctx)
+			return nil
+		})
+	}
+
+	g.Wait()
+
+	var ok []*relayStats
+	failed := 0
+	for i, err := range errs {
 		if err != nil {
-			log.Printf("cannot query cluster members: %v\n", err)
-			os.Exit(1)
+			logger.Warn("cannot query relay", zap.String("endpoint", targets[i].endpoint), zap.Error(err))
+			failed++
+			continue
 		}
 
-		clusterChan <- cluster
-	}()
+		results[i].time = started
+		ok = append(ok, results[i])
+	}
 
-	go func() {
-		metrics, err := relay.Metrics(ctx, &sonm.Empty{})
-		if err != nil {
-			log.Printf("cannot query metrics: %v\n", err)
-			os.Exit(1)
+	if consensusFlag {
+		applyConsensus(ok)
+	}
+
+	if splitBrainFlag {
+		applyPartitions(ctx, targets, ok)
+	}
+
+	if connTrendFlag && len(ok) > 0 {
+		if err := applyConnTrend(connHistoryFlag, ok); err != nil {
+			logger.Warn("cannot update connection history", zap.Error(err))
+		}
+	}
+
+	if len(stateFlag) > 0 && len(ok) > 0 {
+		if err := applyMembership(stateFlag, int(historyFlag), ok); err != nil {
+			logger.Warn("cannot update membership state", zap.Error(err))
+		}
+	}
+
+	if tlsCheckFlag {
+		if err := writeTLS(targets, tlsPassed); err != nil {
+			logger.Warn("cannot report TLS checks", zap.Error(err))
 		}
+	}
 
-		metricsChan <- metrics
-	}()
+	if healthCheckFlag {
+		writeHealth(targets, healthStatuses)
+	}
 
-	cluster := <-clusterChan
-	metrics := <-metricsChan
+	// sinks are called even if all relays have failed, so
+	// exported gauges are reset and notifications are sent
+	writeFailed := 0
+	for _, s := range sinks {
+		if err := s.Write(ok); err != nil {
+			logger.Warn("cannot write results", zap.Error(err))
+			writeFailed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d relays failed", failed, len(targets))
+	}
 
-	// calculate metrics
-	members := len(cluster.GetMembers())
-	membersDiff := uint(members) - expectedCountFlag
-	iponly := strings.Replace(strings.Split(endpointFlag, ":")[0], ".", "_", 4)
-	connCount := metrics.GetConnCurrent()
+	if writeFailed > 0 {
+		return fmt.Errorf("%d of %d outputs failed", writeFailed, len(sinks))
+	}
 
-	// show metrics to telegraf collector
-	fmt.Printf("relay_%s_members count=%d,expect=%d,diff=%d,conn_count=%d\n",
-		iponly, members, expectedCountFlag, membersDiff, connCount)
+	return nil
 }
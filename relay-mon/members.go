@@ -0,0 +1,84 @@
+package relaymon
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// memberFields returns per-member fields keyed by the member. Members
+// which have left since the previous poll are reported as not present,
+// other fields depend on enabled modes.
+func memberFields(stats *relayStats) map[string]map[string]interface{} {
+	fields := map[string]map[string]interface{}{}
+	for _, m := range stats.members {
+		fields[m] = map[string]interface{}{"present": 1}
+	}
+
+	if ch := stats.membership; ch != nil {
+		for _, m := range ch.left {
+			fields[m] = map[string]interface{}{"present": 0}
+		}
+	}
+
+	for m, f := range fields {
+		if ok, probed := stats.reachable[m]; probed {
+			f["up"] = boolToInt(ok)
+		}
+
+		if ch := stats.membership; ch != nil {
+			f["flaps"] = ch.flaps[m]
+			f["flap_score"] = ch.flapScore(m)
+		}
+
+		net := stats.metrics.GetNet()
+		for _, key := range []string{m, memberAddr(m)} {
+			if counters, ok := net[key]; ok {
+				f["tx_bytes"] = int64(counters.GetTxBytes())
+				f["rx_bytes"] = int64(counters.GetRxBytes())
+				break
+			}
+		}
+	}
+
+	return fields
+}
+
+// memberKeys returns members of the fields sorted.
+func memberKeys(fields map[string]map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for m := range fields {
+		keys = append(keys, m)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// formatFields formats fields for the telegraf line sorted by the name.
+func formatFields(fields map[string]interface{}) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		v := fields[name]
+		if f, ok := v.(float64); ok {
+			v = fmt.Sprintf("%.3f", f)
+		}
+		pairs = append(pairs, fmt.Sprintf("%s=%v", tagEscaper.Replace(name), v))
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func boolToInt(v bool) int {
+	if v {
+		return 1
+	}
+
+	return 0
+}
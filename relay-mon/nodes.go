@@ -0,0 +1,84 @@
+package relaymon
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// relayNode is the relay as shown on the infrastructure map,
+// map-proxy polls them from /relays.
+type relayNode struct {
+	Endpoint    string    `json:"endpoint"`
+	Cluster     string    `json:"cluster,omitempty"`
+	Time        time.Time `json:"time"`
+	Up          bool      `json:"up"`
+	Lat         float64   `json:"lat"`
+	Lon         float64   `json:"lon"`
+	City        string    `json:"city,omitempty"`
+	Country     string    `json:"country,omitempty"`
+	Members     int       `json:"members"`
+	ConnCurrent uint64    `json:"conn_current"`
+	Capacity    uint64    `json:"capacity,omitempty"`
+	Utilization float64   `json:"utilization,omitempty"`
+}
+
+// nodesSink keeps relays of the latest poll, unreachable ones
+// included, to serve them on /relays. Relays are located
+// only if -geoip-db is given.
+type nodesSink struct {
+	targets []*relayTarget
+
+	mu     sync.Mutex
+	latest []relayNode
+}
+
+var relayNodes = &nodesSink{latest: []relayNode{}}
+
+func (s *nodesSink) Write(results []*relayStats) error {
+	byEndpoint := map[string]*relayStats{}
+	for _, stats := range results {
+		byEndpoint[stats.endpoint] = stats
+	}
+
+	nodes := make([]relayNode, 0, len(s.targets))
+	for _, t := range s.targets {
+		node := relayNode{Endpoint: t.endpoint, Cluster: t.cluster.Name, Time: time.Now()}
+		if stats, ok := byEndpoint[t.endpoint]; ok {
+			node.Up = true
+			node.Time = stats.time
+			node.Members = len(stats.members)
+			node.ConnCurrent = stats.metrics.GetConnCurrent()
+			node.Capacity = stats.capacity()
+			node.Utilization = stats.utilization()
+		}
+
+		if memberResolver != nil {
+			if loc, err := locateMember(memberResolver, t.endpoint); err == nil {
+				node.Lat, node.Lon, node.City, node.Country = loc.Lat, loc.Lon, loc.City, loc.Country
+			} else {
+				logger.Debug("cannot locate relay", zap.String("endpoint", t.endpoint), zap.Error(err))
+			}
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	s.mu.Lock()
+	s.latest = nodes
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *nodesSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	nodes := s.latest
+	s.mu.Unlock()
+
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(nodes)
+}
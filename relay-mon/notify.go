@@ -0,0 +1,140 @@
+package relaymon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const telegramAPI = "https://api.telegram.org"
+
+// notifier delivers a human readable message to a chat.
+type notifier interface {
+	notify(text string) error
+}
+
+type telegramNotifier struct {
+	token  string
+	chatID string
+}
+
+func (n *telegramNotifier) notify(text string) error {
+	resp, err := (&http.Client{Timeout: alertTimeout}).PostForm(telegramAPI+"/bot"+n.token+"/sendMessage", url.Values{
+		"chat_id": {n.chatID},
+		"text":    {text},
+	})
+	if err != nil {
+		// the error contains the URL, which contains the token
+		return fmt.Errorf("cannot send telegram message: %v", strings.Replace(err.Error(), n.token, "<token>", -1))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("telegram responded with %s", resp.Status)
+	}
+
+	return nil
+}
+
+type slackNotifier struct {
+	webhook string
+}
+
+func (n *slackNotifier) notify(text string) error {
+	b, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+
+	resp, err := (&http.Client{Timeout: alertTimeout}).Post(n.webhook, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("cannot send slack message: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("slack responded with %s", resp.Status)
+	}
+
+	return nil
+}
+
+// stateNotifier sends messages when a relay degrades or recovers and
+// reminds about ongoing degradations, see alertTracker. A relay missing
+// from results because it could not be queried is degraded too.
+type stateNotifier struct {
+	targets   []*relayTarget
+	notifiers []notifier
+	tracker   *alertTracker
+}
+
+func newStateNotifier(targets []*relayTarget, notifiers []notifier) *stateNotifier {
+	return &stateNotifier{targets: targets, notifiers: notifiers, tracker: newAlertTracker()}
+}
+
+func (n *stateNotifier) Write(results []*relayStats) error {
+	reasons := map[string][]string{}
+	for _, t := range n.targets {
+		reasons[t.endpoint] = []string{"relay cannot be queried"}
+	}
+
+	for _, stats := range results {
+		reasons[stats.endpoint] = alertReasons(stats)
+	}
+
+	now := time.Now()
+	var messages []string
+	for _, t := range n.targets {
+		endpoint := t.endpoint
+		reasons[endpoint] = append(reasons[endpoint], tlsReasons(t)...)
+		reasons[endpoint] = append(reasons[endpoint], healthReasons(t)...)
+
+		switch n.tracker.update(endpoint, reasons[endpoint], now) {
+		case alertFiring:
+			messages = append(messages, fmt.Sprintf("relay %s degraded: %s", endpoint, strings.Join(reasons[endpoint], ", ")))
+		case alertRepeat:
+			messages = append(messages, fmt.Sprintf("relay %s is still degraded: %s", endpoint, strings.Join(reasons[endpoint], ", ")))
+		case alertRecovered:
+			messages = append(messages, fmt.Sprintf("relay %s recovered", endpoint))
+		}
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	text := strings.Join(messages, "\n")
+	failed := 0
+	for _, nt := range n.notifiers {
+		if err := nt.notify(text); err != nil {
+			logger.Warn("cannot send notification", zap.Error(err))
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d notifications failed", failed, len(n.notifiers))
+	}
+
+	return nil
+}
+
+// chatNotifiers returns notifiers configured by flags.
+func chatNotifiers() []notifier {
+	var notifiers []notifier
+	if len(telegramTokenFlag) > 0 && len(telegramChatFlag) > 0 {
+		notifiers = append(notifiers, &telegramNotifier{token: telegramTokenFlag, chatID: telegramChatFlag})
+	}
+
+	if len(slackWebhookFlag) > 0 {
+		notifiers = append(notifiers, &slackNotifier{webhook: slackWebhookFlag})
+	}
+
+	return notifiers
+}
@@ -0,0 +1,136 @@
+package relaymon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sonm-io/core/proto"
+	"go.uber.org/zap"
+)
+
+// partition is a group of members seeing the same cluster.
+type partition struct {
+	// view is the sorted list of members seen from the partition.
+	view []string
+	// seenBy are members having this view.
+	seenBy []string
+}
+
+// memberEndpoint returns the monitoring endpoint of the member,
+// its port is replaced with -member-port if set.
+func memberEndpoint(member string) string {
+	if memberPortFlag == 0 {
+		return member
+	}
+
+	prefix, addr := "", member
+	if i := strings.LastIndex(member, "@"); i >= 0 {
+		prefix, addr = member[:i+1], member[i+1:]
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	return prefix + net.JoinHostPort(host, fmt.Sprint(memberPortFlag))
+}
+
+// queryView asks the member which members it sees.
+func (t *relayTarget) queryView(ctx context.Context, member string) ([]string, error) {
+	mt, err := newRelayTarget(ctx, memberEndpoint(member), t.cluster, t.id)
+	if err != nil {
+		return nil, err
+	}
+	defer mt.Close()
+
+	var cluster *sonm.RelayClusterReply
+	err = withRetry(ctx, "cluster of "+mt.endpoint, func() error {
+		var err error
+		cluster, err = mt.relay.Cluster(ctx, &sonm.Empty{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	view := append([]string{}, cluster.GetMembers()...)
+	sort.Strings(view)
+	return view, nil
+}
+
+// applyPartitions queries views of all members seen by relays of the
+// same cluster and groups members by the view. More than one partition
+// means the cluster is split, unreachable members are not counted.
+func applyPartitions(ctx context.Context, targets []*relayTarget, results []*relayStats) {
+	byCluster := map[*cluster][]*relayStats{}
+	for _, stats := range results {
+		byCluster[stats.cluster] = append(byCluster[stats.cluster], stats)
+	}
+
+	querier := map[*cluster]*relayTarget{}
+	for _, t := range targets {
+		querier[t.cluster] = t
+	}
+
+	for c, group := range byCluster {
+		members := map[string]bool{}
+		for _, stats := range group {
+			for _, m := range stats.members {
+				members[m] = true
+			}
+		}
+
+		mu := sync.Mutex{}
+		wg := sync.WaitGroup{}
+		views := map[string]*partition{}
+		for m := range members {
+			wg.Add(1)
+			go func(m string) {
+				defer wg.Done()
+
+				view, err := querier[c].queryView(ctx, m)
+				if err != nil {
+					logger.Debug("cannot query member view", zap.String("member", m), zap.Error(err))
+					return
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				key := strings.Join(view, ",")
+				if _, ok := views[key]; !ok {
+					views[key] = &partition{view: view}
+				}
+				views[key].seenBy = append(views[key].seenBy, m)
+			}(m)
+		}
+		wg.Wait()
+
+		partitions := make([]partition, 0, len(views))
+		for _, p := range views {
+			sort.Strings(p.seenBy)
+			partitions = append(partitions, *p)
+		}
+
+		sort.Slice(partitions, func(i, j int) bool {
+			if len(partitions[i].seenBy) != len(partitions[j].seenBy) {
+				return len(partitions[i].seenBy) > len(partitions[j].seenBy)
+			}
+
+			return partitions[i].seenBy[0] < partitions[j].seenBy[0]
+		})
+
+		if len(partitions) > 1 {
+			logger.Warn("relay cluster is split", zap.String("cluster", c.Name), zap.Int("partitions", len(partitions)))
+		}
+
+		for _, stats := range group {
+			stats.partitions = partitions
+		}
+	}
+}
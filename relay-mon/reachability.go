@@ -0,0 +1,63 @@
+package relaymon
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// memberAddr strips the wallet part from the member,
+// members are either "ip:port" or "0xEth@ip:port".
+func memberAddr(member string) string {
+	if i := strings.LastIndex(member, "@"); i >= 0 {
+		return member[i+1:]
+	}
+
+	return member
+}
+
+// probeMembers dials every cluster member seen by the relay and
+// returns whether it accepts TCP connections, keyed by the member.
+func probeMembers(ctx context.Context, members []string) map[string]bool {
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	up := make(map[string]bool, len(members))
+
+	for _, member := range members {
+		wg.Add(1)
+		go func(member string) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, memberTimeoutFlag)
+			defer cancel()
+
+			conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", memberAddr(member))
+			if err == nil {
+				conn.Close()
+			}
+
+			mu.Lock()
+			up[member] = err == nil
+			mu.Unlock()
+		}(member)
+	}
+
+	wg.Wait()
+	return up
+}
+
+// downMembers returns unreachable members sorted,
+// nothing if members have not been probed.
+func (s *relayStats) downMembers() []string {
+	var down []string
+	for member, ok := range s.reachable {
+		if !ok {
+			down = append(down, member)
+		}
+	}
+
+	sort.Strings(down)
+	return down
+}
@@ -0,0 +1,230 @@
+package relaymon
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sonm-io/core/proto"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sonmclient"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// relayTarget is a single monitored relay.
+type relayTarget struct {
+	endpoint string
+	conn     *grpc.ClientConn
+	relay    sonm.RelayClient
+	// creds authenticate the relay against the expected wallet.
+	creds credentials.TransportCredentials
+	// tls is the result of the latest check, -tls-check mode.
+	tls *tlsStatus
+	// health is the latest serving status, -health-check mode.
+	health string
+	// cluster has the expected size and thresholds.
+	cluster *cluster
+	// id is used to connect to other members of the cluster
+	// and to publish and dial the data-plane probe server.
+	id *sonmclient.Identity
+}
+
+// relayStats is a result of a single relay poll.
+type relayStats struct {
+	endpoint string
+	cluster  *cluster
+	// expected is the number of members the relay should see, the
+	// cluster count or the consensus size if the count is not set.
+	expected uint
+	// time is when the poll has started, it is
+	// the same for all relays queried during the cycle.
+	time    time.Time
+	members []string
+	metrics *sonm.RelayMetrics
+	// scrapeTime is how long both requests took.
+	scrapeTime time.Duration
+	// membership is set only when the state file is used and
+	// the relay was seen during the previous poll.
+	membership *membershipChange
+	// reachable tells whether members accept connections,
+	// it is nil unless members are probed.
+	reachable map[string]bool
+	// addressMismatches are set with -verify-addresses.
+	addressMismatches []string
+	// trend is set with -conn-trend.
+	trend *connTrend
+	// locations are set when members are located with -geoip-db.
+	locations []memberLocation
+	// partitions are member groups seeing the same members,
+	// set in the -split-brain mode.
+	partitions []partition
+	// consensus is set in the -consensus mode.
+	consensus *consensus
+	// dataPlane is set when the data-plane probe is enabled.
+	dataPlane *dataPlaneResult
+}
+
+// loadEndpoints merges comma-separated list of relays with the ones
+// listed in the file. Empty lines and lines starting with # are ignored.
+func loadEndpoints(list, path string) ([]string, error) {
+	var endpoints []string
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			endpoints = append(endpoints, v)
+		}
+	}
+
+	if len(path) == 0 {
+		return endpoints, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		v := strings.TrimSpace(scanner.Text())
+		if len(v) == 0 || strings.HasPrefix(v, "#") {
+			continue
+		}
+
+		endpoints = append(endpoints, v)
+	}
+
+	return endpoints, scanner.Err()
+}
+
+// newRelayTarget connects to the relay, the endpoint is either "ip:port"
+// authenticated with the cluster peer address or "0xEth@ip:port".
+func newRelayTarget(ctx context.Context, endpoint string, c *cluster, id *sonmclient.Identity) (*relayTarget, error) {
+	if !strings.Contains(endpoint, "@") {
+		endpoint = c.Peer + "@" + endpoint
+	}
+
+	conn, err := id.Dial(ctx, endpoint, clientOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	return &relayTarget{
+		endpoint: conn.Addr,
+		conn:     conn.ClientConn,
+		relay:    sonm.NewRelayClient(conn.ClientConn),
+		creds:    conn.Creds,
+		id:       id,
+		cluster:  c,
+	}, nil
+}
+
+func (t *relayTarget) Close() error {
+	return t.conn.Close()
+}
+
+// collect queries cluster members and metrics of the relay concurrently.
+func (t *relayTarget) collect(ctx context.Context) (*relayStats, error) {
+	started := time.Now()
+	clusterChan := make(chan *sonm.RelayClusterReply, 1)
+	errChan := make(chan error, 1)
+
+	go func() {
+		var cluster *sonm.RelayClusterReply
+		err := withRetry(ctx, "cluster of "+t.endpoint, func() error {
+			var err error
+			cluster, err = t.relay.Cluster(ctx, &sonm.Empty{})
+			return err
+		})
+		if err != nil {
+			errChan <- fmt.Errorf("cannot query cluster members: %v", err)
+			return
+		}
+
+		clusterChan <- cluster
+	}()
+
+	var metrics *sonm.RelayMetrics
+	err := withRetry(ctx, "metrics of "+t.endpoint, func() error {
+		var err error
+		metrics, err = t.relay.Metrics(ctx, &sonm.Empty{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot query metrics: %v", err)
+	}
+
+	select {
+	case err := <-errChan:
+		return nil, err
+	case cluster := <-clusterChan:
+		stats := &relayStats{
+			endpoint:   t.endpoint,
+			cluster:    t.cluster,
+			expected:   t.cluster.Count,
+			members:    cluster.GetMembers(),
+			metrics:    metrics,
+			scrapeTime: time.Since(started),
+		}
+
+		if verifyAddressesFlag {
+			stats.addressMismatches = t.verifyAddresses(ctx, stats.members)
+		}
+
+		if memberResolver != nil {
+			stats.locations = locateMembers(memberResolver, stats.members)
+		}
+
+		if probeMembersFlag {
+			stats.reachable = probeMembers(ctx, stats.members)
+		}
+
+		if dataPlanePortFlag > 0 {
+			stats.dataPlane = t.probeDataPlane(ctx)
+		}
+
+		return stats, nil
+	}
+}
+
+// traffic sums up bytes sent and received over all
+// connections the relay reports network counters for.
+func (s *relayStats) traffic() (tx, rx uint64) {
+	for _, m := range s.metrics.GetNet() {
+		tx += m.GetTxBytes()
+		rx += m.GetRxBytes()
+	}
+
+	return tx, rx
+}
+
+// netKeys returns keys of per-member network counters sorted.
+func (s *relayStats) netKeys() []string {
+	keys := make([]string, 0, len(s.metrics.GetNet()))
+	for key := range s.metrics.GetNet() {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// diff is the signed difference between seen and expected cluster
+// members, negative when some of the members are missing.
+func (s *relayStats) diff() int {
+	return len(s.members) - int(s.expected)
+}
+
+// deviation is the absolute value of diff.
+func (s *relayStats) deviation() uint {
+	d := s.diff()
+	if d < 0 {
+		return uint(-d)
+	}
+
+	return uint(d)
+}
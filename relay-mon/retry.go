@@ -0,0 +1,17 @@
+package relaymon
+
+import (
+	"context"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sonmclient"
+)
+
+// clientOptions are options of relay connections set by flags.
+func clientOptions() sonmclient.Options {
+	return sonmclient.Options{DialTimeout: relayTimeoutFlag, Retries: retriesFlag, Backoff: retryBackoffFlag, Logger: logger}
+}
+
+// withRetry retries failed relay requests, see sonmclient.Options.Retry.
+func withRetry(ctx context.Context, what string, fn func() error) error {
+	return clientOptions().Retry(ctx, what, fn)
+}
@@ -0,0 +1,61 @@
+package relaymon
+
+import (
+	"text/template"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+)
+
+// Sink outputs results of a single poll.
+type Sink interface {
+	Write(results []*relayStats) error
+}
+
+// SinkFunc allows using ordinary functions as sinks.
+type SinkFunc func(results []*relayStats) error
+
+func (f SinkFunc) Write(results []*relayStats) error {
+	return f(results)
+}
+
+// outputSinks returns sinks enabled by flags, results are printed
+// using the template or in the -format if there are no others.
+// Results are exported as metrics too unless the exporter is nil.
+func outputSinks(targets []*relayTarget, tmpl *template.Template, exporter *sink.Exporter) []Sink {
+	var sinks []Sink
+	for _, b := range sinkOptions.Sinks() {
+		sinks = append(sinks, pointSink(b))
+	}
+
+	// alert rules are evaluated against the exported metrics
+	if exporter != nil {
+		relayNodes.targets = targets
+		sinks = append(sinks, pointSink(exporter), relayNodes)
+	}
+
+	if len(sinks) == 0 && tmpl != nil {
+		sinks = append(sinks, templateSink(tmpl))
+	}
+
+	if len(sinks) == 0 {
+		sinks = append(sinks, consoleSinks[formatFlag])
+	}
+
+	// alerts do not replace the console output
+	if len(alertURLFlag) > 0 {
+		sinks = append(sinks, newWebhookAlerter())
+	}
+
+	if notifiers := chatNotifiers(); len(notifiers) > 0 {
+		sinks = append(sinks, newStateNotifier(targets, notifiers))
+	}
+
+	return sinks
+}
+
+// pointSink writes results converted to points to the backend.
+func pointSink(backend sink.Sink) Sink {
+	return SinkFunc(func(results []*relayStats) error {
+		return backend.Write(influxPoints(results))
+	})
+}
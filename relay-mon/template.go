@@ -0,0 +1,56 @@
+package relaymon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/template"
+)
+
+var templateFuncs = template.FuncMap{
+	"join":    strings.Join,
+	"replace": strings.Replace,
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	// host strips the port from the endpoint
+	"host": func(endpoint string) string {
+		return strings.Split(endpoint, ":")[0]
+	},
+	"unix": func(v interface{}) (int64, error) {
+		switch t := v.(type) {
+		case interface{ Unix() int64 }:
+			return t.Unix(), nil
+		default:
+			return 0, fmt.Errorf("cannot convert %T to unix time", v)
+		}
+	},
+}
+
+// parseTemplate parses the -template value, which is either the
+// template itself or @path of the file containing it. Results are
+// passed as the list of relays having the same fields as JSON output,
+// e.g. '{{range .}}{{.Endpoint}} {{.ConnCurrent}}{{"\n"}}{{end}}'.
+func parseTemplate(value string) (*template.Template, error) {
+	text := value
+	if strings.HasPrefix(value, "@") {
+		data, err := ioutil.ReadFile(strings.TrimPrefix(value, "@"))
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	}
+
+	return template.New("output").Funcs(templateFuncs).Parse(text)
+}
+
+// templateSink prints results using the template.
+func templateSink(tmpl *template.Template) Sink {
+	return SinkFunc(func(results []*relayStats) error {
+		if err := tmpl.Execute(os.Stdout, jsonRelays(results)); err != nil {
+			return fmt.Errorf("cannot execute template: %v", err)
+		}
+
+		return nil
+	})
+}
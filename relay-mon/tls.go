@@ -0,0 +1,137 @@
+package relaymon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sonm-io/core/util"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/credentials"
+)
+
+var (
+	tlsAuthGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "relay_tls_auth_ok",
+		Help: "Whether the relay passes TLS handshake with the expected wallet and a valid certificate, -tls-check mode.",
+	}, []string{"endpoint"})
+
+	certExpiryGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "relay_cert_expiry_seconds",
+		Help: "Time left until the relay certificate expires, -tls-check mode.",
+	}, []string{"endpoint"})
+)
+
+func init() {
+	prometheus.MustRegister(tlsAuthGauge, certExpiryGauge)
+}
+
+// tlsStatus is a result of the handshake made
+// separately from the relay queries.
+type tlsStatus struct {
+	// wallet is the address the relay has authenticated
+	// with, known only if the handshake succeeds.
+	wallet    common.Address
+	notBefore time.Time
+	notAfter  time.Time
+	err       error
+}
+
+// checkTLS performs the TLS handshake with the relay authenticating it
+// against the expected wallet and checks its certificate validity window.
+func (t *relayTarget) checkTLS(ctx context.Context) *tlsStatus {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", t.endpoint)
+	if err != nil {
+		return &tlsStatus{err: fmt.Errorf("cannot connect: %v", err)}
+	}
+	defer conn.Close()
+
+	_, info, err := t.creds.ClientHandshake(ctx, t.endpoint, conn)
+	if err != nil {
+		return &tlsStatus{err: fmt.Errorf("handshake failed: %v", err)}
+	}
+
+	st := &tlsStatus{}
+	var tlsInfo credentials.TLSInfo
+	switch v := info.(type) {
+	case util.EthAuthInfo:
+		st.wallet, tlsInfo = v.Wallet, v.TLS
+	case credentials.TLSInfo:
+		tlsInfo = v
+	}
+
+	certs := tlsInfo.State.PeerCertificates
+	if len(certs) == 0 {
+		st.err = fmt.Errorf("relay has presented no certificate")
+		return st
+	}
+
+	st.notBefore, st.notAfter = certs[0].NotBefore, certs[0].NotAfter
+	if now := time.Now(); now.Before(st.notBefore) || now.After(st.notAfter) {
+		st.err = fmt.Errorf("certificate is valid from %s till %s only",
+			st.notBefore.Format(time.RFC3339), st.notAfter.Format(time.RFC3339))
+	}
+
+	return st
+}
+
+// tlsReasons explains why the TLS check of the relay has failed
+// or is about to fail, nothing is returned if it has not been made.
+func tlsReasons(t *relayTarget) []string {
+	switch {
+	case t.tls == nil:
+		return nil
+	case t.tls.err != nil:
+		return []string{fmt.Sprintf("TLS check failed: %v", t.tls.err)}
+	case certWarnFlag > 0 && time.Until(t.tls.notAfter) < certWarnFlag:
+		return []string{fmt.Sprintf("certificate expires at %s", t.tls.notAfter.Format(time.RFC3339))}
+	}
+
+	return nil
+}
+
+// writeTLS reports results of the latest TLS checks, the webhook
+// alert is posted only when the check of a relay starts failing.
+func writeTLS(targets []*relayTarget, previous map[string]bool) error {
+	tlsAuthGauge.Reset()
+	certExpiryGauge.Reset()
+
+	failed := 0
+	for _, t := range targets {
+		ok := t.tls.err == nil
+		if ok {
+			tlsAuthGauge.WithLabelValues(t.endpoint).Set(1)
+			certExpiryGauge.WithLabelValues(t.endpoint).Set(time.Until(t.tls.notAfter).Seconds())
+		} else {
+			tlsAuthGauge.WithLabelValues(t.endpoint).Set(0)
+			logger.Warn("TLS check failed", zap.String("endpoint", t.endpoint), zap.Error(t.tls.err))
+		}
+
+		wasOK, seen := previous[t.endpoint]
+		previous[t.endpoint] = ok
+		if ok || (seen && !wasOK) || len(alertURLFlag) == 0 {
+			continue
+		}
+
+		err := postAlert(relayAlert{
+			Endpoint: t.endpoint,
+			Time:     time.Now(),
+			Reasons:  tlsReasons(t),
+			Cluster:  t.cluster.Name,
+			Expected: t.cluster.Count,
+		})
+		if err != nil {
+			logger.Warn("cannot send TLS alert", zap.String("endpoint", t.endpoint), zap.Error(err))
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d TLS alerts failed", failed)
+	}
+
+	return nil
+}
@@ -0,0 +1,245 @@
+package relaymon
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// connSample is the number of connections served by the relay at the time.
+type connSample struct {
+	Time time.Time `json:"time"`
+	Conn uint64    `json:"conn"`
+}
+
+// connSamplesMax is how many samples are kept per relay, samples are
+// taken at least 1/connSamplesMax of the long window apart, so the
+// history covers the window however often relays are polled.
+const connSamplesMax = 1024
+
+// connRing keeps the latest samples of a relay in a fixed buffer,
+// the oldest sample is overwritten once it is full.
+type connRing struct {
+	buf   []connSample
+	start int
+	n     int
+}
+
+func newConnRing() *connRing {
+	return &connRing{buf: make([]connSample, connSamplesMax)}
+}
+
+// push appends the sample unless the previous one is more recent
+// than the spacing, false is returned then.
+func (r *connRing) push(s connSample, spacing time.Duration) bool {
+	if r.n > 0 && s.Time.Sub(r.buf[(r.start+r.n-1)%len(r.buf)].Time) < spacing {
+		return false
+	}
+
+	if r.n < len(r.buf) {
+		r.buf[(r.start+r.n)%len(r.buf)] = s
+		r.n++
+	} else {
+		r.buf[r.start] = s
+		r.start = (r.start + 1) % len(r.buf)
+	}
+
+	return true
+}
+
+// dropBefore drops samples taken before the time, true is
+// returned if any sample is dropped.
+func (r *connRing) dropBefore(t time.Time) bool {
+	dropped := false
+	for r.n > 0 && r.buf[r.start].Time.Before(t) {
+		r.start = (r.start + 1) % len(r.buf)
+		r.n--
+		dropped = true
+	}
+
+	return dropped
+}
+
+// samples returns a copy of kept samples, the oldest first.
+func (r *connRing) samples() []connSample {
+	samples := make([]connSample, r.n)
+	for i := range samples {
+		samples[i] = r.buf[(r.start+i)%len(r.buf)]
+	}
+
+	return samples
+}
+
+// connHistory keeps samples per relay.
+type connHistory map[string]*connRing
+
+// saturationHorizon limits how far saturation is predicted, a slope
+// close to zero would overflow the duration otherwise.
+const saturationHorizon = 30 * 24 * time.Hour
+
+// connTrend is how fast the number of connections grows
+// over short and long windows, in connections per hour.
+type connTrend struct {
+	short float64
+	long  float64
+	// saturation is when the relay reaches its -max-conn if the long
+	// trend continues, it is zero if it never does, does later than
+	// the horizon or there is no limit.
+	saturation time.Time
+}
+
+// memoryConnHistory is loaded from the -conn-history file once, or
+// starts empty without it, so trends are known in the daemon mode only.
+var memoryConnHistory connHistory
+
+func loadConnHistory(path string) (connHistory, error) {
+	history := connHistory{}
+	if len(path) == 0 {
+		return history, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	saved := map[string][]connSample{}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+
+	for endpoint, samples := range saved {
+		r := newConnRing()
+		for _, s := range samples {
+			r.push(s, 0)
+		}
+		history[endpoint] = r
+	}
+
+	return history, nil
+}
+
+// saveConnHistory replaces the history file atomically.
+func saveConnHistory(path string, history connHistory) error {
+	if len(path) == 0 {
+		return nil
+	}
+
+	saved := map[string][]connSample{}
+	for endpoint, r := range history {
+		saved[endpoint] = r.samples()
+	}
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// applyConnTrend appends current connection counts to the history,
+// drops samples older than the long window along with relays having
+// none left and computes trends. The file is written only if the
+// history has changed.
+func applyConnTrend(path string, results []*relayStats) error {
+	if memoryConnHistory == nil {
+		history, err := loadConnHistory(path)
+		if err != nil {
+			return err
+		}
+		memoryConnHistory = history
+	}
+
+	history := memoryConnHistory
+	spacing := trendLongFlag / connSamplesMax
+	changed := false
+	now := time.Now()
+
+	for _, stats := range results {
+		r, ok := history[stats.endpoint]
+		if !ok {
+			r = newConnRing()
+			history[stats.endpoint] = r
+		}
+
+		if r.push(connSample{Time: stats.time, Conn: stats.metrics.GetConnCurrent()}, spacing) {
+			changed = true
+		}
+	}
+
+	for endpoint, r := range history {
+		if r.dropBefore(now.Add(-trendLongFlag)) {
+			changed = true
+		}
+
+		if r.n == 0 {
+			delete(history, endpoint)
+		}
+	}
+
+	for _, stats := range results {
+		r, ok := history[stats.endpoint]
+		if !ok {
+			continue
+		}
+
+		samples := r.samples()
+		trend := &connTrend{
+			short: connSlope(samples, stats.time.Add(-trendShortFlag)),
+			long:  connSlope(samples, stats.time.Add(-trendLongFlag)),
+		}
+
+		maxConn, current := *stats.cluster.MaxConn, stats.metrics.GetConnCurrent()
+		if maxConn > current && trend.long > 0 {
+			hours := float64(maxConn-current) / trend.long
+			if hours <= saturationHorizon.Hours() {
+				trend.saturation = stats.time.Add(time.Duration(hours * float64(time.Hour)))
+			}
+		}
+
+		stats.trend = trend
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return saveConnHistory(path, history)
+}
+
+// connSlope is the least squares slope of samples taken since
+// the time, in connections per hour. It is zero if there
+// are not enough samples.
+func connSlope(samples []connSample, since time.Time) float64 {
+	var n, sumX, sumY, sumXY, sumXX float64
+	for _, s := range samples {
+		if s.Time.Before(since) {
+			continue
+		}
+
+		x := s.Time.Sub(since).Hours()
+		y := float64(s.Conn)
+		n++
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+
+	d := n*sumXX - sumX*sumX
+	if n < 2 || d == 0 {
+		return 0
+	}
+
+	return (n*sumXY - sumX*sumY) / d
+}
@@ -0,0 +1,119 @@
+package rvmon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+	"go.uber.org/zap"
+)
+
+const webhookTimeout = 10 * time.Second
+
+// dropAlert is the webhook payload.
+type dropAlert struct {
+	Source      string    `json:"source"`
+	Time        time.Time `json:"time"`
+	Count       int       `json:"count"`
+	Baseline    float64   `json:"baseline"`
+	DropPercent float64   `json:"drop_percent"`
+}
+
+// dropAlerter compares peer counts with the average of previous
+// polls and posts to the webhook when a count drops by more than
+// the threshold. It fires once per drop, the next alert for the
+// source is sent only after the count has recovered.
+type dropAlerter struct {
+	url       string
+	threshold float64
+	window    int
+
+	history  map[string][]int
+	alerting map[string]bool
+	client   *http.Client
+}
+
+func newDropAlerter(url string, threshold float64, window uint) *dropAlerter {
+	return &dropAlerter{
+		url:       url,
+		threshold: threshold,
+		window:    int(window),
+		history:   map[string][]int{},
+		alerting:  map[string]bool{},
+		client:    &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+func (d *dropAlerter) Write(results []*census.Census) error {
+	failed := 0
+	for _, c := range results {
+		count := c.Total()
+		history := d.history[c.Source]
+		d.history[c.Source] = append(history, count)
+		if len(d.history[c.Source]) > d.window {
+			d.history[c.Source] = d.history[c.Source][1:]
+		}
+
+		if len(history) == 0 {
+			continue
+		}
+
+		sum := 0
+		for _, v := range history {
+			sum += v
+		}
+
+		baseline := float64(sum) / float64(len(history))
+		if baseline == 0 {
+			continue
+		}
+
+		drop := 100 * (baseline - float64(count)) / baseline
+		if drop < d.threshold {
+			d.alerting[c.Source] = false
+			continue
+		}
+
+		if d.alerting[c.Source] {
+			continue
+		}
+
+		logger.Warn("peer count dropped", zap.String("source", c.Source), zap.Int("count", count), zap.Float64("baseline", baseline))
+		err := d.post(dropAlert{Source: c.Source, Time: c.Time, Count: count, Baseline: baseline, DropPercent: drop})
+		if err != nil {
+			logger.Warn("cannot send webhook", zap.Error(err))
+			failed++
+			continue
+		}
+
+		d.alerting[c.Source] = true
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d webhook alerts failed", failed)
+	}
+
+	return nil
+}
+
+func (d *dropAlerter) post(alert dropAlert) error {
+	b, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	resp, err := d.client.Post(d.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+
+	return nil
+}
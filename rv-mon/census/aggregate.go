@@ -0,0 +1,126 @@
+package census
+
+import (
+	"net"
+	"strings"
+
+	"github.com/mmcloughlin/geohash"
+	"github.com/sonm-io/core/proto"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/ipaddr"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/geo"
+	"go.uber.org/zap"
+)
+
+// Reasons for a server to be counted as unlocatable.
+const (
+	UnlocatableInvalid = "invalid"
+	UnlocatablePrivate = "private"
+	UnlocatableUnknown = "unknown"
+)
+
+var UnlocatableReasons = []string{UnlocatableInvalid, UnlocatablePrivate, UnlocatableUnknown}
+
+// ParsePeerIP parses both IPv4 and IPv6 addresses, the latter
+// may come in brackets and with a zone, e.g. "[fe80::1%eth0]".
+func ParsePeerIP(addr string) net.IP {
+	addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	if i := strings.IndexByte(addr, '%'); i >= 0 {
+		addr = addr[:i]
+	}
+
+	return net.ParseIP(addr)
+}
+
+// Aggregate counts servers of the rendezvous state per geohash of the
+// given precision. It does no network calls, so results depend only
+// on the state and the resolver. Servers that cannot be located are
+// left out of locations and peers and counted by the reason instead,
+// the number of geoip lookup failures is returned, every single one
+// is logged at debug level. Only servers matching the filter are
+// counted, unlocatable ones never match a non-empty filter.
+func Aggregate(source string, info *sonm.RendezvousState, resolver geo.Resolver, precision uint, filter *Filter, logger *zap.Logger) (*Census, int) {
+	c := &Census{
+		Source:       source,
+		Locations:    map[string]*Location{},
+		Unlocatable:  map[string]int{},
+		Connectivity: map[ConnKey]int{},
+		WalletSet:    map[string]bool{},
+	}
+
+	unlocated := 0
+	for id, state := range info.GetState() {
+		counted := false
+		for _, srv := range state.GetServers() {
+			key := ClassifyConnectivity(srv.GetPublicAddr(), srv.GetPrivateAddrs())
+			reason := ""
+			var geoLoc *geo.Location
+
+			ip := ParsePeerIP(srv.GetPublicAddr().GetAddr().GetAddr())
+			switch {
+			case ip == nil:
+				reason = UnlocatableInvalid
+			case ipaddr.IsPrivate(ip):
+				reason = UnlocatablePrivate
+			default:
+				var err error
+				geoLoc, err = resolver.Resolve(ip)
+				if err != nil {
+					logger.Debug("cannot find IP in geoip db", zap.String("source", source), zap.Stringer("ip", ip), zap.Error(err))
+					reason = UnlocatableUnknown
+					unlocated++
+				}
+			}
+
+			// there is no telling whether unlocatable servers match
+			if len(reason) > 0 && !filter.Empty() {
+				continue
+			}
+
+			if len(reason) == 0 && !filter.Match(geoLoc) {
+				continue
+			}
+
+			counted = true
+			c.Connectivity[key] += 1
+			if len(reason) > 0 {
+				c.Unlocatable[reason]++
+				continue
+			}
+
+			// peers from different cities may share the same coarse
+			// geohash, the location is named after the first one.
+			pointEncoded := geohash.EncodeWithPrecision(geoLoc.Lat, geoLoc.Lon, precision)
+			loc, ok := c.Locations[pointEncoded]
+			if !ok {
+				loc = &Location{
+					City:    geoLoc.City,
+					Country: geoLoc.Country,
+					Wallets: map[string]bool{},
+				}
+				c.Locations[pointEncoded] = loc
+			}
+
+			loc.Count += 1
+			loc.Wallets[PeerEth(id)] = true
+			c.Peers = append(c.Peers, Peer{
+				Eth:       PeerEth(id),
+				IP:        ip.String(),
+				City:      geoLoc.City,
+				Country:   geoLoc.Country,
+				Continent: geoLoc.Continent,
+				Lat:       geoLoc.Lat,
+				Lon:       geoLoc.Lon,
+				Geohash:   pointEncoded,
+			})
+		}
+
+		// a wallet is registered once per protocol,
+		// state keys are <proto>//<eth>
+		if counted {
+			c.WalletSet[PeerEth(id)] = true
+		}
+	}
+
+	c.WalletCount = len(c.WalletSet)
+	return c, unlocated
+}
@@ -0,0 +1,286 @@
+package census
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/mmcloughlin/geohash"
+	"github.com/sonm-io/core/proto"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/geo"
+	"go.uber.org/zap"
+)
+
+// fakeResolver locates addresses listed in the map, others are unknown.
+type fakeResolver map[string]*geo.Location
+
+func (r fakeResolver) Resolve(ip net.IP) (*geo.Location, error) {
+	loc, ok := r[ip.String()]
+	if !ok {
+		return nil, fmt.Errorf("%s is not in the database", ip)
+	}
+
+	return loc, nil
+}
+
+var (
+	berlin  = &geo.Location{City: "Berlin", Country: "Germany", Continent: "Europe", CountryCode: "DE", ContinentCode: "EU", Lat: 52.52, Lon: 13.405}
+	potsdam = &geo.Location{City: "Potsdam", Country: "Germany", Continent: "Europe", CountryCode: "DE", ContinentCode: "EU", Lat: 52.39, Lon: 13.065}
+	tokyo   = &geo.Location{City: "Tokyo", Country: "Japan", Continent: "Asia", CountryCode: "JP", ContinentCode: "AS", Lat: 35.68, Lon: 139.69}
+
+	testResolver = fakeResolver{
+		"1.1.1.1": berlin,
+		"1.1.1.2": berlin,
+		"2.2.2.2": potsdam,
+		"3.3.3.3": tokyo,
+	}
+)
+
+const (
+	walletA = "0x8125721C2413d99a33E351e1F6Bb4e56b6b633FD"
+	walletB = "0x5b7D6516Fad04e10DB726933bCD75447fd7B4b17"
+	walletC = "0x1B13D2A6B79AAF1bD7C5Eab6CC7F1BC4C6d8C8Bf"
+)
+
+func server(ip string) *sonm.RendezvousData {
+	return &sonm.RendezvousData{
+		PublicAddr: &sonm.Addr{Protocol: "tcp", Addr: &sonm.SocketAddr{Addr: ip, Port: 15010}},
+	}
+}
+
+// state builds the rendezvous state of servers by the state key.
+func state(servers map[string][]string) *sonm.RendezvousState {
+	info := &sonm.RendezvousState{State: map[string]*sonm.RendezvousMeeting{}}
+	for id, ips := range servers {
+		meeting := &sonm.RendezvousMeeting{}
+		for _, ip := range ips {
+			meeting.Servers = append(meeting.Servers, server(ip))
+		}
+
+		info.State[id] = meeting
+	}
+
+	return info
+}
+
+func at(loc *geo.Location, precision uint) string {
+	return geohash.EncodeWithPrecision(loc.Lat, loc.Lon, precision)
+}
+
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     map[string][]string
+		precision uint
+		filter    *Filter
+		// locations are peer counts by the geohash.
+		locations   map[string]int
+		unlocatable map[string]int
+		wallets     int
+		unlocated   int
+	}{
+		{
+			name:        "empty state",
+			state:       map[string][]string{},
+			precision:   5,
+			locations:   map[string]int{},
+			unlocatable: map[string]int{},
+		},
+		{
+			name: "peers of the same city share the location",
+			state: map[string][]string{
+				"tcp//" + walletA: {"1.1.1.1"},
+				"tcp//" + walletB: {"1.1.1.2"},
+				"tcp//" + walletC: {"3.3.3.3"},
+			},
+			precision:   5,
+			locations:   map[string]int{at(berlin, 5): 2, at(tokyo, 5): 1},
+			unlocatable: map[string]int{},
+			wallets:     3,
+		},
+		{
+			name: "coarse precision merges nearby cities",
+			state: map[string][]string{
+				"tcp//" + walletA: {"1.1.1.1"},
+				"tcp//" + walletB: {"2.2.2.2"},
+			},
+			precision:   2,
+			locations:   map[string]int{at(berlin, 2): 2},
+			unlocatable: map[string]int{},
+			wallets:     2,
+		},
+		{
+			name: "fine precision separates nearby cities",
+			state: map[string][]string{
+				"tcp//" + walletA: {"1.1.1.1"},
+				"tcp//" + walletB: {"2.2.2.2"},
+			},
+			precision:   5,
+			locations:   map[string]int{at(berlin, 5): 1, at(potsdam, 5): 1},
+			unlocatable: map[string]int{},
+			wallets:     2,
+		},
+		{
+			name: "invalid addresses are unlocatable",
+			state: map[string][]string{
+				"tcp//" + walletA: {"not-an-ip", ""},
+			},
+			precision:   5,
+			locations:   map[string]int{},
+			unlocatable: map[string]int{UnlocatableInvalid: 2},
+			wallets:     1,
+		},
+		{
+			name: "private addresses are unlocatable",
+			state: map[string][]string{
+				"tcp//" + walletA: {"192.168.1.10", "10.0.0.1", "127.0.0.1"},
+				"tcp//" + walletB: {"[fe80::1%eth0]", "fd00::1"},
+			},
+			precision:   5,
+			locations:   map[string]int{},
+			unlocatable: map[string]int{UnlocatablePrivate: 5},
+			wallets:     2,
+		},
+		{
+			name: "addresses missing in the database are unknown",
+			state: map[string][]string{
+				"tcp//" + walletA: {"1.1.1.1", "4.4.4.4"},
+				"tcp//" + walletB: {"5.5.5.5"},
+			},
+			precision:   5,
+			locations:   map[string]int{at(berlin, 5): 1},
+			unlocatable: map[string]int{UnlocatableUnknown: 2},
+			wallets:     2,
+			unlocated:   2,
+		},
+		{
+			name: "a wallet registered over several protocols is counted once",
+			state: map[string][]string{
+				"tcp//" + walletA: {"1.1.1.1"},
+				"udp//" + walletA: {"1.1.1.1"},
+			},
+			precision:   5,
+			locations:   map[string]int{at(berlin, 5): 2},
+			unlocatable: map[string]int{},
+			wallets:     1,
+		},
+		{
+			name: "filter by country",
+			state: map[string][]string{
+				"tcp//" + walletA: {"1.1.1.1"},
+				"tcp//" + walletB: {"3.3.3.3"},
+				"tcp//" + walletC: {"192.168.1.10", "not-an-ip"},
+			},
+			precision:   5,
+			filter:      NewFilter("DE", ""),
+			locations:   map[string]int{at(berlin, 5): 1},
+			unlocatable: map[string]int{},
+			wallets:     1,
+		},
+		{
+			name: "filter by continent name",
+			state: map[string][]string{
+				"tcp//" + walletA: {"1.1.1.1"},
+				"tcp//" + walletB: {"3.3.3.3"},
+			},
+			precision:   5,
+			filter:      NewFilter("", "asia"),
+			locations:   map[string]int{at(tokyo, 5): 1},
+			unlocatable: map[string]int{},
+			wallets:     1,
+		},
+		{
+			name: "unknown addresses are looked up, but never match the filter",
+			state: map[string][]string{
+				"tcp//" + walletA: {"4.4.4.4"},
+			},
+			precision:   5,
+			filter:      NewFilter("DE", ""),
+			locations:   map[string]int{},
+			unlocatable: map[string]int{},
+			unlocated:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, unlocated := Aggregate("rv", state(tt.state), testResolver, tt.precision, tt.filter, zap.NewNop())
+
+			locations := map[string]int{}
+			for hash, loc := range c.Locations {
+				if len(hash) != int(tt.precision) {
+					t.Errorf("geohash %q has precision %d, want %d", hash, len(hash), tt.precision)
+				}
+
+				locations[hash] = loc.Count
+			}
+
+			if !reflect.DeepEqual(locations, tt.locations) {
+				t.Errorf("locations = %v, want %v", locations, tt.locations)
+			}
+
+			if !reflect.DeepEqual(c.Unlocatable, tt.unlocatable) {
+				t.Errorf("unlocatable = %v, want %v", c.Unlocatable, tt.unlocatable)
+			}
+
+			if c.WalletCount != tt.wallets {
+				t.Errorf("walletCount = %d, want %d", c.WalletCount, tt.wallets)
+			}
+
+			if unlocated != tt.unlocated {
+				t.Errorf("unlocated = %d, want %d", unlocated, tt.unlocated)
+			}
+
+			located := 0
+			for _, n := range tt.locations {
+				located += n
+			}
+
+			if len(c.Peers) != located {
+				t.Errorf("got %d peers, want %d", len(c.Peers), located)
+			}
+		})
+	}
+}
+
+// TestAggregateUnlocatableReasons checks every reason listed
+// for the console output is the one aggregate counts.
+func TestAggregateUnlocatableReasons(t *testing.T) {
+	addrs := map[string]string{
+		UnlocatableInvalid: "not-an-ip",
+		UnlocatablePrivate: "172.16.0.1",
+		UnlocatableUnknown: "4.4.4.4",
+	}
+
+	for _, reason := range UnlocatableReasons {
+		addr, ok := addrs[reason]
+		if !ok {
+			t.Errorf("no address for the %q reason", reason)
+			continue
+		}
+
+		c, _ := Aggregate("rv", state(map[string][]string{"tcp//" + walletA: {addr}}), testResolver, 5, nil, zap.NewNop())
+		want := map[string]int{reason: 1}
+		if !reflect.DeepEqual(c.Unlocatable, want) {
+			t.Errorf("%s: unlocatable = %v, want %v", addr, c.Unlocatable, want)
+		}
+	}
+}
+
+func TestAggregatePeerRecords(t *testing.T) {
+	c, _ := Aggregate("rv", state(map[string][]string{"tcp//" + walletA: {"3.3.3.3"}}), testResolver, 7, nil, zap.NewNop())
+	if len(c.Peers) != 1 {
+		t.Fatalf("got %d peers, want 1", len(c.Peers))
+	}
+
+	p := c.Peers[0]
+	if p.Eth != PeerEth(walletA) || p.IP != "3.3.3.3" || p.City != "Tokyo" || p.Geohash != at(tokyo, 7) {
+		t.Errorf("unexpected peer %+v", p)
+	}
+
+	loc := c.Locations[at(tokyo, 7)]
+	if loc == nil || loc.City != "Tokyo" || !loc.Wallets[PeerEth(walletA)] {
+		t.Errorf("unexpected location %+v", loc)
+	}
+}
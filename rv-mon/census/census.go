@@ -0,0 +1,223 @@
+// Package census counts servers registered on rendezvous servers
+// by location, connectivity and wallet. It does no network calls,
+// geoip lookups are done by the geo.Resolver passed in.
+package census
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sonm-io/core/proto"
+)
+
+// Census is a result of a single rendezvous server poll.
+type Census struct {
+	Source string
+	// Merged is set for the network-wide census, which
+	// has no latency and connectivity data.
+	Merged      bool
+	Replication *Replication
+	// Time is when the poll has started, it is the same
+	// for all servers queried during the cycle.
+	Time time.Time
+	// Locations are keyed by geohash.
+	Locations map[string]*Location
+	// Unlocatable counts servers which cannot be put on the map
+	// by the reason, merged census does not have them.
+	Unlocatable map[string]int
+	// WalletCount is the number of distinct wallets, a wallet
+	// may register several servers, all counted in total.
+	WalletCount int
+	// WalletSet has wallets of the state, located or not.
+	WalletSet    map[string]bool
+	Peers        []Peer
+	Connectivity map[ConnKey]int
+	// DialTime and InfoTime measure rendezvous latency, the
+	// first one does not change while the connection is alive.
+	DialTime time.Duration
+	InfoTime time.Duration
+	// Probe is set when -probe is enabled.
+	Probe *Probe
+	// Raw is the Info response, kept only for -dump.
+	Raw *sonm.RendezvousState
+	// Churn is set only when the state file is used and
+	// the server was seen during the previous poll.
+	Churn *Churn
+}
+
+// Location is a bucket of peers sharing the same geohash.
+type Location struct {
+	City    string
+	Country string
+	Count   int
+	Wallets map[string]bool
+}
+
+// Name is the city name, the country one is used
+// when the city is unknown.
+func (l *Location) Name() string {
+	if len(l.City) > 0 {
+		return l.City
+	}
+
+	return l.Country
+}
+
+// Peer is a located server registered on the rendezvous.
+type Peer struct {
+	Eth       string  `json:"eth"`
+	IP        string  `json:"ip"`
+	City      string  `json:"city"`
+	Country   string  `json:"country"`
+	Continent string  `json:"continent"`
+	Lat       float64 `json:"lat"`
+	Lon       float64 `json:"lon"`
+	Geohash   string  `json:"geohash"`
+	// PTR, ASN and ASOrg are filled by the enricher.
+	PTR   string `json:"ptr,omitempty"`
+	ASN   uint   `json:"asn,omitempty"`
+	ASOrg string `json:"as_org,omitempty"`
+	// Ghost is set by the DWH check for peers
+	// having no profile, orders or deals.
+	Ghost bool `json:"ghost,omitempty"`
+	// Hardware is loaded from the DWH with -dwh-hardware.
+	Hardware
+}
+
+// Total is the number of servers on the rendezvous
+// including the ones which cannot be located.
+func (c *Census) Total() int {
+	total := 0
+	for _, loc := range c.Locations {
+		total += loc.Count
+	}
+
+	for _, n := range c.Unlocatable {
+		total += n
+	}
+
+	return total
+}
+
+// PeersAt returns peers located at the geohash.
+func (c *Census) PeersAt(hash string) []Peer {
+	var peers []Peer
+	for _, p := range c.Peers {
+		if p.Geohash == hash {
+			peers = append(peers, p)
+		}
+	}
+
+	return peers
+}
+
+// WalletList returns sorted addresses of wallets found at the location.
+func (l *Location) WalletList() []string {
+	wallets := make([]string, 0, len(l.Wallets))
+	for eth := range l.Wallets {
+		wallets = append(wallets, eth)
+	}
+
+	sort.Strings(wallets)
+	return wallets
+}
+
+// PeerEth extracts the wallet address from the rendezvous state key,
+// which looks like "<protocol>//<eth>".
+func PeerEth(id string) string {
+	parts := strings.Split(id, "//")
+	return common.HexToAddress(parts[len(parts)-1]).Hex()
+}
+
+// GhostWallets returns sorted addresses of ghost peers.
+func (c *Census) GhostWallets() []string {
+	seen := map[string]bool{}
+	var wallets []string
+	for _, p := range c.Peers {
+		if p.Ghost && !seen[p.Eth] {
+			seen[p.Eth] = true
+			wallets = append(wallets, p.Eth)
+		}
+	}
+
+	sort.Strings(wallets)
+	return wallets
+}
+
+// Hardware is the capacity a supplier has sold in accepted deals,
+// as measured by benchmarks.
+type Hardware struct {
+	CPUCores    uint64 `json:"cpu_cores,omitempty"`
+	GPUCount    uint64 `json:"gpu_count,omitempty"`
+	RAMSize     uint64 `json:"ram_size,omitempty"`
+	EthHashrate uint64 `json:"eth_hashrate,omitempty"`
+}
+
+func (h *Hardware) Add(other Hardware) {
+	h.CPUCores += other.CPUCores
+	h.GPUCount += other.GPUCount
+	h.RAMSize += other.RAMSize
+	h.EthHashrate += other.EthHashrate
+}
+
+// Probe tells how the rendezvous resolves sampled peers,
+// as a client connecting to them would see it.
+type Probe struct {
+	Sampled    int
+	Resolved   int
+	AvgLatency time.Duration
+	MaxLatency time.Duration
+}
+
+func (p *Probe) SuccessRatio() float64 {
+	if p.Sampled == 0 {
+		return 0
+	}
+
+	return float64(p.Resolved) / float64(p.Sampled)
+}
+
+// Churn is the difference between wallets found on the
+// rendezvous server during the current and the previous polls.
+type Churn struct {
+	Joined []string
+	Left   []string
+}
+
+// Wallets returns sorted wallets of the state, so peers missing
+// in the geoip database are neither lost nor counted as gone.
+func (c *Census) Wallets() []string {
+	wallets := make([]string, 0, len(c.WalletSet))
+	for eth := range c.WalletSet {
+		wallets = append(wallets, eth)
+	}
+
+	sort.Strings(wallets)
+	return wallets
+}
+
+func DiffWallets(previous, current []string) *Churn {
+	prev := map[string]bool{}
+	for _, eth := range previous {
+		prev[eth] = true
+	}
+
+	ch := &Churn{Joined: []string{}, Left: []string{}}
+	for _, eth := range current {
+		if prev[eth] {
+			delete(prev, eth)
+			continue
+		}
+
+		ch.Joined = append(ch.Joined, eth)
+	}
+
+	for eth := range prev {
+		ch.Left = append(ch.Left, eth)
+	}
+
+	sort.Strings(ch.Left)
+	return ch
+}
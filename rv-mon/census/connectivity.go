@@ -0,0 +1,64 @@
+package census
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/sonm-io/core/proto"
+)
+
+// ConnKey describes how the server can be reached.
+type ConnKey struct {
+	// NAT is "direct" when the server listens on its public
+	// address and "nat" when it needs hole punching or a relay.
+	NAT string
+	// Protocol is the transport with the IP family, e.g. "tcp4".
+	Protocol string
+}
+
+// ClassifyConnectivity compares server's public address with the
+// private ones it has announced, equal addresses mean no NAT in between.
+func ClassifyConnectivity(public *sonm.Addr, private []*sonm.Addr) ConnKey {
+	key := ConnKey{NAT: "nat", Protocol: strings.ToLower(public.GetProtocol())}
+	if len(key.Protocol) == 0 {
+		key.Protocol = "unknown"
+	}
+
+	ip := ParsePeerIP(public.GetAddr().GetAddr())
+	switch {
+	case ip == nil:
+	case ip.To4() != nil:
+		key.Protocol += "4"
+	default:
+		key.Protocol += "6"
+	}
+
+	for _, addr := range private {
+		privateIP := ParsePeerIP(addr.GetAddr().GetAddr())
+		if ip != nil && ip.Equal(privateIP) {
+			key.NAT = "direct"
+			break
+		}
+	}
+
+	return key
+}
+
+// ConnectivityKeys returns keys of the census' connectivity
+// counters ordered by the NAT kind and the protocol.
+func (c *Census) ConnectivityKeys() []ConnKey {
+	keys := make([]ConnKey, 0, len(c.Connectivity))
+	for key := range c.Connectivity {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].NAT != keys[j].NAT {
+			return keys[i].NAT < keys[j].NAT
+		}
+
+		return keys[i].Protocol < keys[j].Protocol
+	})
+
+	return keys
+}
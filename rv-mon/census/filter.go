@@ -0,0 +1,56 @@
+package census
+
+import (
+	"strings"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/geo"
+)
+
+// Filter scopes a run to some countries and continents, both
+// English names and codes are accepted, e.g. "Germany" or "DE", "EU".
+type Filter struct {
+	countries  map[string]bool
+	continents map[string]bool
+}
+
+func NewFilter(countries, continents string) *Filter {
+	return &Filter{
+		countries:  parseNameSet(countries),
+		continents: parseNameSet(continents),
+	}
+}
+
+// parseNameSet splits a comma-separated list into a set of lowercase names.
+func parseNameSet(list string) map[string]bool {
+	set := map[string]bool{}
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			set[strings.ToLower(v)] = true
+		}
+	}
+
+	return set
+}
+
+// Empty reports whether the filter matches everything.
+func (f *Filter) Empty() bool {
+	return f == nil || len(f.countries) == 0 && len(f.continents) == 0
+}
+
+// Match reports whether the location belongs to any of the listed
+// countries and any of the listed continents, an empty list matches all.
+func (f *Filter) Match(loc *geo.Location) bool {
+	if f.Empty() {
+		return true
+	}
+
+	if len(f.countries) > 0 && !f.countries[strings.ToLower(loc.Country)] && !f.countries[strings.ToLower(loc.CountryCode)] {
+		return false
+	}
+
+	if len(f.continents) > 0 && !f.continents[strings.ToLower(loc.Continent)] && !f.continents[strings.ToLower(loc.ContinentCode)] {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,76 @@
+package census
+
+// MergedSource tags the network-wide view built from all servers.
+const MergedSource = "network"
+
+// Replication tells how consistently wallets are
+// registered across rendezvous servers.
+type Replication struct {
+	// Unique is the number of distinct wallets on all servers.
+	Unique int
+	// Everywhere is the number of wallets registered on every server,
+	// the rest of unique ones are visible only on some of them.
+	Everywhere int
+}
+
+func (r *Replication) Partial() int {
+	return r.Unique - r.Everywhere
+}
+
+// Merge builds the network-wide census, a server endpoint
+// registered on several rendezvous servers is counted once.
+func Merge(results []*Census) *Census {
+	merged := &Census{
+		Source:       MergedSource,
+		Merged:       true,
+		Locations:    map[string]*Location{},
+		Connectivity: map[ConnKey]int{},
+		Replication:  &Replication{},
+	}
+
+	seenOn := map[string]int{}
+	seenEndpoints := map[string]bool{}
+	for _, c := range results {
+		if merged.Time.IsZero() {
+			merged.Time = c.Time
+		}
+
+		for _, eth := range c.Wallets() {
+			seenOn[eth]++
+		}
+
+		for _, p := range c.Peers {
+			endpoint := p.Eth + "@" + p.IP
+			if seenEndpoints[endpoint] {
+				continue
+			}
+
+			seenEndpoints[endpoint] = true
+			merged.Peers = append(merged.Peers, p)
+
+			src := c.Locations[p.Geohash]
+			loc, ok := merged.Locations[p.Geohash]
+			if !ok {
+				loc = &Location{City: src.City, Country: src.Country, Wallets: map[string]bool{}}
+				merged.Locations[p.Geohash] = loc
+			}
+
+			loc.Count++
+			loc.Wallets[p.Eth] = true
+		}
+	}
+
+	merged.Replication.Unique = len(seenOn)
+	merged.WalletCount = len(seenOn)
+	merged.WalletSet = map[string]bool{}
+	for eth := range seenOn {
+		merged.WalletSet[eth] = true
+	}
+	for _, n := range seenOn {
+		if n == len(results) {
+			merged.Replication.Everywhere++
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,68 @@
+package census
+
+import "sort"
+
+// Levels of coarse-grained aggregation enabled by -aggregate.
+var AggregationLevels = map[string]bool{
+	"city":      true,
+	"country":   true,
+	"continent": true,
+}
+
+// Region is a number of located peers sharing the city,
+// the country or the continent, depending on the level.
+type Region struct {
+	Name  string
+	Count int
+	// Hardware is summed up over peers of the region,
+	// it is known only with -dwh-hardware.
+	Hardware Hardware
+}
+
+// Regions counts located peers at the level, sorted by the count.
+// Cities are named along with the country, since names are not unique.
+func (c *Census) Regions(level string) []Region {
+	byName := map[string]*Region{}
+	for _, p := range c.Peers {
+		var name string
+		switch level {
+		case "city":
+			name = p.City
+			if len(name) > 0 && len(p.Country) > 0 {
+				name += ", " + p.Country
+			}
+		case "country":
+			name = p.Country
+		case "continent":
+			name = p.Continent
+		}
+
+		if len(name) == 0 {
+			name = "unknown"
+		}
+
+		r, ok := byName[name]
+		if !ok {
+			r = &Region{Name: name}
+			byName[name] = r
+		}
+
+		r.Count++
+		r.Hardware.Add(p.Hardware)
+	}
+
+	regions := make([]Region, 0, len(byName))
+	for _, r := range byName {
+		regions = append(regions, *r)
+	}
+
+	sort.Slice(regions, func(i, j int) bool {
+		if regions[i].Count != regions[j].Count {
+			return regions[i].Count > regions[j].Count
+		}
+
+		return regions[i].Name < regions[j].Name
+	})
+
+	return regions
+}
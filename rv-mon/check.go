@@ -0,0 +1,67 @@
+package rvmon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/geo"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/output"
+)
+
+// Nagios plugin exit codes.
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+var checkStatusNames = map[int]string{
+	checkOK:       "OK",
+	checkWarning:  "WARNING",
+	checkCritical: "CRITICAL",
+	checkUnknown:  "UNKNOWN",
+}
+
+// runCheck polls rendezvous servers once, prints a single status line
+// with perfdata and returns the exit code. A server having fewer peers
+// than -warn or -crit makes the status WARNING or CRITICAL respectively,
+// an unreachable server is always CRITICAL.
+func runCheck(ctx context.Context, targets []*target, resolver geo.Resolver) int {
+	var results []*census.Census
+	err := poll(ctx, targets, resolver, []output.Sink{output.SinkFunc(func(r []*census.Census) error {
+		results = r
+		return nil
+	})})
+
+	status := checkOK
+	var details, perfdata []string
+	for _, c := range results {
+		total := c.Total()
+
+		switch {
+		case total < int(critFlag):
+			status = checkCritical
+		case total < int(warnFlag) && status < checkWarning:
+			status = checkWarning
+		}
+
+		details = append(details, fmt.Sprintf("%s: %d peers", c.Source, total))
+		perfdata = append(perfdata, fmt.Sprintf("'%s'=%d;%d;%d;0", c.Source, total, warnFlag, critFlag))
+	}
+
+	if err != nil {
+		status = checkCritical
+		details = append(details, err.Error())
+	}
+
+	if len(results) == 0 && err == nil {
+		status = checkUnknown
+		details = append(details, "no data")
+	}
+
+	fmt.Printf("RV %s - %s | %s\n", checkStatusNames[status], strings.Join(details, ", "), strings.Join(perfdata, " "))
+	return status
+}
@@ -0,0 +1,65 @@
+package rvmon
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+)
+
+// peerState keeps sorted wallets seen on every rendezvous server.
+type peerState map[string][]string
+
+func loadPeerState(path string) (peerState, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return peerState{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := peerState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// savePeerState replaces the state file atomically.
+func savePeerState(path string, state peerState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// applyChurn compares results with the ones stored in the state file
+// and remembers the current wallets. Servers seen for the first time
+// get no churn, otherwise every their peer would be reported as joined.
+func applyChurn(path string, results []*census.Census) error {
+	state, err := loadPeerState(path)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range results {
+		current := c.Wallets()
+		if previous, ok := state[c.Source]; ok {
+			c.Churn = census.DiffWallets(previous, current)
+		}
+
+		state[c.Source] = current
+	}
+
+	return savePeerState(path, state)
+}
@@ -0,0 +1,28 @@
+package rvmon
+
+import (
+	"os"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/output"
+)
+
+// consoleSinks maps -format values to sinks configured by flags.
+func consoleSinks() map[string]output.Sink {
+	opts := output.Options{
+		Details:   detailsFlag,
+		Enriched:  peerEnricher != nil,
+		Sort:      sortFlag,
+		Aggregate: aggregateFlag,
+		Hardware:  dwhHardwareFlag,
+		Ghosts:    ghostChecker != nil,
+	}
+
+	return map[string]output.Sink{
+		"text": output.Text(os.Stdout, opts),
+		"json": output.JSON(os.Stdout, opts),
+		"csv":  output.CSV(os.Stdout),
+		// parquet files are written to -parquet-dir rather than stdout
+		"parquet": output.SinkFunc(writeParquet),
+		"public":  output.Public(os.Stdout, publicPrecisionFlag),
+	}
+}
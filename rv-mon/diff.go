@@ -0,0 +1,75 @@
+package rvmon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+)
+
+// discrepancy is the symmetric difference of wallets registered
+// on two rendezvous servers, taken from their whole state whether
+// peers are located or not.
+type discrepancy struct {
+	A      string   `json:"a"`
+	B      string   `json:"b"`
+	Common int      `json:"common"`
+	OnlyA  []string `json:"only_a"`
+	OnlyB  []string `json:"only_b"`
+}
+
+// findDiscrepancy compares two servers queried with -diff, nil is
+// returned if the mode is off or any of the servers has failed.
+func findDiscrepancy(results []*census.Census) *discrepancy {
+	if !diffFlag {
+		return nil
+	}
+
+	var servers []*census.Census
+	for _, c := range results {
+		if !c.Merged {
+			servers = append(servers, c)
+		}
+	}
+
+	if len(servers) != 2 {
+		return nil
+	}
+
+	// the same diff as for the churn, with the first
+	// server playing the previous poll's role
+	a, b := servers[0], servers[1]
+	walletsA := a.Wallets()
+	ch := census.DiffWallets(walletsA, b.Wallets())
+	return &discrepancy{
+		A:      a.Source,
+		B:      b.Source,
+		Common: len(walletsA) - len(ch.Left),
+		OnlyA:  ch.Left,
+		OnlyB:  ch.Joined,
+	}
+}
+
+// writeDiff prints the discrepancy instead of
+// the census when no other output is enabled.
+func writeDiff(results []*census.Census) error {
+	d := findDiscrepancy(results)
+	if d == nil {
+		return fmt.Errorf("cannot compare servers, both of them must respond")
+	}
+
+	if formatFlag == "json" {
+		return json.NewEncoder(os.Stdout).Encode(d)
+	}
+
+	fmt.Printf("common: %d    only on %s: %d    only on %s: %d\n", d.Common, d.A, len(d.OnlyA), d.B, len(d.OnlyB))
+	for _, eth := range d.OnlyA {
+		fmt.Printf("  < %s\n", eth)
+	}
+	for _, eth := range d.OnlyB {
+		fmt.Printf("  > %s\n", eth)
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package rvmon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sonm-io/core/proto"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+)
+
+// snapshot is the raw rendezvous state as returned by Info,
+// written by -dump for debugging.
+type snapshot struct {
+	Time    time.Time                        `json:"time"`
+	Servers map[string]*sonm.RendezvousState `json:"servers"`
+}
+
+// dumpPath inserts the poll time before the extension,
+// e.g. "/tmp/rv.json" becomes "/tmp/rv-20190101T000000Z.json".
+func dumpPath(path string, t time.Time) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + t.UTC().Format("20060102T150405Z") + ext
+}
+
+// writeDump saves states of all servers queried during
+// the poll to a new file, keyed by the server endpoint.
+func writeDump(path string, results []*census.Census) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	s := snapshot{Time: results[0].Time, Servers: map[string]*sonm.RendezvousState{}}
+	for _, c := range results {
+		if c.Raw != nil {
+			s.Servers[c.Source] = c.Raw
+		}
+	}
+
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode snapshot: %v", err)
+	}
+
+	return ioutil.WriteFile(dumpPath(path, s.Time), b, 0644)
+}
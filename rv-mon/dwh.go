@@ -0,0 +1,115 @@
+package rvmon
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sonm-io/core/proto"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sonmclient"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ghostChecker is nil unless -dwh is set.
+var ghostChecker *dwhChecker
+
+// dwhChecker looks for "ghost" peers, the ones connected to the
+// rendezvous without a profile, orders or deals on the DWH. Results
+// are cached, economic activity does not change every poll.
+type dwhChecker struct {
+	dwh sonm.DWHClient
+	ttl time.Duration
+
+	mu      sync.Mutex
+	cache   map[string]dwhPresence
+	hwCache map[string]dwhHardware
+}
+
+type dwhPresence struct {
+	active  bool
+	checked time.Time
+}
+
+func newDWHChecker(ctx context.Context, dwhAddr string, id *sonmclient.Identity, ttl time.Duration) (*dwhChecker, error) {
+	conn, err := id.Dial(ctx, dwhAddr, clientOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	return &dwhChecker{
+		dwh:     sonm.NewDWHClient(conn.ClientConn),
+		ttl:     ttl,
+		cache:   map[string]dwhPresence{},
+		hwCache: map[string]dwhHardware{},
+	}, nil
+}
+
+// mark sets the Ghost flag of peers absent from the DWH, peers
+// which cannot be checked because of DWH errors are left as is.
+func (d *dwhChecker) mark(ctx context.Context, peers []census.Peer) {
+	failed := 0
+	for i := range peers {
+		active, err := d.isActive(ctx, peers[i].Eth)
+		if err != nil {
+			logger.Debug("cannot check peer on DWH", zap.String("eth", peers[i].Eth), zap.Error(err))
+			failed++
+			continue
+		}
+
+		peers[i].Ghost = !active
+	}
+
+	if failed > 0 {
+		logger.Warn("cannot check some peers on DWH", zap.Int("count", failed))
+	}
+}
+
+func (d *dwhChecker) isActive(ctx context.Context, eth string) (bool, error) {
+	d.mu.Lock()
+	p, ok := d.cache[eth]
+	d.mu.Unlock()
+	if ok && time.Since(p.checked) < d.ttl {
+		return p.active, nil
+	}
+
+	active, err := d.lookup(ctx, common.HexToAddress(eth))
+	if err != nil {
+		return false, err
+	}
+
+	d.mu.Lock()
+	d.cache[eth] = dwhPresence{active: active, checked: time.Now()}
+	d.mu.Unlock()
+
+	return active, nil
+}
+
+// lookup checks the profile first, then any order and deal of the peer.
+func (d *dwhChecker) lookup(ctx context.Context, addr common.Address) (bool, error) {
+	_, err := d.dwh.GetProfileInfo(ctx, &sonm.EthID{Id: sonm.NewEthAddress(addr)})
+	if err == nil {
+		return true, nil
+	}
+	if status.Code(err) != codes.NotFound {
+		return false, err
+	}
+
+	orders, err := d.dwh.GetOrders(ctx, &sonm.OrdersRequest{AuthorID: sonm.NewEthAddress(addr), Limit: 1})
+	if err != nil {
+		return false, err
+	}
+	if len(orders.GetOrders()) > 0 {
+		return true, nil
+	}
+
+	deals, err := d.dwh.GetDeals(ctx, &sonm.DealsRequest{SupplierID: sonm.NewEthAddress(addr), Limit: 1})
+	if err != nil {
+		return false, err
+	}
+
+	return len(deals.GetDeals()) > 0, nil
+}
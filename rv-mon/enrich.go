@@ -0,0 +1,109 @@
+package rvmon
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/geoip"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+)
+
+// enrichWorkers limits concurrent reverse DNS lookups.
+const enrichWorkers = 16
+
+// enricher adds reverse DNS and ASN data to located peers. PTR
+// records are cached for the process lifetime, so the daemon
+// does not resolve the same addresses on every poll.
+type enricher struct {
+	asn  *geoip.DB
+	rdns bool
+
+	mu   sync.Mutex
+	ptrs map[string]string
+}
+
+// peerEnricher is nil unless -rdns or -asn-db is set.
+var peerEnricher *enricher
+
+func newEnricher(asnPath string, rdns bool) (*enricher, error) {
+	e := &enricher{rdns: rdns, ptrs: map[string]string{}}
+	if len(asnPath) > 0 {
+		db, err := geoip.Open(asnPath)
+		if err != nil {
+			return nil, err
+		}
+
+		e.asn = db
+	}
+
+	return e, nil
+}
+
+func (e *enricher) Close() error {
+	if e.asn != nil {
+		return e.asn.Close()
+	}
+
+	return nil
+}
+
+func (e *enricher) enrich(ctx context.Context, peers []census.Peer) {
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < enrichWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				e.enrichPeer(ctx, &peers[i])
+			}
+		}()
+	}
+
+	for i := range peers {
+		jobs <- i
+	}
+
+	close(jobs)
+	wg.Wait()
+}
+
+func (e *enricher) enrichPeer(ctx context.Context, p *census.Peer) {
+	ip := net.ParseIP(p.IP)
+	if e.asn != nil {
+		if rec, err := e.asn.ASN(ip); err == nil {
+			p.ASN = rec.AutonomousSystemNumber
+			p.ASOrg = rec.AutonomousSystemOrganization
+		}
+	}
+
+	if e.rdns {
+		p.PTR = e.lookupPTR(ctx, p.IP)
+	}
+}
+
+// lookupPTR returns the first PTR record without the trailing dot,
+// failed lookups are cached as empty names too.
+func (e *enricher) lookupPTR(ctx context.Context, ip string) string {
+	e.mu.Lock()
+	ptr, ok := e.ptrs[ip]
+	e.mu.Unlock()
+	if ok {
+		return ptr
+	}
+
+	names, err := net.DefaultResolver.LookupAddr(ctx, ip)
+	if err == nil && len(names) > 0 {
+		ptr = strings.TrimSuffix(names[0], ".")
+	}
+
+	if ctx.Err() == nil {
+		e.mu.Lock()
+		e.ptrs[ip] = ptr
+		e.mu.Unlock()
+	}
+
+	return ptr
+}
@@ -0,0 +1,6 @@
+package rvmon
+
+import "github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+
+// peerFilter is empty unless -country or -continent is set.
+var peerFilter *census.Filter
@@ -0,0 +1,71 @@
+// Package geo locates IP addresses of peers.
+package geo
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/geoip"
+)
+
+// Location is where an IP address is registered,
+// names are in English and may be empty.
+type Location struct {
+	City      string
+	Country   string
+	Continent string
+	// CountryCode and ContinentCode are ISO 3166-1 and
+	// two-letter continent codes, e.g. "DE" and "EU".
+	CountryCode   string
+	ContinentCode string
+	Lat           float64
+	Lon           float64
+}
+
+// Resolver locates IP addresses, it is an interface so
+// aggregation can be checked against made up locations.
+type Resolver interface {
+	Resolve(ip net.IP) (*Location, error)
+}
+
+// MaxMind resolves addresses using a GeoIP2 or GeoLite2 city database.
+type MaxMind struct {
+	db *geoip.DB
+}
+
+func OpenMaxMind(path string) (*MaxMind, error) {
+	db, err := geoip.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MaxMind{db: db}, nil
+}
+
+func (m *MaxMind) Resolve(ip net.IP) (*Location, error) {
+	rec, err := m.db.City(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Location{
+		City:          rec.City.Names["en"],
+		Country:       rec.Country.Names["en"],
+		Continent:     rec.Continent.Names["en"],
+		CountryCode:   rec.Country.IsoCode,
+		ContinentCode: rec.Continent.Code,
+		Lat:           rec.Location.Latitude,
+		Lon:           rec.Location.Longitude,
+	}, nil
+}
+
+// Watch reloads the database once the file is replaced,
+// it blocks until the context is done.
+func (m *MaxMind) Watch(ctx context.Context, interval time.Duration, onError func(err error)) {
+	m.db.Watch(ctx, interval, onError)
+}
+
+func (m *MaxMind) Close() error {
+	return m.db.Close()
+}
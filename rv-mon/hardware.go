@@ -0,0 +1,90 @@
+package rvmon
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sonm-io/core/proto"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+	"go.uber.org/zap"
+)
+
+// hardwareDealsLimit is how many accepted deals of a supplier are
+// taken into account, the same limit map-proxy uses.
+const hardwareDealsLimit = 100
+
+// loadHardware sums up benchmarks of the supplier's accepted deals.
+func (d *dwhChecker) loadHardware(ctx context.Context, addr common.Address) (census.Hardware, error) {
+	deals, err := d.dwh.GetDeals(ctx, &sonm.DealsRequest{
+		Status:     sonm.DealStatus_DEAL_ACCEPTED,
+		SupplierID: sonm.NewEthAddress(addr),
+		Limit:      hardwareDealsLimit,
+	})
+	if err != nil {
+		return census.Hardware{}, err
+	}
+
+	hw := census.Hardware{}
+	for _, deal := range deals.GetDeals() {
+		b := deal.GetDeal().GetBenchmarks()
+		hw.CPUCores += b.CPUCores()
+		hw.GPUCount += b.GPUCount()
+		hw.RAMSize += b.RAMSize()
+		hw.EthHashrate += b.GPUEthHashrate()
+	}
+
+	return hw, nil
+}
+
+// fillHardware sets the hardware of every peer, a wallet having
+// several servers gets the totals assigned to the first one only,
+// so summing peers up does not count deals twice.
+func (d *dwhChecker) fillHardware(ctx context.Context, peers []census.Peer) {
+	seen := map[string]bool{}
+	failed := 0
+	for i := range peers {
+		if seen[peers[i].Eth] {
+			continue
+		}
+
+		seen[peers[i].Eth] = true
+		hw, err := d.hardwareOf(ctx, peers[i].Eth)
+		if err != nil {
+			logger.Debug("cannot load peer hardware from DWH", zap.String("eth", peers[i].Eth), zap.Error(err))
+			failed++
+			continue
+		}
+
+		peers[i].Hardware = hw
+	}
+
+	if failed > 0 {
+		logger.Warn("cannot load hardware of some peers from DWH", zap.Int("count", failed))
+	}
+}
+
+type dwhHardware struct {
+	hardware census.Hardware
+	checked  time.Time
+}
+
+func (d *dwhChecker) hardwareOf(ctx context.Context, eth string) (census.Hardware, error) {
+	d.mu.Lock()
+	h, ok := d.hwCache[eth]
+	d.mu.Unlock()
+	if ok && time.Since(h.checked) < d.ttl {
+		return h.hardware, nil
+	}
+
+	hw, err := d.loadHardware(ctx, common.HexToAddress(eth))
+	if err != nil {
+		return census.Hardware{}, err
+	}
+
+	d.mu.Lock()
+	d.hwCache[eth] = dwhHardware{hardware: hw, checked: time.Now()}
+	d.mu.Unlock()
+
+	return hw, nil
+}
@@ -0,0 +1,227 @@
+package rvmon
+
+import (
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+)
+
+// influxPoints converts results into points written
+// to every backend and the -out file.
+func influxPoints(results []*census.Census) []sink.Point {
+	var infPoints []sink.Point
+
+	for _, c := range results {
+		for hash, loc := range c.Locations {
+			if influxLegacyFlag {
+				infPoints = append(infPoints, legacyInfluxPoint(c, hash, loc))
+				continue
+			}
+
+			tags := map[string]string{
+				"source":  c.Source,
+				"geohash": hash,
+			}
+
+			// influx refuses empty tag values
+			if len(loc.City) > 0 {
+				tags["city"] = loc.City
+			}
+			if len(loc.Country) > 0 {
+				tags["country"] = loc.Country
+			}
+
+			infPoints = append(infPoints, sink.Point{
+				Measurement: influxMeasurementFlag,
+				Tags:        tags,
+				Fields: map[string]interface{}{
+					"count": loc.Count,
+				},
+				Time: c.Time,
+			})
+		}
+	}
+
+	for _, c := range results {
+		if c.Merged {
+			infPoints = append(infPoints, sink.Point{
+				Measurement: influxMeasurementFlag + "_replication",
+				Tags: map[string]string{
+					"source": c.Source,
+				},
+				Fields: map[string]interface{}{
+					"unique":     c.Replication.Unique,
+					"everywhere": c.Replication.Everywhere,
+					"partial":    c.Replication.Partial(),
+				},
+				Time: c.Time,
+			})
+		}
+
+		infPoints = append(infPoints, sink.Point{
+			Measurement: influxMeasurementFlag + "_totals",
+			Tags: map[string]string{
+				"source": c.Source,
+			},
+			Fields: map[string]interface{}{
+				"endpoints": c.Total(),
+				"wallets":   c.WalletCount,
+			},
+			Time: c.Time,
+		})
+
+		if len(aggregateFlag) > 0 {
+			for _, r := range c.Regions(aggregateFlag) {
+				infPoints = append(infPoints, sink.Point{
+					Measurement: influxMeasurementFlag + "_" + aggregateFlag,
+					Tags: map[string]string{
+						"source":      c.Source,
+						aggregateFlag: r.Name,
+					},
+					Fields: regionFields(r),
+					Time:   c.Time,
+				})
+			}
+		}
+
+		for reason, n := range c.Unlocatable {
+			infPoints = append(infPoints, sink.Point{
+				Measurement: influxMeasurementFlag + "_unlocatable",
+				Tags: map[string]string{
+					"source": c.Source,
+					"reason": reason,
+				},
+				Fields: map[string]interface{}{
+					"count": n,
+				},
+				Time: c.Time,
+			})
+		}
+
+		if ghostChecker != nil {
+			infPoints = append(infPoints, sink.Point{
+				Measurement: influxMeasurementFlag + "_ghosts",
+				Tags: map[string]string{
+					"source": c.Source,
+				},
+				Fields: map[string]interface{}{
+					"count": len(c.GhostWallets()),
+				},
+				Time: c.Time,
+			})
+		}
+
+		if c.Churn != nil {
+			infPoints = append(infPoints, sink.Point{
+				Measurement: influxMeasurementFlag + "_churn",
+				Tags: map[string]string{
+					"source": c.Source,
+				},
+				Fields: map[string]interface{}{
+					"joined": len(c.Churn.Joined),
+					"left":   len(c.Churn.Left),
+				},
+				Time: c.Time,
+			})
+		}
+
+		if c.Merged {
+			continue
+		}
+
+		infPoints = append(infPoints, sink.Point{
+			Measurement: influxMeasurementFlag + "_rtt",
+			Tags: map[string]string{
+				"source": c.Source,
+			},
+			Fields: map[string]interface{}{
+				"dial_ms": sink.Millis(c.DialTime),
+				"info_ms": sink.Millis(c.InfoTime),
+			},
+			Time: c.Time,
+		})
+
+		if c.Probe != nil {
+			infPoints = append(infPoints, sink.Point{
+				Measurement: influxMeasurementFlag + "_resolve",
+				Tags: map[string]string{
+					"source": c.Source,
+				},
+				Fields: map[string]interface{}{
+					"sampled":  c.Probe.Sampled,
+					"resolved": c.Probe.Resolved,
+					"ratio":    c.Probe.SuccessRatio(),
+					"avg_ms":   sink.Millis(c.Probe.AvgLatency),
+					"max_ms":   sink.Millis(c.Probe.MaxLatency),
+				},
+				Time: c.Time,
+			})
+		}
+
+		for key, counter := range c.Connectivity {
+			infPoints = append(infPoints, sink.Point{
+				Measurement: influxMeasurementFlag + "_connectivity",
+				Tags: map[string]string{
+					"source":   c.Source,
+					"nat":      key.NAT,
+					"protocol": key.Protocol,
+				},
+				Fields: map[string]interface{}{
+					"count": counter,
+				},
+				Time: c.Time,
+			})
+		}
+	}
+
+	if d := findDiscrepancy(results); d != nil {
+		infPoints = append(infPoints, sink.Point{
+			Measurement: influxMeasurementFlag + "_discrepancy",
+			Tags: map[string]string{
+				"a": d.A,
+				"b": d.B,
+			},
+			Fields: map[string]interface{}{
+				"common": d.Common,
+				"only_a": len(d.OnlyA),
+				"only_b": len(d.OnlyB),
+			},
+			Time: results[0].Time,
+		})
+	}
+
+	return infPoints
+}
+
+// legacyInfluxPoint keeps location as fields, the layout
+// used before geohash, city and country became tags.
+func legacyInfluxPoint(c *census.Census, hash string, loc *census.Location) sink.Point {
+	return sink.Point{
+		Measurement: influxMeasurementFlag,
+		Tags: map[string]string{
+			"source": c.Source,
+		},
+		Fields: map[string]interface{}{
+			"geohash": hash,
+			"name":    loc.Name(),
+			"count":   loc.Count,
+		},
+		Time: c.Time,
+	}
+}
+
+// regionFields adds hardware totals to the region's
+// point when they are loaded from the DWH.
+func regionFields(r census.Region) map[string]interface{} {
+	fields := map[string]interface{}{
+		"count": r.Count,
+	}
+
+	if dwhHardwareFlag {
+		fields["cpu_cores"] = int64(r.Hardware.CPUCores)
+		fields["gpu_count"] = int64(r.Hardware.GPUCount)
+		fields["ram_size"] = int64(r.Hardware.RAMSize)
+		fields["eth_hashrate"] = int64(r.Hardware.EthHashrate)
+	}
+
+	return fields
+}
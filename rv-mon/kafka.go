@@ -0,0 +1,73 @@
+package rvmon
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+)
+
+// kafkaEvent is a single peer seen by a rendezvous server during the poll.
+type kafkaEvent struct {
+	Source string    `json:"source"`
+	Time   time.Time `json:"time"`
+	census.Peer
+}
+
+// kafkaSink publishes every located peer as a JSON event, events are
+// keyed by the wallet address, so ones of the same peer stay ordered.
+type kafkaSink struct {
+	producer sarama.SyncProducer
+	topic    string
+}
+
+func newKafkaSink(brokers, topic string) (*kafkaSink, error) {
+	cfg := sarama.NewConfig()
+	cfg.ClientID = "rv-mon"
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.Retry.Max = int(retriesFlag)
+
+	producer, err := sarama.NewSyncProducer(strings.Split(brokers, ","), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create kafka producer: %v", err)
+	}
+
+	return &kafkaSink{producer: producer, topic: topic}, nil
+}
+
+func (k *kafkaSink) Write(results []*census.Census) error {
+	var messages []*sarama.ProducerMessage
+	for _, c := range results {
+		for _, p := range c.Peers {
+			b, err := json.Marshal(kafkaEvent{Source: c.Source, Time: c.Time, Peer: p})
+			if err != nil {
+				return err
+			}
+
+			messages = append(messages, &sarama.ProducerMessage{
+				Topic:     k.topic,
+				Key:       sarama.StringEncoder(p.Eth),
+				Value:     sarama.ByteEncoder(b),
+				Timestamp: c.Time,
+			})
+		}
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	if err := k.producer.SendMessages(messages); err != nil {
+		return fmt.Errorf("cannot publish to kafka: %v", err)
+	}
+
+	return nil
+}
+
+func (k *kafkaSink) Close() error {
+	return k.producer.Close()
+}
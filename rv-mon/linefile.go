@@ -0,0 +1,84 @@
+package rvmon
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+)
+
+// parseOutFlag returns the path from the "file:/path" output spec.
+func parseOutFlag(out string) (string, error) {
+	if !strings.HasPrefix(out, "file:") || len(out) == len("file:") {
+		return "", fmt.Errorf("unsupported output `%s`, expected file:/path", out)
+	}
+
+	return strings.TrimPrefix(out, "file:"), nil
+}
+
+// writeToFile appends the same points as sent to influx to the
+// file using line protocol, so telegraf can tail it. The file is
+// rotated before writing if it has grown over the size limit.
+func writeToFile(results []*census.Census) error {
+	path, err := parseOutFlag(outFlag)
+	if err != nil {
+		return err
+	}
+
+	if err := rotateFile(path, outMaxSizeFlag, outKeepFlag); err != nil {
+		return fmt.Errorf("cannot rotate `%s`: %v", path, err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open output file: %v", err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, p := range influxPoints(results) {
+		w.WriteString(sink.LineProtocol(p))
+		w.WriteByte('\n')
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("cannot write to output file: %v", err)
+	}
+
+	return nil
+}
+
+// rotateFile renames path to path.1, shifting older files up to
+// path.<keep>, when it exceeds maxSize bytes. Zero maxSize disables
+// rotation, zero keep just truncates the file.
+func rotateFile(path string, maxSize int64, keep uint) error {
+	st, err := os.Stat(path)
+	if os.IsNotExist(err) || maxSize == 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if st.Size() < maxSize {
+		return nil
+	}
+
+	if keep == 0 {
+		return os.Truncate(path, 0)
+	}
+
+	for i := keep - 1; i > 0; i-- {
+		old := fmt.Sprintf("%s.%d", path, i)
+		if _, err := os.Stat(old); err == nil {
+			if err := os.Rename(old, fmt.Sprintf("%s.%d", path, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return os.Rename(path, path+".1")
+}
@@ -1,183 +1,427 @@
-package main
+// Package rvmon counts and locates peers of SONM rendezvous servers.
+package rvmon
 
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
-	"net"
-	"net/url"
 	"os"
+	"sync"
 	"time"
 
-	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/mmcloughlin/geohash"
-	"github.com/oschwald/geoip2-golang"
-	"github.com/sonm-io/core/insonmnia/auth"
-	"github.com/sonm-io/core/proto"
-	"github.com/sonm-io/core/util"
-	"github.com/sonm-io/core/util/xgrpc"
-
-	influx "github.com/influxdata/influxdb/client"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/daemon"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/health"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/logging"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sonmclient"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/tracing"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/geo"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/output"
+	"go.uber.org/zap"
 )
 
+const pollTimeout = 120 * time.Second
+
 var (
-	peerAddrFlag      string
-	databaseFlag      string
-	writeToInfluxFlag bool
+	peerAddrFlag        string
+	peersFileFlag       string
+	databaseFlag        string
+	daemonFlag          bool
+	intervalFlag        time.Duration
+	listenFlag          string
+	alertRulesFlag      string
+	formatFlag          string
+	parquetDirFlag      string
+	publicPrecisionFlag uint
+	sortFlag            string
+	detailsFlag         bool
+	stateFlag           string
+	keyFileFlag         string
+	precisionFlag       uint
+	aggregateFlag       string
+	countryFlag         string
+	continentFlag       string
+	mergeFlag           bool
+	diffFlag            bool
+	rdnsFlag            bool
+	asnDatabaseFlag     string
+	outFlag             string
+	outMaxSizeFlag      int64
+	outKeepFlag         uint
+	dumpFlag            string
+	quietFlag           bool
+	keyPasswordFlag     string
+	retriesFlag         uint
+	checkFlag           bool
+	warnFlag            uint
+	critFlag            uint
+	retryBackoffFlag    time.Duration
+	probeFlag           uint
+	probeTimeoutFlag    time.Duration
+
+	influxMeasurementFlag string
+	influxLegacyFlag      bool
+
+	graphiteAddrFlag   string
+	graphitePrefixFlag string
+
+	kafkaBrokersFlag string
+	kafkaTopicFlag   string
+
+	dwhAddrFlag     string
+	dwhCacheTTLFlag time.Duration
+	dwhHardwareFlag bool
+
+	sqliteFlag          string
+	sqliteRetentionFlag time.Duration
+
+	webhookFlag        string
+	dropThresholdFlag  float64
+	baselineWindowFlag uint
 )
 
+// Flags are command line flags of the tool, parsed by Run.
+var Flags = flag.NewFlagSet("sonm-mon rv", flag.ExitOnError)
+
+// logger is used for diagnostics, results printed by
+// console sinks do not go through it.
+var logger = zap.NewNop()
+
+// logOptions are set by the -log flags.
+var logOptions = logging.Options{Name: "rv-mon"}
+
+// sinkOptions configure metrics backends, see the sink package.
+var sinkOptions = sink.Options{InfluxDatabase: "telegraf", InfluxBucket: "telegraf", StatsdPrefix: "rv", PushJob: "rv_mon"}
+
+// traceOptions configure exporting spans, see the tracing package.
+var traceOptions tracing.Options
+
+// selfHealth tracks polls of the daemon, so the monitor can be monitored.
+var selfHealth = health.New("rv-mon")
+
 func init() {
-	flag.StringVar(&peerAddrFlag, "peer", "", "rendezvous peer address: 0xEth@ip:port")
-	flag.StringVar(&databaseFlag, "db", "geo.mmdb", "path to geoip database")
-	flag.BoolVar(&writeToInfluxFlag, "write", false, "write data to influx")
+	Flags.StringVar(&peerAddrFlag, "peer", "", "comma-separated rendezvous peer addresses: 0xEth@ip:port")
+	Flags.StringVar(&peersFileFlag, "peers-file", "", "file with rendezvous peer addresses, one per line")
+	Flags.StringVar(&databaseFlag, "db", "geo.mmdb", "path to geoip database")
+	Flags.BoolVar(&sinkOptions.Influx, "write", false, "write data to influx, same as -influx")
+	Flags.BoolVar(&daemonFlag, "daemon", false, "keep running and poll the rendezvous periodically")
+	Flags.DurationVar(&intervalFlag, "interval", 60*time.Second, "polling interval for the daemon mode")
+	Flags.StringVar(&formatFlag, "format", "text", "console output format: text, json, csv, parquet or public (anonymized json)")
+	Flags.UintVar(&publicPrecisionFlag, "public-precision", 1, "decimal digits coordinates are rounded to with -format=public")
+	Flags.StringVar(&parquetDirFlag, "parquet-dir", ".", "directory for files written with -format=parquet")
+	Flags.StringVar(&sortFlag, "sort", "count", "text output: order locations by count, name or geohash")
+	Flags.BoolVar(&detailsFlag, "details", false, "list wallet addresses found at every location")
+	Flags.StringVar(&stateFlag, "state", "", "file to keep peers between runs in, enables joined/left reports")
+	Flags.BoolVar(&rdnsFlag, "rdns", false, "resolve PTR records of peers for detailed and JSON output")
+	Flags.StringVar(&asnDatabaseFlag, "asn-db", "", "path to geoip ASN database, enables ASN lookups")
+	Flags.StringVar(&aggregateFlag, "aggregate", "", "also report peers per city, country or continent")
+	Flags.StringVar(&countryFlag, "country", "", "count only peers from these comma-separated countries, names or ISO codes")
+	Flags.StringVar(&continentFlag, "continent", "", "count only peers from these comma-separated continents, names or codes, e.g. EU")
+	Flags.BoolVar(&diffFlag, "diff", false, "compare wallets registered on two rendezvous servers given by -peer")
+	Flags.BoolVar(&mergeFlag, "merge", false, "add network-wide results with peers deduplicated across servers")
+	Flags.UintVar(&precisionFlag, "geohash-precision", 4, "geohash length used to group peers, 1 to 12")
+	Flags.StringVar(&keyFileFlag, "key-file", "", "keystore or hex private key file, a new key is generated every run if empty")
+	Flags.StringVar(&keyPasswordFlag, "key-password", "", "keystore password (KEY_PASSWORD)")
+	Flags.UintVar(&retriesFlag, "retries", 3, "how many times to retry failed rendezvous requests")
+	Flags.DurationVar(&retryBackoffFlag, "retry-backoff", time.Second, "delay before the first retry, doubled on every next one")
+	Flags.UintVar(&probeFlag, "probe", 0, "resolve that many random peers via the rendezvous every poll, 0 to disable")
+	Flags.DurationVar(&probeTimeoutFlag, "probe-timeout", 5*time.Second, "timeout of a single Resolve probe")
+	Flags.BoolVar(&checkFlag, "check", false, "run as a nagios check: print status line and exit with 0/1/2/3")
+	Flags.UintVar(&warnFlag, "warn", 0, "check mode: warning if a server has fewer peers")
+	Flags.UintVar(&critFlag, "crit", 0, "check mode: critical if a server has fewer peers")
+	Flags.BoolVar(&quietFlag, "quiet", false, "log errors only")
+	logOptions.RegisterFlags(Flags)
+	traceOptions.RegisterFlags(Flags)
+	Flags.StringVar(&listenFlag, "listen", "", "serve prometheus metrics, /healthz, /readyz and /info at the given address, implies -daemon")
+	Flags.StringVar(&alertRulesFlag, "alert-rules", "", "evaluate alert rules of the YAML file against exported metrics, implies -daemon")
+
+	Flags.StringVar(&influxMeasurementFlag, "influx-measurement", "map_data", "influx measurement name (INFLUX_MEASUREMENT)")
+	Flags.BoolVar(&influxLegacyFlag, "influx-legacy-schema", false, "write geohash and name as fields instead of tags")
+	sinkOptions.StatsdName = statsdName
+	sinkOptions.RegisterFlags(Flags)
+
+	Flags.StringVar(&dumpFlag, "dump", "", "write raw rendezvous state to a JSON file, the poll time is added to the name")
+	Flags.StringVar(&outFlag, "out", "", "append influx line protocol to a file for telegraf tail input: file:/path")
+	Flags.Int64Var(&outMaxSizeFlag, "out-max-size", 100<<20, "rotate the -out file when it grows over the size in bytes, 0 to disable")
+	Flags.UintVar(&outKeepFlag, "out-keep", 3, "number of rotated -out files to keep")
+
+	Flags.StringVar(&graphiteAddrFlag, "graphite", "", "graphite plaintext protocol address, host:port")
+	Flags.StringVar(&graphitePrefixFlag, "graphite-prefix", "rv", "prefix of graphite metric paths")
+
+	Flags.StringVar(&kafkaBrokersFlag, "kafka", "", "comma-separated kafka brokers to publish peers to, host:port")
+	Flags.StringVar(&kafkaTopicFlag, "kafka-topic", "rv-peers", "kafka topic for peer events")
 
-	flag.Parse()
+	Flags.StringVar(&dwhAddrFlag, "dwh", "", "DWH address to check peers' profiles, orders and deals on, 0xEth@ip:port")
+	Flags.BoolVar(&dwhHardwareFlag, "dwh-hardware", false, "sum up CPU, GPU and RAM of peers' accepted deals on the DWH, requires -dwh")
+	Flags.DurationVar(&dwhCacheTTLFlag, "dwh-cache-ttl", time.Hour, "how long DWH check results are reused")
+
+	Flags.StringVar(&sqliteFlag, "sqlite", "", "archive a row per peer per poll into the sqlite db at the path, needs a cgo build without the nocgo tag")
+	Flags.DurationVar(&sqliteRetentionFlag, "sqlite-retention", 30*24*time.Hour, "remove sqlite rows older than this, 0 keeps everything")
+
+	Flags.StringVar(&webhookFlag, "webhook", "", "URL to POST an alert to when the peer count drops, daemon mode only")
+	Flags.Float64Var(&dropThresholdFlag, "drop-threshold", 30, "alert when the count is lower than the baseline by more than this percent")
+	Flags.UintVar(&baselineWindowFlag, "baseline-window", 10, "number of previous polls the baseline is averaged over")
 }
 
-func main() {
-	if len(peerAddrFlag) == 0 {
-		log.Println("endpoint is empty, exiting")
-		os.Exit(1)
+// Run parses the arguments and runs the tool,
+// the process exits on failures.
+func Run(args []string) {
+	Flags.Parse(args)
+
+	// -quiet wins over -v
+	if quietFlag {
+		logOptions.Verbose = false
+		logOptions.Level = "error"
 	}
 
-	key, err := crypto.GenerateKey()
+	var err error
+	logger, err = logOptions.New()
 	if err != nil {
-		log.Printf("cannot generate key: %v\n", err)
+		log.Printf("cannot create logger: %v\n", err)
 		os.Exit(1)
 	}
+	defer logger.Sync()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-	defer cancel()
-
-	_, TLSConfig, err := util.NewHitlessCertRotator(ctx, key)
+	stopTracing, err := traceOptions.Setup("rv-mon")
 	if err != nil {
-		log.Printf("cannot create TLS config: %v\n", err)
+		logger.Error("cannot set up tracing", zap.Error(err))
 		os.Exit(1)
 	}
+	defer stopTracing()
 
-	addr, err := auth.ParseAddr(peerAddrFlag)
+	peerAddrs, err := loadPeerAddrs(peerAddrFlag, peersFileFlag)
 	if err != nil {
-		log.Printf("cannot parse string `%s` into peer endpoint: %v\n", peerAddrFlag, err)
+		logger.Error("cannot load peers list", zap.Error(err))
 		os.Exit(1)
 	}
 
-	eth, err := addr.ETH()
-	if err != nil {
-		log.Printf("cannot extract eth part from addr `%s`: %v\n", peerAddrFlag, err)
+	if len(peerAddrs) == 0 {
+		logger.Error("endpoint is empty, exiting")
 		os.Exit(1)
 	}
 
-	ip, err := addr.Addr()
-	if err != nil {
-		log.Printf("cannot extract IP part from addr `%s`: %v\n", peerAddrFlag, err)
+	if dwhHardwareFlag && len(dwhAddrFlag) == 0 {
+		logger.Error("-dwh-hardware requires -dwh")
 		os.Exit(1)
 	}
 
-	creds := auth.NewWalletAuthenticator(util.NewTLS(TLSConfig), eth)
-	client, err := xgrpc.NewClient(ctx, ip, creds)
-	if err != nil {
-		log.Printf("cannot create client connection: %v\n", err)
+	if diffFlag && len(peerAddrs) != 2 {
+		logger.Error("exactly two rendezvous servers must be given for -diff")
 		os.Exit(1)
 	}
 
-	rv := sonm.NewRendezvousClient(client)
-	info, err := rv.Info(ctx, &sonm.Empty{})
-	if err != nil {
-		log.Printf("cannot query rv clients: %v\n", err)
+	if err := sinkOptions.Validate(); err != nil {
+		logger.Error("invalid output flags", zap.Error(err))
 		os.Exit(1)
 	}
 
-	db, err := geoip2.Open(databaseFlag)
-	if err != nil {
-		log.Printf("cannot open geoip db: %v\n", err)
+	if precisionFlag < 1 || precisionFlag > 12 {
+		logger.Error("geohash precision must be between 1 and 12")
 		os.Exit(1)
 	}
 
-	defer db.Close()
+	if len(outFlag) > 0 {
+		if _, err := parseOutFlag(outFlag); err != nil {
+			logger.Error("invalid -out", zap.Error(err))
+			os.Exit(1)
+		}
+	}
 
-	var pointCounters = map[string]int{}
-	var nameCache = map[string]string{}
+	if len(aggregateFlag) > 0 && !census.AggregationLevels[aggregateFlag] {
+		logger.Error("unknown aggregation level", zap.String("aggregate", aggregateFlag))
+		os.Exit(1)
+	}
 
-	for _, state := range info.GetState() {
-		for _, srv := range state.GetServers() {
-			ip := net.ParseIP(srv.PublicAddr.Addr.Addr)
-			rec, err := db.City(ip)
-			if err != nil {
-				log.Printf("cannot find IP `%s` in geoip db: %v\n", ip.String(), err)
-				continue
-			}
+	peerFilter = census.NewFilter(countryFlag, continentFlag)
 
-			pointEncoded := geohash.Encode(rec.Location.Latitude, rec.Location.Longitude)
-			var name string
-			if len(rec.City.Names["en"]) > 0 {
-				name = rec.City.Names["en"]
-			} else {
-				name = rec.Country.Names["en"]
-			}
+	if sortFlag != "count" && sortFlag != "name" && sortFlag != "geohash" {
+		logger.Error("unknown sort order", zap.String("sort", sortFlag))
+		os.Exit(1)
+	}
+
+	if _, ok := consoleSinks()[formatFlag]; !ok {
+		logger.Error("unknown output format", zap.String("format", formatFlag))
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	id, err := sonmclient.NewIdentity(keyFileFlag, keyPasswordFlag)
+	if err != nil {
+		logger.Error("cannot set up identity", zap.Error(err))
+		os.Exit(1)
+	}
+
+	logger.Info("using identity", zap.String("eth", id.Eth().Hex()))
 
-			nameCache[pointEncoded] = name
-			if _, ok := pointCounters[pointEncoded]; ok {
-				pointCounters[pointEncoded] += 1
-			} else {
-				pointCounters[pointEncoded] = 1
+	var targets []*target
+	for _, peerAddr := range peerAddrs {
+		t, err := newTarget(ctx, peerAddr, id)
+		if err != nil {
+			if checkFlag {
+				fmt.Printf("RV UNKNOWN - %v\n", err)
+				os.Exit(checkUnknown)
 			}
+
+			logger.Error("cannot connect to rendezvous", zap.Error(err))
+			os.Exit(1)
 		}
+
+		defer t.Close()
+		targets = append(targets, t)
 	}
 
-	if writeToInfluxFlag {
-		writeToInflux(pointCounters, nameCache)
-	} else {
-		writeToConsole(pointCounters, nameCache)
+	db, err := geo.OpenMaxMind(databaseFlag)
+	if err != nil {
+		logger.Error("cannot open geoip db", zap.Error(err))
+		os.Exit(1)
 	}
-}
 
-func writeToConsole(points map[string]int, names map[string]string) {
-	for hash, counter := range points {
-		log.Printf("geohash=%s    name=%s    count=%d\n", hash, names[hash], counter)
+	defer db.Close()
+
+	if rdnsFlag || len(asnDatabaseFlag) > 0 {
+		peerEnricher, err = newEnricher(asnDatabaseFlag, rdnsFlag)
+		if err != nil {
+			logger.Error("cannot open geoip ASN db", zap.Error(err))
+			os.Exit(1)
+		}
+
+		defer peerEnricher.Close()
 	}
-}
 
-func writeToInflux(points map[string]int, names map[string]string) {
-	var infPoints []influx.Point
+	if len(dwhAddrFlag) > 0 {
+		ghostChecker, err = newDWHChecker(ctx, dwhAddrFlag, id, dwhCacheTTLFlag)
+		if err != nil {
+			logger.Error("cannot connect to DWH", zap.Error(err))
+			os.Exit(1)
+		}
+	}
 
-	for hash, counter := range points {
-		infPoints = append(infPoints, influx.Point{
-			Measurement: "map_data",
-			Fields: map[string]interface{}{
-				"geohash": hash,
-				"name":    names[hash],
-				"count":   counter,
-			},
-			Precision: "s",
-		})
+	if checkFlag {
+		os.Exit(runCheck(ctx, targets, db))
 	}
 
-	pb := influx.BatchPoints{
-		Database:  "telegraf",
-		Precision: "s",
-		Points:    infPoints,
+	tool := &daemon.Tool{
+		Name:       "rv-mon",
+		Logger:     logger,
+		Health:     selfHealth,
+		Daemon:     daemonFlag,
+		Interval:   intervalFlag,
+		Timeout:    pollTimeout,
+		Listen:     listenFlag,
+		AlertRules: alertRulesFlag,
 	}
 
-	infc := getInfluxClient()
-	_, err := infc.Write(pb)
+	sinks, err := outputSinks(tool.Exporter())
 	if err != nil {
-		log.Printf("cannot write influx point: %v\n", err)
+		logger.Error("cannot create outputs", zap.Error(err))
 		os.Exit(1)
 	}
-}
 
-func getInfluxClient() *influx.Client {
-	u, err := url.Parse("http://127.0.0.1:8086")
-	if err != nil {
-		log.Printf("cannot parse string into url: %v\n", err)
-		os.Exit(1)
+	defer output.Close(sinks)
+
+	var wd *watchdog
+	if tool.Polling() {
+		// pick up databases replaced by the geoip command
+		onReloadError := func(err error) {
+			logger.Warn("cannot reload geoip db", zap.Error(err))
+		}
+		go db.Watch(ctx, time.Minute, onReloadError)
+		if peerEnricher != nil && peerEnricher.asn != nil {
+			go peerEnricher.asn.Watch(ctx, time.Minute, onReloadError)
+		}
+
+		wd = newWatchdog()
+		defer wd.stopping()
 	}
 
-	client, err := influx.NewClient(influx.Config{URL: *u})
+	err = tool.Run(ctx, func(ctx context.Context) error {
+		err := poll(ctx, targets, db, sinks)
+		if err == nil && wd != nil {
+			wd.keepalive()
+		}
+		return err
+	})
 	if err != nil {
-		log.Printf("cannot create influx client: %v\n", err)
+		logger.Error("run failed", zap.Error(err))
 		os.Exit(1)
 	}
+}
+
+// poll runs a single collect-and-write cycle, rendezvous servers are
+// queried concurrently. Results of reachable servers are written even
+// if some of the others have failed.
+func poll(ctx context.Context, targets []*target, resolver geo.Resolver, sinks []output.Sink) error {
+	ctx, span := tracing.StartSpan(ctx, "poll")
+	defer span.End()
+
+	started := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, pollTimeout)
+	defer cancel()
+
+	wg := sync.WaitGroup{}
+	results := make([]*census.Census, len(targets))
+	errs := make([]error, len(targets))
+
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t *target) {
+			defer wg.Done()
+			results[i], errs[i] = t.collect(ctx, resolver)
+		}(i, t)
+	}
+
+	wg.Wait()
+
+	var ok []*census.Census
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			logger.Warn("cannot query rv clients", zap.String("source", targets[i].source), zap.Error(err))
+			failed++
+			continue
+		}
+
+		results[i].Time = started
+		ok = append(ok, results[i])
+	}
+
+	if len(dumpFlag) > 0 {
+		if err := writeDump(dumpFlag, ok); err != nil {
+			logger.Warn("cannot dump rendezvous state", zap.Error(err))
+		}
+	}
+
+	if mergeFlag && len(ok) > 0 {
+		ok = append(ok, census.Merge(ok))
+	}
+
+	if len(stateFlag) > 0 && len(ok) > 0 {
+		if err := applyChurn(stateFlag, ok); err != nil {
+			logger.Warn("cannot update peers state", zap.Error(err))
+		}
+	}
+
+	writeFailed := 0
+	if len(ok) > 0 {
+		for _, s := range sinks {
+			if err := s.Write(ok); err != nil {
+				logger.Warn("cannot write results", zap.Error(err))
+				writeFailed++
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d rendezvous servers failed", failed, len(targets))
+	}
+
+	if writeFailed > 0 {
+		return fmt.Errorf("%d of %d outputs failed", writeFailed, len(sinks))
+	}
 
-	return client
+	return nil
 }
@@ -0,0 +1,316 @@
+// Package output writes rendezvous censuses to the console and to
+// external systems. Writers are configured explicitly, rv-mon picks
+// and configures them according to its flags.
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+)
+
+// Options tune what the console writers print.
+type Options struct {
+	// Details lists wallets of every location, along with
+	// addresses and AS info of peers when Enriched is set.
+	Details  bool
+	Enriched bool
+	// Sort is the order of locations: count, name or geohash.
+	Sort string
+	// Aggregate is the region level peers are summed up at,
+	// see census.AggregationLevels, nothing is summed if empty.
+	Aggregate string
+	// Hardware adds capacity of suppliers to regions.
+	Hardware bool
+	// Ghosts lists peers marked by the DWH check.
+	Ghosts bool
+}
+
+// Text prints a table of locations per rendezvous server,
+// sorted according to Sort, with latency, churn and connectivity
+// summaries above it.
+func Text(out io.Writer, opts Options) Sink {
+	return SinkFunc(func(results []*census.Census) error {
+		return writeText(out, opts, results)
+	})
+}
+
+func writeText(out io.Writer, opts Options, results []*census.Census) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, c := range results {
+		fmt.Fprintf(w, "SOURCE %s\n", c.Source)
+		fmt.Fprintf(w, "endpoints: %d    wallets: %d\n", c.Total(), c.WalletCount)
+		if c.Merged {
+			r := c.Replication
+			fmt.Fprintf(w, "unique: %d    everywhere: %d    partial: %d\n", r.Unique, r.Everywhere, r.Partial())
+		} else {
+			fmt.Fprintf(w, "dial: %.1fms    info: %.1fms\n", sink.Millis(c.DialTime), sink.Millis(c.InfoTime))
+		}
+
+		if c.Probe != nil {
+			p := c.Probe
+			fmt.Fprintf(w, "resolved: %d/%d    avg: %.1fms    max: %.1fms\n", p.Resolved, p.Sampled, sink.Millis(p.AvgLatency), sink.Millis(p.MaxLatency))
+		}
+
+		if c.Churn != nil {
+			fmt.Fprintf(w, "joined: %d    left: %d\n", len(c.Churn.Joined), len(c.Churn.Left))
+			for _, eth := range c.Churn.Joined {
+				fmt.Fprintf(w, "  + %s\n", eth)
+			}
+			for _, eth := range c.Churn.Left {
+				fmt.Fprintf(w, "  - %s\n", eth)
+			}
+		}
+
+		if opts.Ghosts {
+			ghosts := c.GhostWallets()
+			fmt.Fprintf(w, "ghosts: %d\n", len(ghosts))
+			if opts.Details {
+				for _, eth := range ghosts {
+					fmt.Fprintf(w, "  ? %s\n", eth)
+				}
+			}
+		}
+
+		if len(c.Connectivity) > 0 {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "NAT\tPROTOCOL\tCOUNT")
+			for _, key := range c.ConnectivityKeys() {
+				fmt.Fprintf(w, "%s\t%s\t%d\n", key.NAT, key.Protocol, c.Connectivity[key])
+			}
+		}
+
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "GEOHASH\tNAME\tCOUNTRY\tCOUNT")
+		for _, hash := range sortedLocations(c.Locations, opts.Sort) {
+			loc := c.Locations[hash]
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", hash, loc.City, loc.Country, loc.Count)
+			if opts.Details && opts.Enriched {
+				for _, p := range c.PeersAt(hash) {
+					fmt.Fprintf(w, "\t  %s\t%s AS%d %s %s\t\n", p.Eth, p.IP, p.ASN, p.ASOrg, p.PTR)
+				}
+			} else if opts.Details {
+				for _, eth := range loc.WalletList() {
+					fmt.Fprintf(w, "\t  %s\t\t\n", eth)
+				}
+			}
+		}
+
+		for _, reason := range census.UnlocatableReasons {
+			if n := c.Unlocatable[reason]; n > 0 {
+				fmt.Fprintf(w, "-\tunlocatable (%s)\t\t%d\n", reason, n)
+			}
+		}
+
+		fmt.Fprintf(w, "TOTAL\t\t\t%d\n\n", c.Total())
+
+		if len(opts.Aggregate) > 0 && opts.Hardware {
+			fmt.Fprintf(w, "%s\tCOUNT\tCPU\tGPU\tRAM\tETH HASHRATE\n", strings.ToUpper(opts.Aggregate))
+			for _, r := range c.Regions(opts.Aggregate) {
+				hw := r.Hardware
+				fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\n", r.Name, r.Count, hw.CPUCores, hw.GPUCount, hw.RAMSize, hw.EthHashrate)
+			}
+
+			fmt.Fprintln(w)
+		} else if len(opts.Aggregate) > 0 {
+			fmt.Fprintf(w, "%s\tCOUNT\n", strings.ToUpper(opts.Aggregate))
+			for _, r := range c.Regions(opts.Aggregate) {
+				fmt.Fprintf(w, "%s\t%d\n", r.Name, r.Count)
+			}
+
+			fmt.Fprintln(w)
+		}
+	}
+
+	return w.Flush()
+}
+
+// sortedLocations returns geohashes ordered by the peer count
+// (descending), the location name or the geohash itself.
+func sortedLocations(locations map[string]*census.Location, by string) []string {
+	hashes := make([]string, 0, len(locations))
+	for hash := range locations {
+		hashes = append(hashes, hash)
+	}
+
+	sort.Slice(hashes, func(i, j int) bool {
+		a, b := locations[hashes[i]], locations[hashes[j]]
+		switch {
+		case by == "count" && a.Count != b.Count:
+			return a.Count > b.Count
+		case by == "name" && a.Name() != b.Name():
+			return a.Name() < b.Name()
+		}
+
+		return hashes[i] < hashes[j]
+	})
+
+	return hashes
+}
+
+type jsonLocation struct {
+	Geohash string   `json:"geohash"`
+	Name    string   `json:"name"`
+	Count   int      `json:"count"`
+	Wallets []string `json:"wallets,omitempty"`
+}
+
+type jsonConnectivity struct {
+	NAT      string `json:"nat"`
+	Protocol string `json:"protocol"`
+	Count    int    `json:"count"`
+}
+
+type jsonProbe struct {
+	Sampled  int     `json:"sampled"`
+	Resolved int     `json:"resolved"`
+	AvgMs    float64 `json:"avg_ms"`
+	MaxMs    float64 `json:"max_ms"`
+}
+
+type jsonCensus struct {
+	Source  string    `json:"source"`
+	Time    time.Time `json:"time"`
+	DialMs  float64   `json:"dial_ms"`
+	InfoMs  float64   `json:"info_ms"`
+	Total   int       `json:"total"`
+	Wallets int       `json:"wallets"`
+	// Resolve is set when peers are probed.
+	Resolve   *jsonProbe     `json:"resolve,omitempty"`
+	Locations []jsonLocation `json:"locations"`
+	// Regions are set with Aggregate, keyed by the region name.
+	Regions map[string]int `json:"regions,omitempty"`
+	// Unlocatable counts peers left out of locations by the reason.
+	Unlocatable map[string]int `json:"unlocatable,omitempty"`
+	Peers       []census.Peer  `json:"peers"`
+	// Connectivity counts all servers, including unlocated ones.
+	Connectivity []jsonConnectivity `json:"connectivity"`
+	// Unique, Everywhere and Partial are set for the merged results only.
+	Unique     int      `json:"unique,omitempty"`
+	Everywhere int      `json:"everywhere,omitempty"`
+	Partial    int      `json:"partial,omitempty"`
+	Joined     []string `json:"joined,omitempty"`
+	Left       []string `json:"left,omitempty"`
+	// Ghosts are set only when the DWH check is enabled.
+	Ghosts []string `json:"ghosts,omitempty"`
+}
+
+// JSON prints a single document per poll.
+func JSON(out io.Writer, opts Options) Sink {
+	return SinkFunc(func(results []*census.Census) error {
+		return writeJSON(out, opts, results)
+	})
+}
+
+func writeJSON(out io.Writer, opts Options, results []*census.Census) error {
+	doc := []jsonCensus{}
+	for _, c := range results {
+		jc := jsonCensus{
+			Source:    c.Source,
+			Time:      c.Time,
+			DialMs:    sink.Millis(c.DialTime),
+			InfoMs:    sink.Millis(c.InfoTime),
+			Locations: []jsonLocation{},
+			Peers:     c.Peers,
+			Total:     c.Total(),
+			Wallets:   c.WalletCount,
+		}
+
+		if p := c.Probe; p != nil {
+			jc.Resolve = &jsonProbe{Sampled: p.Sampled, Resolved: p.Resolved, AvgMs: sink.Millis(p.AvgLatency), MaxMs: sink.Millis(p.MaxLatency)}
+		}
+
+		if len(c.Unlocatable) > 0 {
+			jc.Unlocatable = c.Unlocatable
+		}
+
+		if len(opts.Aggregate) > 0 {
+			jc.Regions = map[string]int{}
+			for _, r := range c.Regions(opts.Aggregate) {
+				jc.Regions[r.Name] = r.Count
+			}
+		}
+
+		for hash, loc := range c.Locations {
+			jl := jsonLocation{Geohash: hash, Name: loc.Name(), Count: loc.Count}
+			if opts.Details {
+				jl.Wallets = loc.WalletList()
+			}
+
+			jc.Locations = append(jc.Locations, jl)
+		}
+
+		sort.Slice(jc.Locations, func(i, j int) bool {
+			return jc.Locations[i].Geohash < jc.Locations[j].Geohash
+		})
+
+		jc.Connectivity = []jsonConnectivity{}
+		for key, counter := range c.Connectivity {
+			jc.Connectivity = append(jc.Connectivity, jsonConnectivity{NAT: key.NAT, Protocol: key.Protocol, Count: counter})
+		}
+
+		if c.Merged {
+			jc.Unique = c.Replication.Unique
+			jc.Everywhere = c.Replication.Everywhere
+			jc.Partial = c.Replication.Partial()
+		}
+
+		if c.Churn != nil {
+			jc.Joined, jc.Left = c.Churn.Joined, c.Churn.Left
+		}
+
+		if opts.Ghosts {
+			jc.Ghosts = c.GhostWallets()
+		}
+
+		if jc.Peers == nil {
+			jc.Peers = []census.Peer{}
+		}
+
+		doc = append(doc, jc)
+	}
+
+	return json.NewEncoder(out).Encode(doc)
+}
+
+// CSV prints one row per located peer.
+func CSV(out io.Writer) Sink {
+	return SinkFunc(func(results []*census.Census) error {
+		return writeCSV(out, results)
+	})
+}
+
+func writeCSV(out io.Writer, results []*census.Census) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"source", "eth", "ip", "city", "country", "lat", "lon", "geohash", "ptr", "asn", "as_org"})
+
+	for _, c := range results {
+		for _, p := range c.Peers {
+			w.Write([]string{
+				c.Source,
+				p.Eth,
+				p.IP,
+				p.City,
+				p.Country,
+				strconv.FormatFloat(p.Lat, 'f', -1, 64),
+				strconv.FormatFloat(p.Lon, 'f', -1, 64),
+				p.Geohash,
+				p.PTR,
+				strconv.FormatUint(uint64(p.ASN), 10),
+				p.ASOrg,
+			})
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,109 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+)
+
+const (
+	walletA = "0x8125721C2413d99a33E351e1F6Bb4e56b6b633FD"
+	walletB = "0x5b7D6516Fad04e10DB726933bCD75447fd7B4b17"
+)
+
+func testCensus() *census.Census {
+	return &census.Census{
+		Source: "rv",
+		Time:   time.Unix(1546300800, 0).UTC(),
+		Locations: map[string]*census.Location{
+			"u33db": {City: "Berlin", Country: "Germany", Count: 2, Wallets: map[string]bool{walletA: true, walletB: true}},
+			"xn76u": {City: "Tokyo", Country: "Japan", Count: 1, Wallets: map[string]bool{walletA: true}},
+		},
+		Unlocatable: map[string]int{census.UnlocatablePrivate: 1},
+		WalletCount: 2,
+		Peers: []census.Peer{
+			{Eth: walletA, IP: "1.1.1.1", City: "Berlin", Country: "Germany", Continent: "Europe", Lat: 52.52, Lon: 13.405, Geohash: "u33db"},
+			{Eth: walletB, IP: "1.1.1.2", City: "Berlin", Country: "Germany", Continent: "Europe", Lat: 52.52, Lon: 13.405, Geohash: "u33db"},
+			{Eth: walletA, IP: "3.3.3.3", City: "Tokyo", Country: "Japan", Continent: "Asia", Lat: 35.68, Lon: 139.69, Geohash: "xn76u"},
+		},
+		Connectivity: map[census.ConnKey]int{{NAT: "nat", Protocol: "tcp4"}: 4},
+	}
+}
+
+func TestCSV(t *testing.T) {
+	out := &bytes.Buffer{}
+	if err := CSV(out).Write([]*census.Census{testCensus()}); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want a header and 3 peers:\n%s", len(lines), out)
+	}
+
+	want := "rv," + walletA + ",1.1.1.1,Berlin,Germany,52.52,13.405,u33db,,0,"
+	if lines[1] != want {
+		t.Errorf("got row %q, want %q", lines[1], want)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	out := &bytes.Buffer{}
+	opts := Options{Details: true, Aggregate: "country"}
+	if err := JSON(out, opts).Write([]*census.Census{testCensus()}); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc []jsonCensus
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc) != 1 {
+		t.Fatalf("got %d documents, want 1", len(doc))
+	}
+
+	jc := doc[0]
+	if jc.Total != 4 || jc.Wallets != 2 {
+		t.Errorf("got total %d and %d wallets, want 4 and 2", jc.Total, jc.Wallets)
+	}
+
+	if len(jc.Locations) != 2 || jc.Locations[0].Geohash != "u33db" || len(jc.Locations[0].Wallets) != 2 {
+		t.Errorf("got locations %+v, want Berlin with 2 wallets first", jc.Locations)
+	}
+
+	if jc.Regions["Germany"] != 2 || jc.Regions["Japan"] != 1 {
+		t.Errorf("got regions %v, want 2 peers in Germany and 1 in Japan", jc.Regions)
+	}
+
+	if jc.Ghosts != nil {
+		t.Errorf("got ghosts %v without the DWH check", jc.Ghosts)
+	}
+}
+
+func TestPublic(t *testing.T) {
+	out := &bytes.Buffer{}
+	if err := Public(out, 0).Write([]*census.Census{testCensus()}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, secret := range []string{walletA, walletB, "1.1.1.1", "52.52"} {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("%s is published: %s", secret, out)
+		}
+	}
+
+	var doc []publicCensus
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	peers := doc[0].Peers
+	if len(peers) != 3 || peers[0].Lat != 36 || peers[0].Lon != 140 {
+		t.Errorf("got peers %+v, want rounded coordinates sorted by latitude", peers)
+	}
+}
@@ -0,0 +1,58 @@
+package output
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+)
+
+const graphiteTimeout = 10 * time.Second
+
+// Graphite sends the same metrics as statsd to the address using
+// graphite plaintext protocol, paths start with the prefix, e.g.
+// "rv.1_2_3_4_14099.peers_total 42 1546300800". A connection is
+// made for every poll.
+func Graphite(addr, prefix string) Sink {
+	return SinkFunc(func(results []*census.Census) error {
+		return writeToGraphite(addr, prefix, results)
+	})
+}
+
+func writeToGraphite(addr, prefix string, results []*census.Census) error {
+	conn, err := net.DialTimeout("tcp", addr, graphiteTimeout)
+	if err != nil {
+		return fmt.Errorf("cannot connect to graphite: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetWriteDeadline(time.Now().Add(graphiteTimeout))
+
+	w := bufio.NewWriter(conn)
+	for _, c := range results {
+		now := c.Time.Unix()
+		path := prefix + "." + sink.MetricNode(c.Source)
+		for hash, loc := range c.Locations {
+			fmt.Fprintf(w, "%s.geohash.%s %d %d\n", path, hash, loc.Count, now)
+		}
+
+		fmt.Fprintf(w, "%s.peers_total %d %d\n", path, c.Total(), now)
+		fmt.Fprintf(w, "%s.wallets_total %d %d\n", path, c.WalletCount, now)
+		if c.Merged {
+			fmt.Fprintf(w, "%s.unique %d %d\n", path, c.Replication.Unique, now)
+			fmt.Fprintf(w, "%s.partial %d %d\n", path, c.Replication.Partial(), now)
+		} else {
+			fmt.Fprintf(w, "%s.dial_ms %f %d\n", path, sink.Millis(c.DialTime), now)
+			fmt.Fprintf(w, "%s.info_ms %f %d\n", path, sink.Millis(c.InfoTime), now)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("cannot write to graphite: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+)
+
+// publicPeer is a peer without anything identifying it,
+// coordinates are rounded to the precision given to Public.
+type publicPeer struct {
+	Country string  `json:"country"`
+	City    string  `json:"city"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	ASN     uint    `json:"asn,omitempty"`
+}
+
+type publicCensus struct {
+	Source    string         `json:"source"`
+	Time      time.Time      `json:"time"`
+	Total     int            `json:"total"`
+	Wallets   int            `json:"wallets"`
+	Locations []jsonLocation `json:"locations"`
+	Peers     []publicPeer   `json:"peers"`
+}
+
+// Public prints a privacy-safe dataset suitable for publishing:
+// wallet and IP addresses are dropped, coordinates are rounded to
+// the precision in decimal digits and peers are sorted, so their
+// order tells nothing either.
+func Public(out io.Writer, precision uint) Sink {
+	return SinkFunc(func(results []*census.Census) error {
+		return writePublic(out, precision, results)
+	})
+}
+
+func writePublic(out io.Writer, precision uint, results []*census.Census) error {
+	scale := math.Pow(10, float64(precision))
+	round := func(v float64) float64 {
+		return math.Round(v*scale) / scale
+	}
+
+	doc := []publicCensus{}
+	for _, c := range results {
+		pc := publicCensus{
+			Source:    c.Source,
+			Time:      c.Time,
+			Total:     c.Total(),
+			Wallets:   c.WalletCount,
+			Locations: []jsonLocation{},
+			Peers:     []publicPeer{},
+		}
+
+		for _, hash := range sortedLocations(c.Locations, "geohash") {
+			loc := c.Locations[hash]
+			pc.Locations = append(pc.Locations, jsonLocation{Geohash: hash, Name: loc.Name(), Count: loc.Count})
+		}
+
+		for _, p := range c.Peers {
+			pc.Peers = append(pc.Peers, publicPeer{
+				Country: p.Country,
+				City:    p.City,
+				Lat:     round(p.Lat),
+				Lon:     round(p.Lon),
+				ASN:     p.ASN,
+			})
+		}
+
+		sort.Slice(pc.Peers, func(i, j int) bool {
+			a, b := pc.Peers[i], pc.Peers[j]
+			if a.Lat != b.Lat {
+				return a.Lat < b.Lat
+			}
+			if a.Lon != b.Lon {
+				return a.Lon < b.Lon
+			}
+
+			return a.ASN < b.ASN
+		})
+
+		doc = append(doc, pc)
+	}
+
+	return json.NewEncoder(out).Encode(doc)
+}
@@ -0,0 +1,28 @@
+package output
+
+import (
+	"io"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+)
+
+// Sink outputs results of a single poll.
+type Sink interface {
+	Write(results []*census.Census) error
+}
+
+// SinkFunc allows using ordinary functions as sinks.
+type SinkFunc func(results []*census.Census) error
+
+func (f SinkFunc) Write(results []*census.Census) error {
+	return f(results)
+}
+
+// Close releases connections held by sinks.
+func Close(sinks []Sink) {
+	for _, s := range sinks {
+		if c, ok := s.(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
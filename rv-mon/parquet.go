@@ -0,0 +1,82 @@
+package rvmon
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// parquetRow is a single peer observation, the same
+// columns as the CSV output has plus the poll time.
+type parquetRow struct {
+	Time      int64   `parquet:"name=time, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Source    string  `parquet:"name=source, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Eth       string  `parquet:"name=eth, type=BYTE_ARRAY, convertedtype=UTF8"`
+	IP        string  `parquet:"name=ip, type=BYTE_ARRAY, convertedtype=UTF8"`
+	City      string  `parquet:"name=city, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Country   string  `parquet:"name=country, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Continent string  `parquet:"name=continent, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Lat       float64 `parquet:"name=lat, type=DOUBLE"`
+	Lon       float64 `parquet:"name=lon, type=DOUBLE"`
+	Geohash   string  `parquet:"name=geohash, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ASN       int64   `parquet:"name=asn, type=INT64"`
+	ASOrg     string  `parquet:"name=as_org, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	PTR       string  `parquet:"name=ptr, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Ghost     bool    `parquet:"name=ghost, type=BOOLEAN"`
+}
+
+// writeParquet writes peers of the poll into a new file in
+// the -parquet-dir, named after the poll time.
+func writeParquet(results []*census.Census) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	path := filepath.Join(parquetDirFlag, "rv-"+results[0].Time.UTC().Format("20060102T150405Z")+".parquet")
+	fw, err := local.NewLocalFileWriter(path)
+	if err != nil {
+		return fmt.Errorf("cannot create parquet file: %v", err)
+	}
+	defer fw.Close()
+
+	pw, err := writer.NewParquetWriter(fw, new(parquetRow), 1)
+	if err != nil {
+		return fmt.Errorf("cannot create parquet writer: %v", err)
+	}
+
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+	for _, c := range results {
+		for _, p := range c.Peers {
+			row := parquetRow{
+				Time:      c.Time.UnixNano() / 1e6,
+				Source:    c.Source,
+				Eth:       p.Eth,
+				IP:        p.IP,
+				City:      p.City,
+				Country:   p.Country,
+				Continent: p.Continent,
+				Lat:       p.Lat,
+				Lon:       p.Lon,
+				Geohash:   p.Geohash,
+				ASN:       int64(p.ASN),
+				ASOrg:     p.ASOrg,
+				PTR:       p.PTR,
+				Ghost:     p.Ghost,
+			}
+
+			if err := pw.Write(row); err != nil {
+				return fmt.Errorf("cannot write parquet row: %v", err)
+			}
+		}
+	}
+
+	if err := pw.WriteStop(); err != nil {
+		return fmt.Errorf("cannot finish parquet file: %v", err)
+	}
+
+	return nil
+}
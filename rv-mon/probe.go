@@ -0,0 +1,60 @@
+package rvmon
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/sonm-io/core/proto"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+	"go.uber.org/zap"
+)
+
+func init() {
+	rand.Seed(time.Now().UnixNano())
+}
+
+// probe calls Resolve for up to n randomly chosen wallets one by one,
+// the latency is averaged over successful calls only.
+func (t *target) probe(ctx context.Context, wallets []string, n int) *census.Probe {
+	sample := make([]string, len(wallets))
+	copy(sample, wallets)
+	rand.Shuffle(len(sample), func(i, j int) {
+		sample[i], sample[j] = sample[j], sample[i]
+	})
+	if len(sample) > n {
+		sample = sample[:n]
+	}
+
+	res := &census.Probe{Sampled: len(sample)}
+	var total time.Duration
+	for _, eth := range sample {
+		started := time.Now()
+		err := t.resolve(ctx, eth)
+		latency := time.Since(started)
+		if err != nil {
+			logger.Debug("cannot resolve peer", zap.String("source", t.source), zap.String("eth", eth), zap.Error(err))
+			continue
+		}
+
+		res.Resolved++
+		total += latency
+		if latency > res.MaxLatency {
+			res.MaxLatency = latency
+		}
+	}
+
+	if res.Resolved > 0 {
+		res.AvgLatency = total / time.Duration(res.Resolved)
+	}
+
+	return res
+}
+
+func (t *target) resolve(ctx context.Context, eth string) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeoutFlag)
+	defer cancel()
+
+	_, err := t.rv.Resolve(ctx, &sonm.ConnectRequest{Protocol: "tcp", ID: eth})
+	return err
+}
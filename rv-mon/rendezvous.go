@@ -0,0 +1,170 @@
+package rvmon
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sonm-io/core/proto"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sonmclient"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/geo"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// target is a single monitored rendezvous server.
+type target struct {
+	// source is the server's endpoint, every record
+	// collected from the server is tagged with it.
+	source string
+	conn   *grpc.ClientConn
+	rv     sonm.RendezvousClient
+	// dialTime is how long the connection took to become
+	// ready, measured again after it is lost.
+	dialTime time.Duration
+}
+
+// loadPeerAddrs merges comma-separated list of peers with the ones
+// listed in the file. Empty lines and lines starting with # are ignored.
+func loadPeerAddrs(list, path string) ([]string, error) {
+	var addrs []string
+	for _, addr := range strings.Split(list, ",") {
+		if addr = strings.TrimSpace(addr); len(addr) > 0 {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	if len(path) == 0 {
+		return addrs, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		addr := strings.TrimSpace(scanner.Text())
+		if len(addr) == 0 || strings.HasPrefix(addr, "#") {
+			continue
+		}
+
+		addrs = append(addrs, addr)
+	}
+
+	return addrs, scanner.Err()
+}
+
+func newTarget(ctx context.Context, peerAddr string, id *sonmclient.Identity) (*target, error) {
+	started := time.Now()
+	conn, err := id.Dial(ctx, peerAddr, clientOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	t := &target{
+		source: conn.Addr,
+		conn:   conn.ClientConn,
+		rv:     sonm.NewRendezvousClient(conn.ClientConn),
+	}
+
+	// a server being down is reported by polls, which wait again
+	if err := t.waitReady(ctx, started); err != nil {
+		logger.Warn("rendezvous connection is not ready", zap.String("source", t.source), zap.Error(err))
+	}
+
+	return t, nil
+}
+
+// waitReady blocks until the connection is established and
+// measures the dial time from the moment it has started.
+func (t *target) waitReady(ctx context.Context, started time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, pollTimeout)
+	defer cancel()
+
+	if err := waitReady(ctx, t.conn); err != nil {
+		return fmt.Errorf("connection is not ready: %v", err)
+	}
+
+	t.dialTime = time.Since(started)
+	return nil
+}
+
+func (t *target) Close() error {
+	return t.conn.Close()
+}
+
+// collect queries the rendezvous state and counts servers per geohash.
+func (t *target) collect(ctx context.Context, resolver geo.Resolver) (*census.Census, error) {
+	// the connection is re-established in background
+	// after being lost, the dial time is updated then
+	if t.conn.GetState() != connectivity.Ready {
+		if err := t.waitReady(ctx, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	var info *sonm.RendezvousState
+	var infoTime time.Duration
+	err := withRetry(ctx, "info from "+t.source, func() error {
+		var err error
+		started := time.Now()
+		info, err = t.rv.Info(ctx, &sonm.Empty{})
+		infoTime = time.Since(started)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// lookup failures are summarized once per poll,
+	// every single one is logged at debug level only.
+	c, unlocated := census.Aggregate(t.source, info, resolver, precisionFlag, peerFilter, logger)
+	c.DialTime = t.dialTime
+	c.InfoTime = infoTime
+	if len(dumpFlag) > 0 {
+		c.Raw = info
+	}
+
+	if unlocated > 0 {
+		logger.Warn("cannot locate some peers", zap.String("source", t.source), zap.Int("count", unlocated))
+	}
+
+	if peerEnricher != nil {
+		peerEnricher.enrich(ctx, c.Peers)
+	}
+
+	if ghostChecker != nil {
+		ghostChecker.mark(ctx, c.Peers)
+		if dwhHardwareFlag {
+			ghostChecker.fillHardware(ctx, c.Peers)
+		}
+	}
+
+	if probeFlag > 0 {
+		c.Probe = t.probe(ctx, c.Wallets(), int(probeFlag))
+	}
+
+	return c, nil
+}
+
+// waitReady blocks until the connection is established.
+func waitReady(ctx context.Context, conn *grpc.ClientConn) error {
+	for {
+		state := conn.GetState()
+		if state == connectivity.Ready {
+			return nil
+		}
+
+		if !conn.WaitForStateChange(ctx, state) {
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package rvmon
+
+import (
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/output"
+)
+
+// outputSinks returns sinks enabled by flags, results are printed
+// to the console if there are no others. Results are exported as
+// metrics too unless the exporter is nil.
+func outputSinks(exporter *sink.Exporter) ([]output.Sink, error) {
+	var sinks []output.Sink
+	for _, b := range sinkOptions.Sinks() {
+		sinks = append(sinks, pointSink(b))
+	}
+
+	if exporter != nil {
+		sinks = append(sinks, pointSink(exporter))
+	}
+
+	if len(outFlag) > 0 {
+		sinks = append(sinks, output.SinkFunc(writeToFile))
+	}
+
+	if len(graphiteAddrFlag) > 0 {
+		sinks = append(sinks, output.Graphite(graphiteAddrFlag, graphitePrefixFlag))
+	}
+
+	if len(kafkaBrokersFlag) > 0 {
+		k, err := newKafkaSink(kafkaBrokersFlag, kafkaTopicFlag)
+		if err != nil {
+			return nil, err
+		}
+
+		sinks = append(sinks, k)
+	}
+
+	if len(sqliteFlag) > 0 {
+		s, err := newSQLiteSink(sqliteFlag, sqliteRetentionFlag)
+		if err != nil {
+			return nil, err
+		}
+
+		sinks = append(sinks, s)
+	}
+
+	if len(sinks) == 0 && diffFlag {
+		sinks = append(sinks, output.SinkFunc(writeDiff))
+	}
+
+	if len(sinks) == 0 {
+		sinks = append(sinks, consoleSinks()[formatFlag])
+	}
+
+	// alerts do not replace the console output
+	if len(webhookFlag) > 0 {
+		sinks = append(sinks, newDropAlerter(webhookFlag, dropThresholdFlag, baselineWindowFlag))
+	}
+
+	return sinks, nil
+}
+
+// pointSink writes results converted to points to the backend.
+func pointSink(backend sink.Sink) output.Sink {
+	return output.SinkFunc(func(results []*census.Census) error {
+		return backend.Write(influxPoints(results))
+	})
+}
@@ -0,0 +1,101 @@
+//go:build cgo && !nocgo
+// +build cgo,!nocgo
+
+// The sqlite driver is a cgo one, builds with the nocgo tag
+// and cross-compiled ones get the stub of sqlite_nocgo.go.
+
+package rvmon
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/census"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS observations (
+	time      INTEGER NOT NULL,
+	source    TEXT NOT NULL,
+	eth       TEXT NOT NULL,
+	ip        TEXT NOT NULL,
+	city      TEXT,
+	country   TEXT,
+	continent TEXT,
+	lat       REAL,
+	lon       REAL,
+	geohash   TEXT,
+	asn       INTEGER,
+	as_org    TEXT,
+	ptr       TEXT,
+	ghost     INTEGER
+);
+CREATE INDEX IF NOT EXISTS observations_time ON observations (time);
+CREATE INDEX IF NOT EXISTS observations_eth ON observations (eth);
+`
+
+// sqliteSink archives one row per located peer per poll,
+// rows older than the retention are removed after writing.
+type sqliteSink struct {
+	db        *sql.DB
+	retention time.Duration
+}
+
+func newSQLiteSink(path string, retention time.Duration) (*sqliteSink, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open sqlite db: %v", err)
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("cannot create sqlite schema: %v", err)
+	}
+
+	return &sqliteSink{db: db, retention: retention}, nil
+}
+
+func (s *sqliteSink) Write(results []*census.Census) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("cannot begin sqlite transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO observations
+		(time, source, eth, ip, city, country, continent, lat, lon, geohash, asn, as_org, ptr, ghost)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("cannot prepare sqlite statement: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, c := range results {
+		for _, p := range c.Peers {
+			_, err := stmt.Exec(c.Time.Unix(), c.Source, p.Eth, p.IP, p.City, p.Country, p.Continent,
+				p.Lat, p.Lon, p.Geohash, p.ASN, p.ASOrg, p.PTR, p.Ghost)
+			if err != nil {
+				return fmt.Errorf("cannot insert sqlite row: %v", err)
+			}
+		}
+	}
+
+	if s.retention > 0 {
+		cutoff := time.Now().Add(-s.retention).Unix()
+		if _, err := tx.Exec("DELETE FROM observations WHERE time < ?", cutoff); err != nil {
+			return fmt.Errorf("cannot remove old sqlite rows: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("cannot commit sqlite transaction: %v", err)
+	}
+
+	return nil
+}
+
+func (s *sqliteSink) Close() error {
+	return s.db.Close()
+}
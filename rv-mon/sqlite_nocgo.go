@@ -0,0 +1,17 @@
+//go:build !cgo || nocgo
+// +build !cgo nocgo
+
+package rvmon
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/rv-mon/output"
+)
+
+// newSQLiteSink fails, the sqlite driver needs cgo,
+// see sqlite.go for the real one.
+func newSQLiteSink(path string, retention time.Duration) (output.Sink, error) {
+	return nil, errors.New("built without sqlite support, rebuild with cgo and without the nocgo tag")
+}
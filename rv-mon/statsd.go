@@ -0,0 +1,35 @@
+package rvmon
+
+import (
+	"strings"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+)
+
+// statsdName keeps the statsd layout rv-mon had before the sink
+// package: a gauge per location and totals for every rendezvous
+// server, e.g. "rv.1_2_3_4_14099.peers_total:42|g". Other points
+// are not sent.
+func statsdName(p sink.Point, field string) string {
+	prefix := sinkOptions.StatsdPrefix + "." + sink.MetricNode(p.Tags["source"])
+	suffix := strings.TrimPrefix(p.Measurement, influxMeasurementFlag)
+
+	switch {
+	case suffix == "" && field == "count":
+		hash := p.Tags["geohash"]
+		if legacy, ok := p.Fields["geohash"].(string); ok {
+			hash = legacy
+		}
+		return prefix + ".geohash." + hash
+	case suffix == "_totals" && field == "endpoints":
+		return prefix + ".peers_total"
+	case suffix == "_totals" && field == "wallets":
+		return prefix + ".wallets_total"
+	case suffix == "_replication" && (field == "unique" || field == "partial"):
+		return prefix + "." + field
+	case suffix == "_rtt":
+		return prefix + "." + field
+	}
+
+	return ""
+}
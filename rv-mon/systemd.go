@@ -0,0 +1,42 @@
+package rvmon
+
+import (
+	"github.com/coreos/go-systemd/daemon"
+	"go.uber.org/zap"
+)
+
+// watchdog notifies systemd about the daemon being alive, it is
+// a no-op unless the service has WatchdogSec set.
+type watchdog struct {
+	enabled bool
+}
+
+// newWatchdog tells systemd the daemon is ready. Keepalives are sent
+// after successful polls only, so WatchdogSec should be larger than
+// the polling interval, a few failed polls in a row cause a restart.
+func newWatchdog() *watchdog {
+	daemon.SdNotify(false, daemon.SdNotifyReady)
+
+	interval, err := daemon.SdWatchdogEnabled(false)
+	if err != nil || interval == 0 {
+		return &watchdog{}
+	}
+
+	if interval < intervalFlag+pollTimeout {
+		logger.Warn("systemd watchdog timeout is shorter than a poll cycle may take",
+			zap.Duration("watchdog", interval), zap.Duration("cycle", intervalFlag+pollTimeout))
+	}
+
+	logger.Info("systemd watchdog enabled", zap.Duration("timeout", interval))
+	return &watchdog{enabled: true}
+}
+
+func (w *watchdog) keepalive() {
+	if w.enabled {
+		daemon.SdNotify(false, daemon.SdNotifyWatchdog)
+	}
+}
+
+func (w *watchdog) stopping() {
+	daemon.SdNotify(false, daemon.SdNotifyStopping)
+}
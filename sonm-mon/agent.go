@@ -0,0 +1,169 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/daemon"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/logging"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/tracing"
+	"go.uber.org/zap"
+)
+
+// agentShutdownTimeout is how long jobs may take to stop,
+// those serving forever, e.g. map, are not waited for.
+const agentShutdownTimeout = 10 * time.Second
+
+// unhostable are commands the agent refuses to run,
+// the dashboard serves its own UI on -listen.
+var unhostable = map[string]bool{"agent": true, "dashboard": true}
+
+var (
+	agentListenFlag   string
+	agentJobsFlag     string
+	agentParallelFlag int
+)
+
+var agentFlags = flag.NewFlagSet("sonm-mon agent", flag.ExitOnError)
+
+// agentLogOptions and agentTraceOptions are shared by the jobs, the
+// standard logger and spans of every job go where the agent sets.
+var (
+	agentLogOptions   = logging.Options{Name: "sonm-mon"}
+	agentTraceOptions tracing.Options
+)
+
+func init() {
+	agentFlags.StringVar(&agentListenFlag, "listen", ":9100", "serve metrics of all jobs on /metrics and endpoints of every job under /<tool>/")
+	agentFlags.StringVar(&agentJobsFlag, "jobs", "", "comma-separated commands to run, those having a config section if empty")
+	agentFlags.IntVar(&agentParallelFlag, "parallel", 4, "how many polls of all jobs may run at once")
+	agentLogOptions.RegisterFlags(agentFlags)
+	agentTraceOptions.RegisterFlags(agentFlags)
+
+	commands = append(commands, command{"agent", "run several monitors in one process", nil, agentFlags, runAgent})
+}
+
+// agentJobs returns commands named by -jobs or by config sections.
+func agentJobs(cfg config) ([]*command, error) {
+	var names []string
+	if len(agentJobsFlag) > 0 {
+		for _, name := range strings.Split(agentJobsFlag, ",") {
+			if name = strings.TrimSpace(name); len(name) > 0 {
+				names = append(names, name)
+			}
+		}
+	} else {
+		for name := range cfg {
+			if name != "global" && !unhostable[name] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+	}
+
+	var jobs []*command
+	for _, name := range names {
+		c := findCommand(name)
+		if c == nil {
+			return nil, fmt.Errorf("unknown command `%s`", name)
+		}
+
+		if unhostable[c.name] {
+			return nil, fmt.Errorf("command `%s` cannot run within the agent", c.name)
+		}
+
+		jobs = append(jobs, c)
+	}
+
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("no jobs, set -jobs or add sections of commands to the config")
+	}
+
+	return jobs, nil
+}
+
+// runAgent runs commands as jobs of one process: polls are scheduled
+// by the shared scheduler, metrics go to the one registry served on
+// -listen and tools share the identity, logging of the standard logger
+// and tracing. Every job gets flags from its config section, as if run
+// alone, and is made a daemon. The agent exits if any job fails to start.
+func runAgent(args []string) {
+	agentFlags.Parse(args)
+
+	jobs, err := agentJobs(appConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	logger, err := agentLogOptions.New()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot create logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer logger.Sync()
+	defer zap.RedirectStdLog(logger)()
+
+	// jobs set up tracing too, but only the first setup takes effect
+	stopTracing, err := agentTraceOptions.Setup("sonm-mon")
+	if err != nil {
+		logger.Error("cannot set up tracing", zap.Error(err))
+		os.Exit(1)
+	}
+	defer stopTracing()
+
+	agent := daemon.StartAgent(agentParallelFlag)
+	go func() {
+		if err := http.ListenAndServe(agentListenFlag, agent); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot serve agent endpoints: %v\n", err)
+			os.Exit(1)
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	for _, c := range jobs {
+		jobArgs := appConfig.args(c)
+		switch {
+		case c.flags.Lookup("listen") != nil:
+			// the address is ignored, endpoints are mounted on the agent
+			jobArgs = append(jobArgs, "-listen="+agentListenFlag)
+		case c.flags.Lookup("daemon") != nil:
+			jobArgs = append(jobArgs, "-daemon")
+		}
+
+		wg.Add(1)
+		go func(c *command, args []string) {
+			defer wg.Done()
+			c.run(args)
+		}(c, jobArgs)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// jobs stop on the same signal by themselves
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case <-done:
+		return
+	case <-sigs:
+	}
+
+	select {
+	case <-done:
+	case <-time.After(agentShutdownTimeout):
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// setting is a value shared by subcommands, some of them name
+// the flag differently, e.g. the geoip database is -db of rv
+// and map, but -geoip-db of relay.
+type setting struct {
+	key  string
+	help string
+	// flags are names of the flag by the subcommand,
+	// the key is used for subcommands missing here.
+	flags map[string]string
+	// env are variables read before the SONM_MON_<KEY> one,
+	// they are kept for deployments predating the config.
+	env []string
+	// commands limit the setting to these subcommands,
+	// it applies to any having the flag if empty.
+	commands []string
+}
+
+var settings = []setting{
+	{key: "key-file", help: "keystore or hex private key file"},
+	{key: "key-password", help: "keystore password", env: []string{"KEY_PASSWORD"}},
+	{key: "dwh", help: "DWH address: 0xEth@ip:port"},
+	{key: "rendezvous", help: "rendezvous address: 0xEth@ip:port", flags: map[string]string{"rv": "peer", "worker": "rv", "npp": "rv"}},
+	{key: "geoip-db", help: "MaxMind city database", flags: map[string]string{"map": "db", "rv": "db", "geoip": "db"}},
+	{key: "geoip-license-key", help: "MaxMind license key", flags: map[string]string{"geoip": "license-key"}, env: []string{"MAXMIND_LICENSE_KEY"}},
+	{key: "geoip-account-id", help: "MaxMind account ID", flags: map[string]string{"geoip": "account-id"}, env: []string{"MAXMIND_ACCOUNT_ID"}},
+	{key: "interval", help: "polling interval of daemons"},
+	{key: "timeout", help: "timeout of a single poll"},
+	{key: "alert-rules", help: "alerting rules file"},
+	{key: "log", help: "where to write logs: stderr, syslog, journald or file:/path"},
+	{key: "log-level", help: "log level"},
+	{key: "log-format", help: "log format: console or json"},
+	{key: "otlp-endpoint", help: "OTLP/HTTP collector to export traces to", env: []string{"OTEL_EXPORTER_OTLP_ENDPOINT"}},
+	{key: "influx", help: "write points to influx"},
+	{key: "influx-url", help: "influx url", env: []string{"INFLUX_URL"}},
+	{key: "influx-db", help: "influx database", env: []string{"INFLUX_DB"}},
+	{key: "influx-rp", help: "influx retention policy", env: []string{"INFLUX_RP"}},
+	{key: "influx-user", help: "influx username", env: []string{"INFLUX_USER"}},
+	{key: "influx-password", help: "influx password", env: []string{"INFLUX_PASSWORD"}},
+	{key: "influx-org", help: "influx 2.x organization", env: []string{"INFLUX_ORG"}},
+	{key: "influx-bucket", help: "influx 2.x bucket", env: []string{"INFLUX_BUCKET"}},
+	{key: "influx-token", help: "influx 2.x auth token", env: []string{"INFLUX_TOKEN"}},
+	{key: "influx-measurement", help: "influx measurement of rv and relay", env: []string{"INFLUX_MEASUREMENT"}, commands: []string{"rv", "relay"}},
+	{key: "telegram-token", help: "telegram bot token of relay alerts", env: []string{"TELEGRAM_TOKEN"}},
+	{key: "slack-webhook", help: "slack webhook of relay alerts", env: []string{"SLACK_WEBHOOK"}},
+	{key: "statsd", help: "statsd address"},
+	{key: "pushgateway", help: "prometheus pushgateway url"},
+}
+
+// flagName returns the name of the setting flag of the command,
+// empty if the command has no such flag.
+func (s *setting) flagName(c *command) string {
+	if len(s.commands) > 0 && !contains(s.commands, c.name) {
+		return ""
+	}
+
+	name := s.key
+	if n, ok := s.flags[c.name]; ok {
+		name = n
+	}
+
+	if c.flags.Lookup(name) == nil {
+		return ""
+	}
+
+	return name
+}
+
+// envNames returns variables of the setting in the order they are read.
+func (s *setting) envNames() []string {
+	return append(append([]string{}, s.env...), envName(s.key))
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func findSetting(key string) *setting {
+	for i := range settings {
+		if settings[i].key == key {
+			return &settings[i]
+		}
+	}
+
+	return nil
+}
+
+// envName returns the variable overriding the config value,
+// e.g. SONM_MON_KEY_FILE or SONM_MON_RV_DB.
+func envName(parts ...string) string {
+	name := "SONM_MON_" + strings.Join(parts, "_")
+	return strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// config keeps flag values by the subcommand name. Keys of the
+// "global" section are settings, they apply to every subcommand
+// having the matching flag, as do other flags named there.
+type config map[string]map[string]interface{}
+
+func loadConfig(path string) (config, error) {
+	if len(path) == 0 {
+		return config{}, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := config{}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	for name := range cfg {
+		if name != "global" && findCommand(name) == nil {
+			return nil, fmt.Errorf("unknown section `%s`", name)
+		}
+	}
+
+	for key := range cfg["global"] {
+		if findSetting(key) == nil && !anyCommandFlag(key) {
+			return nil, fmt.Errorf("unknown global setting `%s`", key)
+		}
+	}
+
+	return cfg, nil
+}
+
+func anyCommandFlag(name string) bool {
+	for _, c := range commands {
+		if c.flags.Lookup(name) != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// args turns configured values into flags of the command, they go
+// before the command line, so the command line takes precedence.
+// Values are taken from the global section, then from the section
+// of the command and then from the environment, the latter wins.
+func (cfg config) args(c *command) []string {
+	values := map[string]interface{}{}
+	for key, v := range cfg["global"] {
+		name := key
+		if s := findSetting(key); s != nil {
+			name = s.flagName(c)
+		}
+
+		if len(name) > 0 && c.flags.Lookup(name) != nil {
+			values[name] = v
+		}
+	}
+
+	// values of the command itself are passed as is,
+	// so the command reports misspelled flags
+	for name, v := range cfg[c.name] {
+		values[name] = v
+	}
+
+	for _, s := range settings {
+		name := s.flagName(c)
+		if len(name) == 0 {
+			continue
+		}
+
+		for _, env := range s.envNames() {
+			if v, ok := os.LookupEnv(env); ok {
+				values[name] = v
+			}
+		}
+	}
+
+	c.flags.VisitAll(func(f *flag.Flag) {
+		if v, ok := os.LookupEnv(envName(c.name, f.Name)); ok {
+			values[f.Name] = v
+		}
+	})
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var args []string
+	for _, name := range names {
+		args = append(args, fmt.Sprintf("-%s=%v", name, values[name]))
+	}
+
+	return args
+}
+
+// printSettings lists settings of the global section with variables
+// overriding them, any flag is also set by SONM_MON_<COMMAND>_<FLAG>.
+func printSettings() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "\nshared settings, the \"global\" config section or environment:\n")
+	for _, s := range settings {
+		fmt.Fprintf(out, "  %-18s %s (%s)\n", s.key, s.help, strings.Join(s.envNames(), ", "))
+	}
+
+	fmt.Fprintf(out, "\nflags of a command are also set by SONM_MON_<COMMAND>_<FLAG>, e.g. SONM_MON_RV_DB,\nthe command line overrides the environment, which overrides the config.\n")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	benchmon "github.com/sshaman1101/sonm-monitoring-tools/bench-mon"
+	chainmon "github.com/sshaman1101/sonm-monitoring-tools/chain-mon"
+	"github.com/sshaman1101/sonm-monitoring-tools/dashboard"
+	dealmon "github.com/sshaman1101/sonm-monitoring-tools/deal-mon"
+	dwhmon "github.com/sshaman1101/sonm-monitoring-tools/dwh-mon"
+	geoipupdate "github.com/sshaman1101/sonm-monitoring-tools/geoip-update"
+	mapproxy "github.com/sshaman1101/sonm-monitoring-tools/map-proxy"
+	marketmon "github.com/sshaman1101/sonm-monitoring-tools/market-mon"
+	nppmon "github.com/sshaman1101/sonm-monitoring-tools/npp-mon"
+	pricemon "github.com/sshaman1101/sonm-monitoring-tools/price-mon"
+	relaymon "github.com/sshaman1101/sonm-monitoring-tools/relay-mon"
+	rvmon "github.com/sshaman1101/sonm-monitoring-tools/rv-mon"
+	workermon "github.com/sshaman1101/sonm-monitoring-tools/worker-mon"
+)
+
+// command is a tool run as a subcommand, aliases are names
+// of the binaries it replaces, e.g. symlinks to sonm-mon.
+type command struct {
+	name    string
+	help    string
+	aliases []string
+	flags   *flag.FlagSet
+	run     func(args []string)
+}
+
+var commands = []command{
+	{"map", "serve supplier locations for the network map", []string{"map-proxy", "map_proxy"}, mapproxy.Flags, mapproxy.Run},
+	{"rv", "count and locate peers of rendezvous servers", []string{"rv-mon", "rv_mon"}, rvmon.Flags, rvmon.Run},
+	{"relay", "monitor relay clusters", []string{"relay-mon", "relay_mon"}, relaymon.Flags, relaymon.Run},
+	{"dwh", "check DWH availability and data freshness", []string{"dwh-mon", "dwh_mon"}, dwhmon.Flags, dwhmon.Run},
+	{"market", "sample orderbook statistics", []string{"market-mon", "market_mon"}, marketmon.Flags, marketmon.Run},
+	{"deal", "report opened and closed deals", []string{"deal-mon", "deal_mon"}, dealmon.Flags, dealmon.Run},
+	{"chain", "track sidechain block production", []string{"chain-mon", "chain_mon"}, chainmon.Flags, chainmon.Run},
+	{"worker", "probe worker endpoints", []string{"worker-mon", "worker_mon"}, workermon.Flags, workermon.Run},
+	{"npp", "connect to peers through the rendezvous and relays", []string{"npp-mon", "npp_mon"}, nppmon.Flags, nppmon.Run},
+	{"price", "poll exchanges for the SNM price", []string{"price-mon", "price_mon"}, pricemon.Flags, pricemon.Run},
+	{"bench", "check the benchmark list workers download", []string{"bench-mon", "bench_mon"}, benchmon.Flags, benchmon.Run},
+	{"geoip", "download MaxMind databases", []string{"geoip-update", "geoip_update"}, geoipupdate.Flags, geoipupdate.Run},
+	{"dashboard", "serve a web dashboard of the monitors", nil, dashboard.Flags, dashboard.Run},
+}
+
+var configFlag string
+
+// appConfig is the loaded config, the agent passes
+// its sections to the jobs.
+var appConfig = config{}
+
+func init() {
+	flag.StringVar(&configFlag, "config", os.Getenv("SONM_MON_CONFIG"), "YAML file with shared settings and flag values of subcommands (SONM_MON_CONFIG)")
+	flag.Usage = usage
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-config file] <command> [flags]\n\ncommands:\n", filepath.Base(os.Args[0]))
+	for _, c := range commands {
+		fmt.Fprintf(flag.CommandLine.Output(), "  %-10s %s\n", c.name, c.help)
+	}
+
+	fmt.Fprintf(flag.CommandLine.Output(), "\nglobal flags:\n")
+	flag.PrintDefaults()
+	printSettings()
+}
+
+func findCommand(name string) *command {
+	for i, c := range commands {
+		if c.name == name {
+			return &commands[i]
+		}
+
+		for _, alias := range c.aliases {
+			if alias == name {
+				return &commands[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	args := os.Args[1:]
+
+	// invoked through a symlink named after one of the old binaries
+	c := findCommand(strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe"))
+	if c == nil {
+		flag.Parse()
+		if flag.NArg() == 0 {
+			flag.Usage()
+			os.Exit(2)
+		}
+
+		if c = findCommand(flag.Arg(0)); c == nil {
+			fmt.Fprintf(os.Stderr, "unknown command `%s`\n", flag.Arg(0))
+			flag.Usage()
+			os.Exit(2)
+		}
+
+		args = flag.Args()[1:]
+	}
+
+	cfg, err := loadConfig(configFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot load config: %v\n", err)
+		os.Exit(1)
+	}
+	appConfig = cfg
+
+	c.run(append(cfg.args(c), args...))
+}
@@ -0,0 +1,60 @@
+package workermon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sonm-io/core/proto"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sonmclient"
+	"google.golang.org/grpc"
+)
+
+// discovery lists workers registered on the rendezvous.
+type discovery struct {
+	conn *grpc.ClientConn
+	rv   sonm.RendezvousClient
+}
+
+func newDiscovery(ctx context.Context, id *sonmclient.Identity, addr string) (*discovery, error) {
+	conn, err := id.Dial(ctx, addr, sonmclient.Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &discovery{conn: conn.ClientConn, rv: sonm.NewRendezvousClient(conn.ClientConn)}, nil
+}
+
+func (d *discovery) Close() error {
+	return d.conn.Close()
+}
+
+// workers returns "0xEth@ip:port" of public addresses of servers
+// registered on the rendezvous, workers behind NAT are expected
+// to be unreachable.
+func (d *discovery) workers(ctx context.Context) ([]string, error) {
+	info, err := d.rv.Info(ctx, &sonm.Empty{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot discover workers: %v", err)
+	}
+
+	var workers []string
+	for id, state := range info.GetState() {
+		parts := strings.Split(id, "//")
+		eth := common.HexToAddress(parts[len(parts)-1]).Hex()
+		for _, srv := range state.GetServers() {
+			addr := srv.GetPublicAddr().GetAddr()
+			if len(addr.GetAddr()) == 0 {
+				continue
+			}
+
+			host := strings.TrimSuffix(strings.TrimPrefix(addr.GetAddr(), "["), "]")
+			workers = append(workers, eth+"@"+net.JoinHostPort(host, strconv.Itoa(int(addr.GetPort()))))
+		}
+	}
+
+	return workers, nil
+}
@@ -0,0 +1,66 @@
+package workermon
+
+import (
+	"time"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+)
+
+// influxPoints returns a point per worker followed by a point with totals.
+func influxPoints(results []*workerStatus) []sink.Point {
+	var points []sink.Point
+	reachable := 0
+	for _, st := range results {
+		if st.reachable {
+			reachable++
+		}
+
+		points = append(points, sink.Point{
+			Measurement: influxMeasurementFlag,
+			Tags:        workerTags(st),
+			Fields:      workerFields(st),
+			Time:        st.time,
+		})
+	}
+
+	points = append(points, sink.Point{
+		Measurement: influxMeasurementFlag + "_totals",
+		Fields: map[string]interface{}{
+			"total":     len(results),
+			"reachable": reachable,
+		},
+		Time: time.Now(),
+	})
+
+	return points
+}
+
+// workerTags are tags of the worker's line or point, the version
+// is known only if the monitor is allowed to query the status.
+func workerTags(st *workerStatus) map[string]string {
+	tags := map[string]string{"endpoint": st.endpoint}
+	if len(st.eth) > 0 {
+		tags["eth"] = st.eth
+	}
+	if len(st.version) > 0 {
+		tags["version"] = st.version
+	}
+
+	return tags
+}
+
+func workerFields(st *workerStatus) map[string]interface{} {
+	fields := map[string]interface{}{
+		"reachable":  st.reachable,
+		"authorized": st.authorized,
+	}
+
+	if st.reachable {
+		fields["latency_ms"] = sink.Millis(st.latency)
+	}
+	if st.authorized {
+		fields["uptime_s"] = st.uptime.Seconds()
+	}
+
+	return fields
+}
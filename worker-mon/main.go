@@ -0,0 +1,172 @@
+// Package workermon checks reachability and status of SONM workers.
+package workermon
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/daemon"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/health"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/logging"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sink"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sonmclient"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/tracing"
+	"go.uber.org/zap"
+)
+
+var (
+	workersFlag       string
+	workersFileFlag   string
+	rvAddrFlag        string
+	keyFileFlag       string
+	keyPasswordFlag   string
+	daemonFlag        bool
+	intervalFlag      time.Duration
+	timeoutFlag       time.Duration
+	workerTimeoutFlag time.Duration
+	parallelFlag      uint
+	listenFlag        string
+	alertRulesFlag    string
+
+	influxMeasurementFlag string
+)
+
+// Flags are command line flags of the tool, parsed by Run.
+var Flags = flag.NewFlagSet("sonm-mon worker", flag.ExitOnError)
+
+// logger is used for diagnostics, results printed by
+// console sinks do not go through it.
+var logger = zap.NewNop()
+
+// logOptions are set by the -log flags.
+var logOptions = logging.Options{Name: "worker-mon"}
+
+// sinkOptions configure metrics backends, see the sink package.
+var sinkOptions = sink.Options{InfluxDatabase: "sonm", StatsdPrefix: "sonm", PushJob: "worker_mon"}
+
+// traceOptions configure exporting spans, see the tracing package.
+var traceOptions tracing.Options
+
+// selfHealth tracks polls of the daemon, so the monitor can be monitored.
+var selfHealth = health.New("worker-mon")
+
+func init() {
+	Flags.StringVar(&workersFlag, "workers", "", "comma-separated worker addresses: 0xEth@ip:port")
+	Flags.StringVar(&workersFileFlag, "workers-file", "", "file with worker addresses, one per line")
+	Flags.StringVar(&rvAddrFlag, "rv", "", "rendezvous address to discover workers from: 0xEth@ip:port")
+	Flags.StringVar(&keyFileFlag, "key-file", "", "keystore or hex private key file, a new key is generated every run if empty")
+	Flags.StringVar(&keyPasswordFlag, "key-password", "", "keystore password (KEY_PASSWORD)")
+	Flags.BoolVar(&daemonFlag, "daemon", false, "keep running and probe workers periodically")
+	Flags.DurationVar(&intervalFlag, "interval", 5*time.Minute, "polling interval for the daemon mode")
+	Flags.DurationVar(&timeoutFlag, "timeout", 2*time.Minute, "how long probing all workers may take")
+	Flags.DurationVar(&workerTimeoutFlag, "worker-timeout", 10*time.Second, "how long probing a single worker may take")
+	Flags.UintVar(&parallelFlag, "parallel", 20, "how many workers to probe at once")
+	Flags.StringVar(&listenFlag, "listen", "", "serve prometheus metrics and health endpoints at the given address, implies -daemon")
+	Flags.StringVar(&alertRulesFlag, "alert-rules", "", "evaluate alert rules of the YAML file against exported metrics, implies -daemon")
+	logOptions.RegisterFlags(Flags)
+	traceOptions.RegisterFlags(Flags)
+	Flags.StringVar(&influxMeasurementFlag, "influx-measurement", "worker", "measurement name of points")
+	sinkOptions.RegisterFlags(Flags)
+}
+
+// Run parses the arguments and runs the tool,
+// the process exits on failures.
+func Run(args []string) {
+	Flags.Parse(args)
+
+	static, err := loadWorkers(workersFlag, workersFileFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot load workers list: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(static) == 0 && len(rvAddrFlag) == 0 {
+		fmt.Fprintln(os.Stderr, "either workers or the rendezvous to discover them from must be given")
+		os.Exit(1)
+	}
+
+	if parallelFlag == 0 {
+		fmt.Fprintln(os.Stderr, "parallelism cannot be zero")
+		os.Exit(1)
+	}
+
+	logger, err = logOptions.New()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot create logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer logger.Sync()
+
+	stopTracing, err := traceOptions.Setup("worker-mon")
+	if err != nil {
+		logger.Error("cannot set up tracing", zap.Error(err))
+		os.Exit(1)
+	}
+	defer stopTracing()
+
+	if err := sinkOptions.Validate(); err != nil {
+		logger.Error("invalid output flags", zap.Error(err))
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	id, err := sonmclient.NewIdentity(keyFileFlag, keyPasswordFlag)
+	if err != nil {
+		logger.Error("cannot set up identity", zap.Error(err))
+		os.Exit(1)
+	}
+
+	logger.Info("using identity", zap.String("eth", id.Eth().Hex()))
+
+	p := &prober{id: id, static: static}
+	if len(rvAddrFlag) > 0 {
+		if p.rv, err = newDiscovery(ctx, id, rvAddrFlag); err != nil {
+			logger.Error("cannot connect to rendezvous", zap.Error(err))
+			os.Exit(1)
+		}
+		defer p.rv.Close()
+	}
+
+	tool := &daemon.Tool{
+		Name:       "worker-mon",
+		Logger:     logger,
+		Health:     selfHealth,
+		Daemon:     daemonFlag,
+		Interval:   intervalFlag,
+		Timeout:    timeoutFlag,
+		Listen:     listenFlag,
+		AlertRules: alertRulesFlag,
+	}
+
+	backends := sinkOptions.Outputs(tool.Exporter())
+	err = tool.Run(ctx, func(ctx context.Context) error {
+		return poll(ctx, p, backends)
+	})
+	if err != nil {
+		logger.Error("run failed", zap.Error(err))
+		logger.Sync()
+		os.Exit(1)
+	}
+}
+
+// poll probes workers and writes results, unreachable
+// workers are results too, so they fail no poll.
+func poll(ctx context.Context, p *prober, backends []sink.Sink) error {
+	ctx, span := tracing.StartSpan(ctx, "poll")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, timeoutFlag)
+	defer cancel()
+
+	results, err := p.probeAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	return sink.WriteAll(backends, influxPoints(results))
+}
@@ -0,0 +1,176 @@
+package workermon
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sonm-io/core/proto"
+	"github.com/sshaman1101/sonm-monitoring-tools/internal/sonmclient"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// loadWorkers merges comma-separated list of workers with the ones
+// listed in the file. Empty lines and lines starting with # are ignored.
+func loadWorkers(list, path string) ([]string, error) {
+	var workers []string
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			workers = append(workers, v)
+		}
+	}
+
+	if len(path) == 0 {
+		return workers, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		v := strings.TrimSpace(scanner.Text())
+		if len(v) == 0 || strings.HasPrefix(v, "#") {
+			continue
+		}
+
+		workers = append(workers, v)
+	}
+
+	return workers, scanner.Err()
+}
+
+// workerStatus is a result of a single worker probe.
+type workerStatus struct {
+	endpoint string
+	eth      string
+	time     time.Time
+	// reachable is set when the worker has authenticated with
+	// its wallet and answered, even if it has denied the call.
+	reachable bool
+	// authorized is set when the worker has let the monitor
+	// know its status, the monitor must be its admin for that.
+	authorized bool
+	version    string
+	uptime     time.Duration
+	latency    time.Duration
+	err        error
+}
+
+type prober struct {
+	id     *sonmclient.Identity
+	static []string
+	rv     *discovery
+}
+
+// targets returns workers listed by flags followed by
+// discovered ones, each worker is probed once.
+func (p *prober) targets(ctx context.Context) ([]string, error) {
+	targets := append([]string{}, p.static...)
+	if p.rv != nil {
+		discovered, err := p.rv.workers(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		targets = append(targets, discovered...)
+	}
+
+	seen := map[string]bool{}
+	var unique []string
+	for _, t := range targets {
+		if !seen[t] {
+			seen[t] = true
+			unique = append(unique, t)
+		}
+	}
+
+	return unique, nil
+}
+
+// probeAll probes up to -parallel workers at once, each within -worker-timeout.
+func (p *prober) probeAll(ctx context.Context) ([]*workerStatus, error) {
+	targets, err := p.targets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	g := errgroup.Group{}
+	sem := make(chan struct{}, parallelFlag)
+	results := make([]*workerStatus, len(targets))
+
+	for i, target := range targets {
+		i, target := i, target
+		g.Go(func() error {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				results[i] = &workerStatus{endpoint: target, err: ctx.Err()}
+				return nil
+			}
+			defer func() { <-sem }()
+
+			results[i] = p.probe(ctx, target)
+			return nil
+		})
+	}
+
+	g.Wait()
+
+	for _, r := range results {
+		r.time = started
+		if r.err != nil {
+			logger.Debug("worker probe failed", zap.String("worker", r.endpoint), zap.Error(r.err))
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].endpoint < results[j].endpoint
+	})
+
+	return results, nil
+}
+
+func (p *prober) probe(ctx context.Context, target string) *workerStatus {
+	ctx, cancel := context.WithTimeout(ctx, workerTimeoutFlag)
+	defer cancel()
+
+	st := &workerStatus{endpoint: target}
+	if addr, eth, err := sonmclient.ParseTarget(target); err == nil {
+		st.endpoint, st.eth = addr, eth.Hex()
+	}
+
+	conn, err := p.id.Dial(ctx, target, sonmclient.Options{})
+	if err != nil {
+		st.err = err
+		return st
+	}
+	defer conn.ClientConn.Close()
+
+	started := time.Now()
+	reply, err := sonm.NewWorkerManagementClient(conn.ClientConn).Status(ctx, &sonm.Empty{})
+	st.latency = time.Since(started)
+
+	switch status.Code(err) {
+	case codes.OK:
+		st.reachable, st.authorized = true, true
+		st.version = reply.GetVersion()
+		st.uptime = time.Duration(reply.GetUptime()) * time.Second
+	case codes.PermissionDenied:
+		st.reachable = true
+	default:
+		st.err = err
+	}
+
+	return st
+}